@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"eLibrary/internal/export"
+	"eLibrary/internal/interchange"
+	"eLibrary/internal/service"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const exportUsage = "usage: elibrary export [-format csv|ndjson|marcxml] [-from DATE] [-to DATE] [-returned true|false] [-o FILE] books|users|loans"
+
+// runExport is the export command. It writes books, users or loans to a
+// file, named after what was exported and today's date unless given, and
+// prints its name. A file left incomplete by a failure is removed.
+func runExport(ctx context.Context, svc *service.Service, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := flags.String("format", "", "format of the file: csv, ndjson or marcxml; told by the extension of -o, else csv")
+	from := flags.String("from", "", "only records created, or loans lent, at or after this date or RFC 3339 time")
+	to := flags.String("to", "", "only records created, or loans lent, before this time or up to and including this date")
+	returned := flags.String("returned", "", "only returned (true) or outstanding (false) loans")
+	filename := flags.String("o", "", "file to write, books-YYYY-MM-DD.csv and the like by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(exportUsage)
+	}
+
+	kind, err := export.ParseKind(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	format := interchange.CSV
+	if *formatName != "" || *filename != "" {
+		if format, err = interchange.DetectFormat(*formatName, *filename); err != nil {
+			return err
+		}
+	}
+	if err = export.Check(kind, format); err != nil {
+		return err
+	}
+	filter, err := export.ParseFilter(*from, *to, *returned)
+	if err != nil {
+		return err
+	}
+
+	if *filename == "" {
+		*filename = export.Filename(kind, format, time.Now())
+	}
+	file, err := os.Create(*filename)
+	if err != nil {
+		return err
+	}
+	if err = export.Write(ctx, svc, kind, format, filter, file); err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err != nil {
+		os.Remove(*filename)
+		return err
+	}
+	fmt.Fprintf(out, "exported %s to %s\n", kind, *filename)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"eLibrary/internal/interchange"
+	"eLibrary/internal/service"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const importUsage = "usage: elibrary import [-format csv|marc|marcxml] [-dry-run] FILE"
+
+// runImport is the import command. It imports the books of a catalog file,
+// or of standard input when the file is "-", and prints what changed along
+// with the rows that failed.
+func runImport(ctx context.Context, svc *service.Service, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := flags.String("format", "", "format of the file: csv, marc or marcxml; told by its extension by default")
+	dryRun := flags.Bool("dry-run", false, "report what would change without changing anything")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+
+	filename := flags.Arg(0)
+	format, err := interchange.DetectFormat(*formatName, filename)
+	if err != nil {
+		return err
+	}
+	var content io.Reader = os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		content = file
+	}
+
+	rows, err := interchange.NewReader(format, content)
+	if err != nil {
+		return err
+	}
+	report, err := svc.ImportBooks(ctx, rows, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%d rows: %d created, %d updated, %d failed\n", report.Rows, report.Created, report.Updated, report.Failed)
+	for _, rowErr := range report.Errors {
+		fmt.Fprintf(out, "row %d (isbn %q): %v\n", rowErr.Row, rowErr.ISBN, rowErr.Err)
+	}
+	if report.DryRun {
+		fmt.Fprintln(out, "dry run, nothing was changed")
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", report.Failed, report.Rows)
+	}
+	return nil
+}
@@ -1,10 +1,21 @@
 package main
 
 import (
+	"context"
+	"eLibrary/config"
 	"eLibrary/database"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/handlers"
+	"eLibrary/internal/jobs"
+	"eLibrary/internal/repository"
+	"eLibrary/internal/service"
+	"eLibrary/internal/storage"
 	"eLibrary/routes"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
+	"os"
+	"time"
 )
 
 //go:generate go env -w GO111MODULE=on
@@ -16,12 +27,73 @@ func main() {
 		log.SetLevel(log.InfoLevel)
 	}
 
-	database.Init()
-	r := routes.SetupRouter()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Error loading config: ", err)
+	}
+
+	db, err := database.Init(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	err := r.Run(":3000")
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+	links, err := storage.NewSigner(cfg.Storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	svc := service.New(repository.NewGormRepositories(db),
+		service.WithHoldPickupWindow(cfg.Lending.HoldPickupWindow.Duration),
+		service.WithDueSoonWindow(cfg.Lending.DueSoonWindow.Duration),
+		service.WithFinePolicy(service.NewFinePolicy(cfg.Fines)),
+		service.WithFileStorage(store, links),
+		service.WithMaxUploadSize(cfg.Storage.MaxUploadSize))
+
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), svc, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if cfg.Auth.AdminUsername != "" {
+		created, err := svc.EnsureAdmin(context.Background(), cfg.Auth.AdminUsername, cfg.Auth.AdminPassword)
+		if err != nil {
+			log.Fatal("Error creating admin: ", err)
+		} else if created {
+			log.Infof("Created admin %s", cfg.Auth.AdminUsername)
+		}
+	}
+
+	tokens, err := auth.NewIssuer(cfg.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := routes.NewRouter(handlers.New(svc, tokens), handlers.NewHealthHandler(db), tokens)
+
+	go jobs.Every(context.Background(), "expire holds", time.Minute, svc.ExpireHolds)
+	go jobs.Every(context.Background(), "accrue fines", time.Hour, svc.AccrueFines)
+	go jobs.Every(context.Background(), "expire digital loans", time.Minute, svc.ExpireDigitalLoans)
+
+	err = r.Run(cfg.Server.Addr)
 	if err != nil {
 		log.Error("Error running app: ", err)
 		return
 	}
 }
+
+// runCommand runs one of the commands the binary offers besides serving the
+// API.
+func runCommand(ctx context.Context, svc *service.Service, command string, args []string) error {
+	switch command {
+	case "import":
+		return runImport(ctx, svc, args, os.Stdout)
+	case "export":
+		return runExport(ctx, svc, args, os.Stdout)
+	}
+	return fmt.Errorf("unknown command %q, use import or export", command)
+}
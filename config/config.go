@@ -0,0 +1,414 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported database dialects.
+const (
+	DialectPostgres     = "postgres"
+	DialectCockroach    = "cockroach"
+	DialectSQLite       = "sqlite"
+	DialectSQLiteMemory = "sqlite-memory"
+)
+
+// Supported storage backends for the files of digital items.
+const (
+	StorageLocal = "local"
+)
+
+// EnvConfigFile points at an optional YAML or TOML file that is loaded before
+// environment overrides are applied.
+const EnvConfigFile = "ELIBRARY_CONFIG_FILE"
+
+type Config struct {
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Lending  LendingConfig  `yaml:"lending" toml:"lending"`
+	Fines    FinesConfig    `yaml:"fines" toml:"fines"`
+	Storage  StorageConfig  `yaml:"storage" toml:"storage"`
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr" toml:"addr"`
+}
+
+type DatabaseConfig struct {
+	Dialect         string   `yaml:"dialect" toml:"dialect"`
+	Host            string   `yaml:"host" toml:"host"`
+	Port            int      `yaml:"port" toml:"port"`
+	User            string   `yaml:"user" toml:"user"`
+	Password        string   `yaml:"password" toml:"password"`
+	Name            string   `yaml:"name" toml:"name"`
+	SSLMode         string   `yaml:"sslmode" toml:"sslmode"`
+	Path            string   `yaml:"path" toml:"path"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+}
+
+// AuthConfig controls the signed tokens issued on login. When Secret is empty
+// a random one is generated at startup, so tokens do not survive a restart.
+// AdminUsername and AdminPassword, when set, create the first admin of an
+// installation that has none.
+type AuthConfig struct {
+	Secret          string   `yaml:"secret" toml:"secret"`
+	Issuer          string   `yaml:"issuer" toml:"issuer"`
+	AccessTokenTTL  Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+	RefreshTokenTTL Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+	AdminUsername   string   `yaml:"admin_username" toml:"admin_username"`
+	AdminPassword   string   `yaml:"admin_password" toml:"admin_password"`
+}
+
+// LendingConfig holds the circulation rules. HoldPickupWindow is how long a
+// copy set aside for a hold waits to be collected before it passes on, and
+// DueSoonWindow how long before its return date a loan is flagged as due soon.
+type LendingConfig struct {
+	HoldPickupWindow Duration `yaml:"hold_pickup_window" toml:"hold_pickup_window"`
+	DueSoonWindow    Duration `yaml:"due_soon_window" toml:"due_soon_window"`
+}
+
+// FinesConfig prices late returns, in minor currency units such as cents.
+// DailyRate is charged per started day overdue, up to MaxPerItem per loan
+// (zero for no cap); users owing more than BlockThreshold cannot borrow.
+type FinesConfig struct {
+	DailyRate      int64 `yaml:"daily_rate" toml:"daily_rate"`
+	MaxPerItem     int64 `yaml:"max_per_item" toml:"max_per_item"`
+	BlockThreshold int64 `yaml:"block_threshold" toml:"block_threshold"`
+}
+
+// StorageConfig says where the files of e-books are kept and how they are
+// handed out. Path is the directory of the local backend. Download links are
+// signed with LinkSecret, generated at startup when empty, and stay valid for
+// LinkTTL; MaxUploadSize caps uploads in bytes.
+type StorageConfig struct {
+	Backend       string   `yaml:"backend" toml:"backend"`
+	Path          string   `yaml:"path" toml:"path"`
+	MaxUploadSize int64    `yaml:"max_upload_size" toml:"max_upload_size"`
+	LinkSecret    string   `yaml:"link_secret" toml:"link_secret"`
+	LinkTTL       Duration `yaml:"link_ttl" toml:"link_ttl"`
+}
+
+// Duration wraps time.Duration so it can be written as "2h" or "30m" in
+// config files.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// Default returns the configuration used when neither a file nor environment
+// variables say otherwise: a local SQLite file on port 3000.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr: ":3000",
+		},
+		Database: DatabaseConfig{
+			Dialect:         DialectSQLite,
+			Path:            "elibrary.db",
+			MaxIdleConns:    3,
+			MaxOpenConns:    10,
+			ConnMaxLifetime: Duration{2 * time.Hour},
+		},
+		Auth: AuthConfig{
+			Issuer:          "elibrary",
+			AccessTokenTTL:  Duration{15 * time.Minute},
+			RefreshTokenTTL: Duration{7 * 24 * time.Hour},
+		},
+		Lending: LendingConfig{
+			HoldPickupWindow: Duration{72 * time.Hour},
+			DueSoonWindow:    Duration{72 * time.Hour},
+		},
+		Fines: FinesConfig{
+			DailyRate:      25,
+			MaxPerItem:     1000,
+			BlockThreshold: 500,
+		},
+		Storage: StorageConfig{
+			Backend:       StorageLocal,
+			Path:          "files",
+			MaxUploadSize: 100 << 20,
+			LinkTTL:       Duration{15 * time.Minute},
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the optional file named by
+// ELIBRARY_CONFIG_FILE and finally ELIBRARY_* environment variables.
+func Load() (Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv(EnvConfigFile); path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Auth.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Lending.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Fines.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Storage.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Database.validate(); err != nil {
+		return cfg, err
+	}
+	cfg.Database.applyDialectDefaults()
+
+	return cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file type %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	setString(&cfg.Server.Addr, "ELIBRARY_SERVER_ADDR")
+
+	db := &cfg.Database
+	setString(&db.Dialect, "ELIBRARY_DB_DIALECT")
+	setString(&db.Host, "ELIBRARY_DB_HOST")
+	setString(&db.User, "ELIBRARY_DB_USER")
+	setString(&db.Password, "ELIBRARY_DB_PASSWORD")
+	setString(&db.Name, "ELIBRARY_DB_NAME")
+	setString(&db.SSLMode, "ELIBRARY_DB_SSLMODE")
+	setString(&db.Path, "ELIBRARY_DB_PATH")
+
+	if err := setInt(&db.Port, "ELIBRARY_DB_PORT"); err != nil {
+		return err
+	}
+	if err := setInt(&db.MaxIdleConns, "ELIBRARY_DB_MAX_IDLE_CONNS"); err != nil {
+		return err
+	}
+	if err := setInt(&db.MaxOpenConns, "ELIBRARY_DB_MAX_OPEN_CONNS"); err != nil {
+		return err
+	}
+	if err := setDuration(&db.ConnMaxLifetime.Duration, "ELIBRARY_DB_CONN_MAX_LIFETIME"); err != nil {
+		return err
+	}
+
+	auth := &cfg.Auth
+	setString(&auth.Secret, "ELIBRARY_AUTH_SECRET")
+	setString(&auth.Issuer, "ELIBRARY_AUTH_ISSUER")
+	setString(&auth.AdminUsername, "ELIBRARY_AUTH_ADMIN_USERNAME")
+	setString(&auth.AdminPassword, "ELIBRARY_AUTH_ADMIN_PASSWORD")
+	if err := setDuration(&auth.AccessTokenTTL.Duration, "ELIBRARY_AUTH_ACCESS_TOKEN_TTL"); err != nil {
+		return err
+	}
+	if err := setDuration(&auth.RefreshTokenTTL.Duration, "ELIBRARY_AUTH_REFRESH_TOKEN_TTL"); err != nil {
+		return err
+	}
+
+	if err := setDuration(&cfg.Lending.HoldPickupWindow.Duration, "ELIBRARY_LENDING_HOLD_PICKUP_WINDOW"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.Lending.DueSoonWindow.Duration, "ELIBRARY_LENDING_DUE_SOON_WINDOW"); err != nil {
+		return err
+	}
+
+	fines := &cfg.Fines
+	if err := setInt64(&fines.DailyRate, "ELIBRARY_FINES_DAILY_RATE"); err != nil {
+		return err
+	}
+	if err := setInt64(&fines.MaxPerItem, "ELIBRARY_FINES_MAX_PER_ITEM"); err != nil {
+		return err
+	}
+	if err := setInt64(&fines.BlockThreshold, "ELIBRARY_FINES_BLOCK_THRESHOLD"); err != nil {
+		return err
+	}
+
+	storage := &cfg.Storage
+	setString(&storage.Backend, "ELIBRARY_STORAGE_BACKEND")
+	setString(&storage.Path, "ELIBRARY_STORAGE_PATH")
+	setString(&storage.LinkSecret, "ELIBRARY_STORAGE_LINK_SECRET")
+	if err := setInt64(&storage.MaxUploadSize, "ELIBRARY_STORAGE_MAX_UPLOAD_SIZE"); err != nil {
+		return err
+	}
+	if err := setDuration(&storage.LinkTTL.Duration, "ELIBRARY_STORAGE_LINK_TTL"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func setString(dst *string, key string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*dst = v
+	}
+}
+
+func setInt(dst *int, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func setInt64(dst *int64, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func setDuration(dst *time.Duration, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func (auth AuthConfig) validate() error {
+	if auth.AccessTokenTTL.Duration <= 0 || auth.RefreshTokenTTL.Duration <= 0 {
+		return fmt.Errorf("token lifetimes must be positive")
+	}
+	if auth.RefreshTokenTTL.Duration < auth.AccessTokenTTL.Duration {
+		return fmt.Errorf("refresh token lifetime must not be shorter than the access token lifetime")
+	}
+	if (auth.AdminUsername == "") != (auth.AdminPassword == "") {
+		return fmt.Errorf("admin username and password must be set together")
+	}
+	return nil
+}
+
+func (lending LendingConfig) validate() error {
+	if lending.HoldPickupWindow.Duration <= 0 {
+		return fmt.Errorf("hold pickup window must be positive")
+	}
+	if lending.DueSoonWindow.Duration <= 0 {
+		return fmt.Errorf("due soon window must be positive")
+	}
+	return nil
+}
+
+func (fines FinesConfig) validate() error {
+	if fines.DailyRate < 0 || fines.MaxPerItem < 0 || fines.BlockThreshold < 0 {
+		return fmt.Errorf("fine amounts must not be negative")
+	}
+	return nil
+}
+
+func (storage StorageConfig) validate() error {
+	switch storage.Backend {
+	case StorageLocal:
+		if storage.Path == "" {
+			return fmt.Errorf("storage path is required for backend %q", storage.Backend)
+		}
+	default:
+		return fmt.Errorf("unsupported storage backend %q", storage.Backend)
+	}
+	if storage.MaxUploadSize <= 0 {
+		return fmt.Errorf("maximum upload size must be positive")
+	}
+	if storage.LinkTTL.Duration <= 0 {
+		return fmt.Errorf("download link lifetime must be positive")
+	}
+	return nil
+}
+
+func (db DatabaseConfig) validate() error {
+	switch db.Dialect {
+	case DialectPostgres, DialectCockroach:
+		if db.Host == "" {
+			return fmt.Errorf("database host is required for dialect %q", db.Dialect)
+		}
+	case DialectSQLite:
+		if db.Path == "" {
+			return fmt.Errorf("database path is required for dialect %q", db.Dialect)
+		}
+	case DialectSQLiteMemory:
+	default:
+		return fmt.Errorf("unsupported database dialect %q", db.Dialect)
+	}
+	return nil
+}
+
+func (db *DatabaseConfig) applyDialectDefaults() {
+	switch db.Dialect {
+	case DialectPostgres:
+		if db.Port == 0 {
+			db.Port = 5432
+		}
+		if db.SSLMode == "" {
+			db.SSLMode = "disable"
+		}
+	case DialectCockroach:
+		if db.Port == 0 {
+			db.Port = 26257
+		}
+		if db.SSLMode == "" {
+			db.SSLMode = "verify-full"
+		}
+	case DialectSQLiteMemory:
+		// every connection to ":memory:" gets its own empty database, so the
+		// pool has to be pinned to a single long-lived connection
+		db.MaxIdleConns = 1
+		db.MaxOpenConns = 1
+		db.ConnMaxLifetime = Duration{}
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv(EnvConfigFile, "")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, ":3000", cfg.Server.Addr)
+	assert.Equal(t, DialectSQLite, cfg.Database.Dialect)
+	assert.Equal(t, "elibrary.db", cfg.Database.Path)
+	assert.Equal(t, 2*time.Hour, cfg.Database.ConnMaxLifetime.Duration)
+}
+
+func TestLoadFileAndEnv(t *testing.T) {
+	t.Run("YAML", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "elibrary.yaml")
+		err := os.WriteFile(path, []byte(`
+database:
+  dialect: postgres
+  host: db.internal
+  user: library
+  max_open_conns: 20
+  conn_max_lifetime: 30m
+`), 0o600)
+		assert.NoError(t, err)
+
+		t.Setenv(EnvConfigFile, path)
+		t.Setenv("ELIBRARY_DB_PASSWORD", "secret")
+
+		cfg, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, DialectPostgres, cfg.Database.Dialect)
+		assert.Equal(t, "db.internal", cfg.Database.Host)
+		assert.Equal(t, "secret", cfg.Database.Password)
+		assert.Equal(t, 5432, cfg.Database.Port)
+		assert.Equal(t, "disable", cfg.Database.SSLMode)
+		assert.Equal(t, 20, cfg.Database.MaxOpenConns)
+		assert.Equal(t, 30*time.Minute, cfg.Database.ConnMaxLifetime.Duration)
+	})
+
+	t.Run("TOML", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "elibrary.toml")
+		err := os.WriteFile(path, []byte(`
+[database]
+dialect = "cockroach"
+host = "cluster.example.com"
+conn_max_lifetime = "1h"
+`), 0o600)
+		assert.NoError(t, err)
+
+		t.Setenv(EnvConfigFile, path)
+		t.Setenv("ELIBRARY_DB_MAX_IDLE_CONNS", "7")
+
+		cfg, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, DialectCockroach, cfg.Database.Dialect)
+		assert.Equal(t, 26257, cfg.Database.Port)
+		assert.Equal(t, "verify-full", cfg.Database.SSLMode)
+		assert.Equal(t, 7, cfg.Database.MaxIdleConns)
+		assert.Equal(t, time.Hour, cfg.Database.ConnMaxLifetime.Duration)
+	})
+
+	t.Run("SQLite In-Memory", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, "")
+		t.Setenv("ELIBRARY_DB_DIALECT", DialectSQLiteMemory)
+
+		cfg, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, cfg.Database.MaxOpenConns)
+	})
+
+	t.Run("Unsupported Dialect", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, "")
+		t.Setenv("ELIBRARY_DB_DIALECT", "oracle")
+
+		_, err := Load()
+		assert.Error(t, err)
+	})
+
+	t.Run("Auth", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, "")
+		t.Setenv("ELIBRARY_AUTH_SECRET", "s3cret")
+		t.Setenv("ELIBRARY_AUTH_ACCESS_TOKEN_TTL", "5m")
+
+		cfg, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, "s3cret", cfg.Auth.Secret)
+		assert.Equal(t, 5*time.Minute, cfg.Auth.AccessTokenTTL.Duration)
+		assert.Equal(t, 7*24*time.Hour, cfg.Auth.RefreshTokenTTL.Duration)
+	})
+
+	t.Run("Refresh Shorter Than Access", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, "")
+		t.Setenv("ELIBRARY_AUTH_REFRESH_TOKEN_TTL", "1m")
+
+		_, err := Load()
+		assert.Error(t, err)
+	})
+
+	t.Run("Admin Without Password", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, "")
+		t.Setenv("ELIBRARY_AUTH_ADMIN_USERNAME", "admin")
+
+		_, err := Load()
+		assert.Error(t, err)
+	})
+
+	t.Run("Lending", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, "")
+
+		cfg, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, 72*time.Hour, cfg.Lending.HoldPickupWindow.Duration)
+		assert.Equal(t, 72*time.Hour, cfg.Lending.DueSoonWindow.Duration)
+
+		t.Setenv("ELIBRARY_LENDING_HOLD_PICKUP_WINDOW", "0s")
+		_, err = Load()
+		assert.Error(t, err)
+	})
+
+	t.Run("Fines", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, "")
+		t.Setenv("ELIBRARY_FINES_DAILY_RATE", "50")
+
+		cfg, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(50), cfg.Fines.DailyRate)
+		assert.Equal(t, int64(1000), cfg.Fines.MaxPerItem)
+
+		t.Setenv("ELIBRARY_FINES_BLOCK_THRESHOLD", "-1")
+		_, err = Load()
+		assert.Error(t, err)
+	})
+
+	t.Run("Storage", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, "")
+		t.Setenv("ELIBRARY_STORAGE_PATH", "/var/lib/elibrary")
+
+		cfg, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, StorageLocal, cfg.Storage.Backend)
+		assert.Equal(t, "/var/lib/elibrary", cfg.Storage.Path)
+		assert.Equal(t, 15*time.Minute, cfg.Storage.LinkTTL.Duration)
+
+		t.Setenv("ELIBRARY_STORAGE_BACKEND", "s3")
+		_, err = Load()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"eLibrary/config"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newDialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Dialect {
+	case config.DialectPostgres, config.DialectCockroach:
+		return postgres.Open(postgresDSN(cfg)), nil
+	case config.DialectSQLite:
+		return sqlite.Open(SQLiteDSN(cfg.Path)), nil
+	case config.DialectSQLiteMemory:
+		return sqlite.Open(":memory:"), nil
+	default:
+		return nil, fmt.Errorf("unsupported database dialect %q", cfg.Dialect)
+	}
+}
+
+// sqliteBusyTimeout is how long a connection to a SQLite file waits for
+// another one to finish writing.
+const sqliteBusyTimeout = 5 * time.Second
+
+// SQLiteDSN returns the data source name of the SQLite file at path. Its
+// transactions take the write lock as they begin, waiting up to
+// sqliteBusyTimeout for it, rather than when they first write: SQLite fails a
+// transaction that read and then finds the lock taken at once, without
+// waiting, which would fail concurrent borrows as a pool has several
+// connections.
+func SQLiteDSN(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%s_txlock=immediate&_busy_timeout=%d", path, separator, sqliteBusyTimeout.Milliseconds())
+}
+
+func postgresDSN(cfg config.DatabaseConfig) string {
+	dsn := url.URL{
+		Scheme:   "postgresql",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     fmt.Sprintf("%v:%v", cfg.Host, cfg.Port),
+		Path:     cfg.Name,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+	return dsn.String()
+}
@@ -1,5 +1,11 @@
 package database
 
-func Init() {
-	NewPostgres()
+import (
+	"eLibrary/config"
+	"gorm.io/gorm"
+)
+
+func Init(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	handler := dbHandler(start)
+	return handler.handleDb(cfg)
 }
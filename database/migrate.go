@@ -0,0 +1,227 @@
+package database
+
+import (
+	"eLibrary/isbn"
+	"eLibrary/model"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Models lists every schema managed by Migrate, in migration order.
+func Models() []interface{} {
+	return []interface{}{
+		&model.Author{},
+		&model.Work{},
+		&model.BookDetail{},
+		&model.BookCopy{},
+		&model.DigitalFile{},
+		&model.LoanDetail{},
+		&model.LoanRenewal{},
+		&model.User{},
+		&model.Hold{},
+		&model.Fine{},
+		&model.LoanPolicy{},
+		&model.Event{},
+		&model.ReadingProgress{},
+		&model.Bookmark{},
+		&model.Highlight{},
+	}
+}
+
+func Migrate(db *gorm.DB) error {
+	for _, m := range Models() {
+		if err := db.AutoMigrate(m); err != nil {
+			return err
+		}
+	}
+	if err := migrateCopyCounters(db); err != nil {
+		return err
+	}
+	if err := migrateWorks(db); err != nil {
+		return err
+	}
+	if err := seedLoanPolicy(db); err != nil {
+		return err
+	}
+	if err := migrateISBNs(db); err != nil {
+		return err
+	}
+	return createUniqueIndexes(db)
+}
+
+// uniqueIndex is a unique index on a column that was not unique to begin with.
+type uniqueIndex struct {
+	name   string
+	table  string
+	column string
+}
+
+// uniqueIndexes are created by Migrate once the data is migrated rather than
+// declared on the models, since databases from before they existed may hold
+// duplicates that AutoMigrate would fail on.
+var uniqueIndexes = []uniqueIndex{
+	{name: "idx_book_details_isbn", table: "book_details", column: "isbn"},
+	{name: "idx_users_username", table: "users", column: "username"},
+}
+
+// createUniqueIndexes creates the uniqueIndexes that do not exist yet. An
+// index whose column holds duplicates is left out, and the duplicates logged
+// for someone to fix, until a later start finds them resolved; the service
+// checks uniqueness on every write regardless.
+func createUniqueIndexes(db *gorm.DB) error {
+	for _, index := range uniqueIndexes {
+		if db.Migrator().HasIndex(index.table, index.name) {
+			continue
+		}
+
+		var duplicates []string
+		err := db.Table(index.table).Group(index.column).Having("COUNT(*) > 1").
+			Pluck(index.column, &duplicates).Error
+		if err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			log.WithField("table", index.table).Warnf("not enforcing unique %s until duplicates %q are resolved",
+				index.column, duplicates)
+			continue
+		}
+
+		stmt := fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)", index.name, index.table, index.column)
+		if err = db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedLoanPolicy stores the default loan policy when there is none, so the
+// loan periods that used to be hardcoded can be edited.
+func seedLoanPolicy(db *gorm.DB) error {
+	var count int64
+	if err := db.Unscoped().Model(&model.LoanPolicy{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	policy := model.DefaultLoanPolicy()
+	return db.Create(&policy).Error
+}
+
+// migrateISBNs rewrites ISBNs stored before they were normalized into their
+// canonical ISBN-13 form. Invalid ISBNs, and ISBNs whose normalized form is
+// already taken by another book, are left untouched for someone to fix.
+func migrateISBNs(db *gorm.DB) error {
+	var books []model.BookDetail
+	if err := db.Where("isbn13 IS NULL OR isbn13 = ''").Find(&books).Error; err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		normalized, err := isbn.Normalize(book.ISBN)
+		if err != nil {
+			log.WithField("book_id", book.ID).Warnf("keeping invalid isbn %q: %v", book.ISBN, err)
+			continue
+		}
+
+		var taken int64
+		err = db.Unscoped().Model(&model.BookDetail{}).Where("isbn = ? AND id <> ?", normalized, book.ID).Count(&taken).Error
+		if err != nil {
+			return err
+		} else if taken > 0 {
+			log.WithField("book_id", book.ID).Warnf("keeping isbn %q, %s belongs to another book", book.ISBN, normalized)
+			continue
+		}
+
+		isbn10, _ := isbn.To10(normalized)
+		err = db.Model(&book).UpdateColumns(map[string]interface{}{
+			"isbn":   normalized,
+			"isbn10": isbn10,
+			"isbn13": normalized,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateWorks gives every book catalogued before works existed a work of its
+// own, credited to the book's author.
+func migrateWorks(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var books []model.BookDetail
+		if err := tx.Where("work_id IS NULL").Find(&books).Error; err != nil {
+			return err
+		}
+
+		for _, book := range books {
+			work := model.Work{Title: book.Title}
+			if book.Author != "" {
+				author := model.Author{}
+				if err := tx.Where(model.Author{Name: book.Author}).FirstOrCreate(&author).Error; err != nil {
+					return err
+				}
+				work.Authors = []model.Author{author}
+			}
+			if err := tx.Create(&work).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&book).UpdateColumn("work_id", work.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// migrateCopyCounters replaces the available_copies counter that books used
+// to carry with one BookCopy row per counted copy, then drops the column.
+func migrateCopyCounters(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&model.BookDetail{}, "available_copies") {
+		return nil
+	}
+
+	type legacyBook struct {
+		ID              uint
+		AvailableCopies int
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var books []legacyBook
+		err := tx.Table("book_details").Select("id, available_copies").
+			Where("available_copies > 0").Scan(&books).Error
+		if err != nil {
+			return err
+		}
+
+		for _, book := range books {
+			copies := make([]model.BookCopy, 0, book.AvailableCopies)
+			for i := 1; i <= book.AvailableCopies; i++ {
+				copies = append(copies, model.BookCopy{
+					BookID:  book.ID,
+					Barcode: model.CopyBarcode(book.ID, i),
+					Status:  model.CopyAvailable,
+				})
+			}
+			if err = tx.Create(&copies).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().DropColumn(&model.BookDetail{}, "available_copies")
+	})
+}
+
+// PendingMigrations returns the tables of Models that do not exist yet.
+func PendingMigrations(db *gorm.DB) ([]string, error) {
+	var pending []string
+	for _, m := range Models() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			return nil, err
+		}
+		if !db.Migrator().HasTable(m) {
+			pending = append(pending, stmt.Schema.Table)
+		}
+	}
+	return pending, nil
+}
@@ -1,68 +1,55 @@
 package database
 
 import (
-	"eLibrary/global"
-	"eLibrary/model"
+	"eLibrary/config"
 	"fmt"
-	log "github.com/sirupsen/logrus"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
-	"time"
 )
 
-func NewPostgres() {
-	handler := dbHandler(start)
-	dsn := fmt.Sprintf("postgresql://%v:%v@%v:%v/%v?sslmode=verify-full",
-		"nickczj",
-		"SjjaKpb06K0W8hF66iTwKw",
-		"free-tier6.gcp-asia-southeast1.cockroachlabs.cloud",
-		"26257",
-		"last-koala-2636.defaultdb",
-	)
-	handler.handleDb(postgres.Open(dsn))
-}
+func start(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialect, err := newDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-func start(dialect gorm.Dialector) error {
 	// initialize connection
 	conf := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	}
 	database, err := gorm.Open(dialect, conf)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// settings
 	sqlDB, err := database.DB()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	sqlDB.SetMaxIdleConns(3)
-	sqlDB.SetMaxOpenConns(10)
-	sqlDB.SetConnMaxLifetime(2 * time.Hour)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime.Duration)
 
 	// test connection
 	if err = sqlDB.Ping(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// synchronize DB schemas
-	err = database.AutoMigrate(&model.BookDetail{})
-	err = database.AutoMigrate(&model.LoanDetail{})
-	err = database.AutoMigrate(&model.User{})
-	if err != nil {
-		return err
+	if err = Migrate(database); err != nil {
+		return nil, err
 	}
 
-	global.Database = database
-	return nil
+	return database, nil
 }
 
-type dbHandler func(dialect gorm.Dialector) error
+type dbHandler func(cfg config.DatabaseConfig) (*gorm.DB, error)
 
-func (fn dbHandler) handleDb(dialect gorm.Dialector) {
-	if err := fn(dialect); err != nil {
-		log.Error(err)
+func (fn dbHandler) handleDb(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	database, err := fn(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start %s database: %w", cfg.Dialect, err)
 	}
+	return database, nil
 }
@@ -1,9 +0,0 @@
-package global
-
-import (
-	"gorm.io/gorm"
-)
-
-var (
-	Database *gorm.DB
-)
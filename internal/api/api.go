@@ -0,0 +1,230 @@
+// Package api defines the JSON representations the HTTP API responds with and
+// maps the database models onto them. Keeping the two apart means a change to
+// a model, or to GORM's bookkeeping fields, never silently changes what
+// clients see. Request bodies are described by the request types of package
+// model, which carry no database fields at all.
+package api
+
+import (
+	"eLibrary/model"
+	"encoding/json"
+	"time"
+)
+
+// Time is a timestamp sent as an RFC 3339 string in UTC, to the second.
+type Time struct {
+	time.Time
+}
+
+func NewTime(t time.Time) Time {
+	return Time{t.UTC().Truncate(time.Second)}
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.UTC().Format(time.RFC3339))
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	t.Time = parsed
+	return err
+}
+
+// optionalTime maps a timestamp that may be unset.
+func optionalTime(t *time.Time) *Time {
+	if t == nil {
+		return nil
+	}
+	mapped := NewTime(*t)
+	return &mapped
+}
+
+// Map applies convert to every item, returning an empty rather than a nil
+// slice so collections are always sent as JSON arrays.
+func Map[T any, U any](items []T, convert func(T) U) []U {
+	mapped := make([]U, 0, len(items))
+	for _, item := range items {
+		mapped = append(mapped, convert(item))
+	}
+	return mapped
+}
+
+type Author struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func NewAuthor(author model.Author) Author {
+	return Author{ID: author.ID, Name: author.Name}
+}
+
+type Work struct {
+	ID        uint     `json:"id"`
+	Title     string   `json:"title"`
+	Authors   []Author `json:"authors"`
+	Editions  []Book   `json:"editions,omitempty"`
+	CreatedAt Time     `json:"created_at"`
+	UpdatedAt Time     `json:"updated_at"`
+}
+
+func NewWork(work model.Work) Work {
+	w := Work{
+		ID:        work.ID,
+		Title:     work.Title,
+		Authors:   Map(work.Authors, NewAuthor),
+		CreatedAt: NewTime(work.CreatedAt),
+		UpdatedAt: NewTime(work.UpdatedAt),
+	}
+	if len(work.Editions) > 0 {
+		w.Editions = Map(work.Editions, NewBook)
+	}
+	return w
+}
+
+// Book is an edition of a work. Version doubles as its entity tag.
+type Book struct {
+	ID              uint   `json:"id"`
+	WorkID          *uint  `json:"work_id"`
+	Work            *Work  `json:"work,omitempty"`
+	Title           string `json:"title"`
+	Author          string `json:"author"`
+	ISBN            string `json:"isbn"`
+	ISBN10          string `json:"isbn_10"`
+	ISBN13          string `json:"isbn_13"`
+	Publisher       string `json:"publisher"`
+	PublicationYear int    `json:"publication_year"`
+	Language        string `json:"language"`
+	Format          string `json:"format"`
+	Version         uint   `json:"version"`
+	AvailableCopies int    `json:"available_copies"`
+	TotalCopies     int    `json:"total_copies"`
+	Copies          []Copy `json:"copies,omitempty"`
+	CreatedAt       Time   `json:"created_at"`
+	UpdatedAt       Time   `json:"updated_at"`
+}
+
+func NewBook(book model.BookDetail) Book {
+	b := Book{
+		ID:              book.ID,
+		WorkID:          book.WorkID,
+		Title:           book.Title,
+		Author:          book.Author,
+		ISBN:            book.ISBN,
+		ISBN10:          book.ISBN10,
+		ISBN13:          book.ISBN13,
+		Publisher:       book.Publisher,
+		PublicationYear: book.PublicationYear,
+		Language:        book.Language,
+		Format:          book.Format,
+		Version:         book.Version,
+		AvailableCopies: book.AvailableCopies,
+		TotalCopies:     book.TotalCopies,
+		CreatedAt:       NewTime(book.CreatedAt),
+		UpdatedAt:       NewTime(book.UpdatedAt),
+	}
+	if book.Work != nil {
+		work := NewWork(*book.Work)
+		b.Work = &work
+	}
+	if len(book.Copies) > 0 {
+		b.Copies = Map(book.Copies, NewCopy)
+	}
+	return b
+}
+
+type Copy struct {
+	ID            uint             `json:"id"`
+	BookID        uint             `json:"book_id"`
+	Barcode       string           `json:"barcode"`
+	Condition     string           `json:"condition"`
+	ShelfLocation string           `json:"shelf_location"`
+	Status        model.CopyStatus `json:"status"`
+	CreatedAt     Time             `json:"created_at"`
+	UpdatedAt     Time             `json:"updated_at"`
+}
+
+func NewCopy(bookCopy model.BookCopy) Copy {
+	return Copy{
+		ID:            bookCopy.ID,
+		BookID:        bookCopy.BookID,
+		Barcode:       bookCopy.Barcode,
+		Condition:     bookCopy.Condition,
+		ShelfLocation: bookCopy.ShelfLocation,
+		Status:        bookCopy.Status,
+		CreatedAt:     NewTime(bookCopy.CreatedAt),
+		UpdatedAt:     NewTime(bookCopy.UpdatedAt),
+	}
+}
+
+// File is an e-book file of a book. Checksum is the hex encoded SHA-256 of
+// its content.
+type File struct {
+	ID                uint              `json:"id"`
+	BookID            uint              `json:"book_id"`
+	Format            model.EbookFormat `json:"format"`
+	ContentType       string            `json:"content_type"`
+	Filename          string            `json:"filename"`
+	Size              int64             `json:"size"`
+	Checksum          string            `json:"checksum"`
+	Licenses          int               `json:"licenses"`
+	AvailableLicenses int               `json:"available_licenses"`
+	CreatedAt         Time              `json:"created_at"`
+	UpdatedAt         Time              `json:"updated_at"`
+}
+
+func NewFile(file model.DigitalFile) File {
+	return File{
+		ID:                file.ID,
+		BookID:            file.BookID,
+		Format:            file.Format,
+		ContentType:       file.Format.ContentType(),
+		Filename:          file.Filename,
+		Size:              file.Size,
+		Checksum:          file.Checksum,
+		Licenses:          file.Licenses,
+		AvailableLicenses: file.AvailableLicenses(),
+		CreatedAt:         NewTime(file.CreatedAt),
+		UpdatedAt:         NewTime(file.UpdatedAt),
+	}
+}
+
+// DownloadLink is a signed URL the file of a digital loan can be fetched from
+// without credentials until ExpiresAt.
+type DownloadLink struct {
+	URL       string `json:"url"`
+	ExpiresAt Time   `json:"expires_at"`
+}
+
+// User is an account without its credentials. Version doubles as its entity
+// tag.
+type User struct {
+	ID        uint       `json:"id"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Username  string     `json:"username"`
+	Email     string     `json:"email"`
+	Role      model.Role `json:"role"`
+	Category  string     `json:"category"`
+	Version   uint       `json:"version"`
+	CreatedAt Time       `json:"created_at"`
+	UpdatedAt Time       `json:"updated_at"`
+}
+
+func NewUser(user model.User) User {
+	return User{
+		ID:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      user.Role,
+		Category:  user.Category,
+		Version:   user.Version,
+		CreatedAt: NewTime(user.CreatedAt),
+		UpdatedAt: NewTime(user.UpdatedAt),
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"eLibrary/internal/errs"
+	"eLibrary/internal/service"
+	"errors"
+)
+
+// ImportReport tells what a catalog import did, or would have done on a dry
+// run.
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Rows    int           `json:"rows"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Failed  int           `json:"failed"`
+	Errors  []ImportError `json:"errors"`
+}
+
+// ImportError is why a row of an import was skipped. Code is that of the
+// error, as in problem details, and Fields lists the fields that failed
+// validation when that is why.
+type ImportError struct {
+	Row     int               `json:"row"`
+	ISBN    string            `json:"isbn"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  []errs.FieldError `json:"fields,omitempty"`
+}
+
+func NewImportReport(report service.ImportReport) ImportReport {
+	return ImportReport{
+		DryRun:  report.DryRun,
+		Rows:    report.Rows,
+		Created: report.Created,
+		Updated: report.Updated,
+		Failed:  report.Failed,
+		Errors:  Map(report.Errors, NewImportError),
+	}
+}
+
+func NewImportError(rowErr service.RowError) ImportError {
+	e := ImportError{Row: rowErr.Row, ISBN: rowErr.ISBN, Message: rowErr.Err.Error()}
+	if domain := errs.As(rowErr.Err); domain != nil {
+		e.Code = domain.Code
+	}
+	var fields errs.Fields
+	if errors.As(rowErr.Err, &fields) {
+		e.Fields = fields
+	}
+	return e
+}
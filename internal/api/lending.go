@@ -0,0 +1,304 @@
+package api
+
+import "eLibrary/model"
+
+// Loan is a copy or digital file of a book lent to a user. Book, User, Copy
+// and File are only sent when they were loaded along with the loan.
+type Loan struct {
+	ID             uint      `json:"id"`
+	BookID         uint      `json:"book_id"`
+	Book           *Book     `json:"book,omitempty"`
+	CopyID         *uint     `json:"copy_id"`
+	Copy           *Copy     `json:"copy,omitempty"`
+	FileID         *uint     `json:"file_id"`
+	File           *File     `json:"file,omitempty"`
+	UserID         uint      `json:"user_id"`
+	User           *User     `json:"user,omitempty"`
+	NameOfBorrower string    `json:"name_of_borrower"`
+	LoanDate       Time      `json:"loan_date"`
+	ReturnDate     Time      `json:"return_date"`
+	IsReturned     bool      `json:"is_returned"`
+	ReturnedAt     *Time     `json:"returned_at"`
+	Renewals       int       `json:"renewals"`
+	RenewalHistory []Renewal `json:"renewal_history"`
+	Overdue        bool      `json:"overdue"`
+	DueSoon        bool      `json:"due_soon"`
+	Fine           *Fine     `json:"fine,omitempty"`
+	CreatedAt      Time      `json:"created_at"`
+	UpdatedAt      Time      `json:"updated_at"`
+}
+
+func NewLoan(loan model.LoanDetail) Loan {
+	l := Loan{
+		ID:             loan.ID,
+		BookID:         loan.BookID,
+		CopyID:         loan.CopyID,
+		FileID:         loan.FileID,
+		UserID:         loan.UserID,
+		NameOfBorrower: loan.NameOfBorrower,
+		LoanDate:       NewTime(loan.LoanDate),
+		ReturnDate:     NewTime(loan.ReturnDate),
+		IsReturned:     loan.IsReturned,
+		ReturnedAt:     optionalTime(loan.ReturnedAt),
+		Renewals:       loan.Renewals,
+		RenewalHistory: Map(loan.RenewalHistory, NewRenewal),
+		Overdue:        loan.Overdue,
+		DueSoon:        loan.DueSoon,
+		CreatedAt:      NewTime(loan.CreatedAt),
+		UpdatedAt:      NewTime(loan.UpdatedAt),
+	}
+	if loan.BookDetail.ID != 0 {
+		book := NewBook(loan.BookDetail)
+		l.Book = &book
+	}
+	if loan.Copy != nil {
+		bookCopy := NewCopy(*loan.Copy)
+		l.Copy = &bookCopy
+	}
+	if loan.File != nil {
+		file := NewFile(*loan.File)
+		l.File = &file
+	}
+	if loan.User.ID != 0 {
+		user := NewUser(loan.User)
+		l.User = &user
+	}
+	if loan.Fine != nil {
+		fine := NewFine(*loan.Fine)
+		l.Fine = &fine
+	}
+	return l
+}
+
+type Renewal struct {
+	ID                 uint `json:"id"`
+	LoanID             uint `json:"loan_id"`
+	RenewedBy          uint `json:"renewed_by"`
+	RenewedAt          Time `json:"renewed_at"`
+	PreviousReturnDate Time `json:"previous_return_date"`
+	NewReturnDate      Time `json:"new_return_date"`
+}
+
+func NewRenewal(renewal model.LoanRenewal) Renewal {
+	return Renewal{
+		ID:                 renewal.ID,
+		LoanID:             renewal.LoanID,
+		RenewedBy:          renewal.RenewedBy,
+		RenewedAt:          NewTime(renewal.RenewedAt),
+		PreviousReturnDate: NewTime(renewal.PreviousReturnDate),
+		NewReturnDate:      NewTime(renewal.NewReturnDate),
+	}
+}
+
+// Hold is a patron's place in the queue for a book. Book and User are only
+// sent when they were loaded along with the hold.
+type Hold struct {
+	ID        uint             `json:"id"`
+	BookID    uint             `json:"book_id"`
+	Book      *Book            `json:"book,omitempty"`
+	UserID    uint             `json:"user_id"`
+	User      *User            `json:"user,omitempty"`
+	CopyID    *uint            `json:"copy_id"`
+	Position  int              `json:"position"`
+	Status    model.HoldStatus `json:"status"`
+	ReadyAt   *Time            `json:"ready_at"`
+	ExpiresAt *Time            `json:"expires_at"`
+	CreatedAt Time             `json:"created_at"`
+	UpdatedAt Time             `json:"updated_at"`
+}
+
+func NewHold(hold model.Hold) Hold {
+	h := Hold{
+		ID:        hold.ID,
+		BookID:    hold.BookID,
+		UserID:    hold.UserID,
+		CopyID:    hold.CopyID,
+		Position:  hold.Position,
+		Status:    hold.Status,
+		ReadyAt:   optionalTime(hold.ReadyAt),
+		ExpiresAt: optionalTime(hold.ExpiresAt),
+		CreatedAt: NewTime(hold.CreatedAt),
+		UpdatedAt: NewTime(hold.UpdatedAt),
+	}
+	if hold.Book != nil {
+		book := NewBook(*hold.Book)
+		h.Book = &book
+	}
+	if hold.User != nil {
+		user := NewUser(*hold.User)
+		h.User = &user
+	}
+	return h
+}
+
+// Fine charges a user for a late loan. Amounts are in minor currency units;
+// Outstanding is what is left to pay.
+type Fine struct {
+	ID          uint             `json:"id"`
+	LoanID      uint             `json:"loan_id"`
+	UserID      uint             `json:"user_id"`
+	DaysOverdue int              `json:"days_overdue"`
+	Amount      int64            `json:"amount"`
+	Paid        int64            `json:"paid"`
+	Outstanding int64            `json:"outstanding"`
+	Status      model.FineStatus `json:"status"`
+	Final       bool             `json:"final"`
+	SettledAt   *Time            `json:"settled_at"`
+	SettledBy   *uint            `json:"settled_by"`
+	Note        string           `json:"note"`
+	CreatedAt   Time             `json:"created_at"`
+	UpdatedAt   Time             `json:"updated_at"`
+}
+
+func NewFine(fine model.Fine) Fine {
+	return Fine{
+		ID:          fine.ID,
+		LoanID:      fine.LoanID,
+		UserID:      fine.UserID,
+		DaysOverdue: fine.DaysOverdue,
+		Amount:      fine.Amount,
+		Paid:        fine.Paid,
+		Outstanding: fine.Outstanding(),
+		Status:      fine.Status,
+		Final:       fine.Final,
+		SettledAt:   optionalTime(fine.SettledAt),
+		SettledBy:   fine.SettledBy,
+		Note:        fine.Note,
+		CreatedAt:   NewTime(fine.CreatedAt),
+		UpdatedAt:   NewTime(fine.UpdatedAt),
+	}
+}
+
+// LoanPolicy is a set of lending rules. Version doubles as its entity tag.
+type LoanPolicy struct {
+	ID                  uint   `json:"id"`
+	PatronCategory      string `json:"patron_category"`
+	ItemType            string `json:"item_type"`
+	LoanDays            int    `json:"loan_days"`
+	RenewalDays         int    `json:"renewal_days"`
+	MaxRenewals         int    `json:"max_renewals"`
+	MaxConcurrentLoans  int    `json:"max_concurrent_loans"`
+	BlockRenewalOnHolds bool   `json:"block_renewal_on_holds"`
+	RenewalGraceDays    int    `json:"renewal_grace_days"`
+	Version             uint   `json:"version"`
+	CreatedAt           Time   `json:"created_at"`
+	UpdatedAt           Time   `json:"updated_at"`
+}
+
+func NewLoanPolicy(policy model.LoanPolicy) LoanPolicy {
+	return LoanPolicy{
+		ID:                  policy.ID,
+		PatronCategory:      policy.PatronCategory,
+		ItemType:            policy.ItemType,
+		LoanDays:            policy.LoanDays,
+		RenewalDays:         policy.RenewalDays,
+		MaxRenewals:         policy.MaxRenewals,
+		MaxConcurrentLoans:  policy.MaxConcurrentLoans,
+		BlockRenewalOnHolds: policy.BlockRenewalOnHolds,
+		RenewalGraceDays:    policy.RenewalGraceDays,
+		Version:             policy.Version,
+		CreatedAt:           NewTime(policy.CreatedAt),
+		UpdatedAt:           NewTime(policy.UpdatedAt),
+	}
+}
+
+// Event is an entry of the event log. The ids name what the event is about
+// and are null when it is not about a loan, user or book.
+type Event struct {
+	ID         uint            `json:"id"`
+	Type       model.EventType `json:"type"`
+	LoanID     *uint           `json:"loan_id"`
+	UserID     *uint           `json:"user_id"`
+	BookID     *uint           `json:"book_id"`
+	OccurredAt Time            `json:"occurred_at"`
+}
+
+func NewEvent(event model.Event) Event {
+	return Event{
+		ID:         event.ID,
+		Type:       event.Type,
+		LoanID:     event.LoanID,
+		UserID:     event.UserID,
+		BookID:     event.BookID,
+		OccurredAt: NewTime(event.OccurredAt),
+	}
+}
+
+// Location points into a digital book, see model.ReadingLocation.
+type Location struct {
+	Chapter string `json:"chapter"`
+	CFI     string `json:"cfi"`
+	Page    int    `json:"page"`
+}
+
+func NewLocation(location model.ReadingLocation) Location {
+	return Location{Chapter: location.Chapter, CFI: location.CFI, Page: location.Page}
+}
+
+// ReadingProgress is where a user is in a digital book. RecordedAt is when
+// the reader was there and UpdatedAt when it was synced.
+type ReadingProgress struct {
+	BookID          uint     `json:"book_id"`
+	LoanID          uint     `json:"loan_id"`
+	Location        Location `json:"location"`
+	Percent         float64  `json:"percent"`
+	KeepAfterReturn bool     `json:"keep_after_return"`
+	RecordedAt      Time     `json:"recorded_at"`
+	UpdatedAt       Time     `json:"updated_at"`
+}
+
+func NewReadingProgress(progress model.ReadingProgress) ReadingProgress {
+	return ReadingProgress{
+		BookID:          progress.BookID,
+		LoanID:          progress.LoanID,
+		Location:        NewLocation(progress.Location),
+		Percent:         progress.Percent,
+		KeepAfterReturn: progress.KeepAfterReturn,
+		RecordedAt:      NewTime(progress.RecordedAt),
+		UpdatedAt:       NewTime(progress.UpdatedAt),
+	}
+}
+
+type Bookmark struct {
+	ID        uint     `json:"id"`
+	BookID    uint     `json:"book_id"`
+	LoanID    uint     `json:"loan_id"`
+	Location  Location `json:"location"`
+	Label     string   `json:"label"`
+	CreatedAt Time     `json:"created_at"`
+}
+
+func NewBookmark(bookmark model.Bookmark) Bookmark {
+	return Bookmark{
+		ID:        bookmark.ID,
+		BookID:    bookmark.BookID,
+		LoanID:    bookmark.LoanID,
+		Location:  NewLocation(bookmark.Location),
+		Label:     bookmark.Label,
+		CreatedAt: NewTime(bookmark.CreatedAt),
+	}
+}
+
+type Highlight struct {
+	ID        uint     `json:"id"`
+	BookID    uint     `json:"book_id"`
+	LoanID    uint     `json:"loan_id"`
+	Location  Location `json:"location"`
+	Text      string   `json:"text"`
+	Note      string   `json:"note"`
+	Color     string   `json:"color"`
+	CreatedAt Time     `json:"created_at"`
+}
+
+func NewHighlight(highlight model.Highlight) Highlight {
+	return Highlight{
+		ID:        highlight.ID,
+		BookID:    highlight.BookID,
+		LoanID:    highlight.LoanID,
+		Location:  NewLocation(highlight.Location),
+		Text:      highlight.Text,
+		Note:      highlight.Note,
+		Color:     highlight.Color,
+		CreatedAt: NewTime(highlight.CreatedAt),
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"eLibrary/internal/errs"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password HashPassword accepts. bcrypt
+// itself caps passwords at 72 bytes.
+const MinPasswordLength = 8
+
+var (
+	ErrPasswordTooShort = errs.New(errs.Invalid, "invalid_password", "password must be at least 8 characters")
+	ErrPasswordTooLong  = errs.New(errs.Invalid, "invalid_password", "password must be at most 72 bytes")
+)
+
+// HashPassword returns the bcrypt hash of password.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", ErrPasswordTooLong
+	}
+	return string(hash), err
+}
+
+// CheckPassword reports whether password matches hash. An empty hash, as held
+// by users created before passwords existed, never matches.
+func CheckPassword(hash string, password string) bool {
+	if hash == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
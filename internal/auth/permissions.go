@@ -0,0 +1,45 @@
+package auth
+
+import "eLibrary/model"
+
+// Permission names an operation that is not open to every authenticated user.
+type Permission string
+
+const (
+	// ManageCatalog covers creating, editing and deleting books and copies.
+	ManageCatalog Permission = "catalog:manage"
+	// ManageUsers covers registering, listing, editing and deleting users
+	// other than oneself.
+	ManageUsers Permission = "users:manage"
+	// ViewAllLoans lifts the restriction of loan listings to one's own loans.
+	ViewAllLoans Permission = "loans:view-all"
+	// ManageLoans covers renewing loans on behalf of their borrowers.
+	ManageLoans Permission = "loans:manage"
+	// ManageHolds covers viewing hold queues and cancelling or reordering
+	// other users' holds.
+	ManageHolds Permission = "holds:manage"
+	// ManageFines covers viewing anyone's fines, taking payments for them
+	// and waiving them.
+	ManageFines Permission = "fines:manage"
+	// ManagePolicies covers creating, editing and deleting loan policies.
+	ManagePolicies Permission = "policies:manage"
+	// AssignRoles covers setting the role of a user, and editing or deleting
+	// staff accounts.
+	AssignRoles Permission = "roles:assign"
+)
+
+var rolePermissions = map[model.Role][]Permission{
+	model.RolePatron:    {},
+	model.RoleLibrarian: {ManageCatalog, ManageUsers, ViewAllLoans, ManageLoans, ManageHolds, ManageFines},
+	model.RoleAdmin:     {ManageCatalog, ManageUsers, ViewAllLoans, ManageLoans, ManageHolds, ManageFines, ManagePolicies, AssignRoles},
+}
+
+// Can reports whether users with role hold permission.
+func Can(role model.Role, permission Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
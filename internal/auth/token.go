@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/rand"
+	"eLibrary/config"
+	"eLibrary/internal/errs"
+	"eLibrary/model"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+// TokenKind tells access tokens, which authorize API calls, apart from refresh
+// tokens, which may only be exchanged for a new pair.
+type TokenKind string
+
+const (
+	AccessToken  TokenKind = "access"
+	RefreshToken TokenKind = "refresh"
+)
+
+var ErrInvalidToken = errs.New(errs.Unauthenticated, "invalid_token", "invalid or expired token")
+
+// Claims are the JWT claims of both token kinds; the subject is the user ID.
+// The role is captured when the token is issued, so a role change takes
+// effect for access tokens on the next refresh.
+type Claims struct {
+	jwt.RegisteredClaims
+	Kind TokenKind  `json:"kind"`
+	Role model.Role `json:"role,omitempty"`
+}
+
+// Principal is the user a verified token was issued to.
+type Principal struct {
+	UserID uint
+	Role   model.Role
+}
+
+// TokenPair is handed out on login and refresh.
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Issuer signs and verifies HS256 tokens.
+type Issuer struct {
+	secret     []byte
+	issuer     string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewIssuer(cfg config.AuthConfig) (*Issuer, error) {
+	secret := []byte(cfg.Secret)
+	if len(secret) == 0 {
+		log.Warn("no auth secret configured, generating one; tokens will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("generating auth secret: %w", err)
+		}
+	}
+
+	return &Issuer{
+		secret:     secret,
+		issuer:     cfg.Issuer,
+		accessTTL:  cfg.AccessTokenTTL.Duration,
+		refreshTTL: cfg.RefreshTokenTTL.Duration,
+	}, nil
+}
+
+// Issue returns a fresh access and refresh token for the user.
+func (i *Issuer) Issue(principal Principal) (pair TokenPair, err error) {
+	now := time.Now()
+	pair.TokenType = "Bearer"
+	pair.ExpiresAt = now.Add(i.accessTTL)
+
+	if pair.AccessToken, err = i.sign(principal, AccessToken, now, i.accessTTL); err != nil {
+		return pair, err
+	}
+	pair.RefreshToken, err = i.sign(principal, RefreshToken, now, i.refreshTTL)
+	return pair, err
+}
+
+// Verify checks the signature, expiry and kind of token and returns the user
+// it was issued to.
+func (i *Issuer) Verify(token string, kind TokenKind) (Principal, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return i.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(i.issuer), jwt.WithExpirationRequired())
+	if err != nil || claims.Kind != kind {
+		return Principal{}, ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil || userID == 0 {
+		return Principal{}, ErrInvalidToken
+	}
+	return Principal{UserID: uint(userID), Role: claims.Role}, nil
+}
+
+func (i *Issuer) sign(principal Principal, kind TokenKind, now time.Time, ttl time.Duration) (string, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.issuer,
+			Subject:   strconv.FormatUint(uint64(principal.UserID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Kind: kind,
+		Role: principal.Role,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.secret)
+}
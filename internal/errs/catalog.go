@@ -0,0 +1,137 @@
+package errs
+
+// Errors about the request itself.
+var (
+	InvalidBody      = New(Invalid, "invalid_body", "invalid request body")
+	ValidationFailed = New(Invalid, "validation_failed", "validation failed")
+	InvalidParameter = New(Invalid, "invalid_parameter", "invalid path or query parameter")
+	InvalidTitle     = New(Invalid, "invalid_title", "invalid book title provided")
+	InvalidIfMatch   = New(Invalid, "invalid_if_match", "invalid If-Match header")
+	IfMatchRequired  = New(Unconditional, "if_match_required", "an If-Match header is required")
+	NotAllowed       = New(Forbidden, "forbidden", "you are not allowed to perform this operation")
+)
+
+// Errors about signing in.
+var (
+	MissingToken = New(Unauthenticated, "missing_token", "a bearer token is required")
+	// InvalidCredentials is returned for an unknown username and a wrong
+	// password alike.
+	InvalidCredentials = New(Unauthenticated, "invalid_credentials", "invalid username or password")
+)
+
+// Resources that do not exist.
+var (
+	BookNotFound       = New(NotFound, "book_not_found", "book not found")
+	WorkNotFound       = New(NotFound, "work_not_found", "work not found")
+	CopyNotFound       = New(NotFound, "copy_not_found", "copy not found")
+	UserNotFound       = New(NotFound, "user_not_found", "user not found")
+	LoanNotFound       = New(NotFound, "loan_not_found", "loan not found")
+	HoldNotFound       = New(NotFound, "hold_not_found", "hold not found")
+	FineNotFound       = New(NotFound, "fine_not_found", "fine not found")
+	LoanPolicyNotFound = New(NotFound, "loan_policy_not_found", "loan policy not found")
+	FileNotFound       = New(NotFound, "file_not_found", "file not found")
+	BookmarkNotFound   = New(NotFound, "bookmark_not_found", "bookmark not found")
+	HighlightNotFound  = New(NotFound, "highlight_not_found", "highlight not found")
+)
+
+// Errors about the catalog and user accounts.
+var (
+	// InvalidISBN is wrapped with the reason package isbn rejected an ISBN.
+	InvalidISBN = New(Invalid, "invalid_isbn", "invalid isbn")
+	// UnknownWork is returned when a book is created for or moved to a work
+	// that does not exist.
+	UnknownWork = New(Invalid, "unknown_work", "work not found")
+	// DuplicateISBN is returned when another edition already has the same
+	// normalized ISBN.
+	DuplicateISBN     = New(Conflict, "duplicate_isbn", "a book with this isbn exists")
+	InvalidCopyStatus = New(Invalid, "invalid_copy_status", "invalid copy status")
+	// CopyStatusLocked is returned for copy status changes that only
+	// borrowing, returning and holds may make.
+	CopyStatusLocked  = New(Conflict, "copy_status_locked", "copy status cannot be changed")
+	InvalidRole       = New(Invalid, "invalid_role", "invalid role")
+	DuplicateUsername = New(Conflict, "duplicate_username", "a user with this username exists")
+	// HasActiveLoans is returned when deleting a book or user that still has
+	// unreturned loans.
+	HasActiveLoans = New(Conflict, "has_active_loans", "there are outstanding loans")
+)
+
+// Errors about lending.
+var (
+	// NoCopiesAvailable is returned when every copy of a book is out on loan.
+	NoCopiesAvailable = New(Conflict, "no_copies_available", "there are no more available books to borrow")
+	LoanExists        = New(Conflict, "loan_exists", "a loan for this book exists")
+	// NoActiveLoan is returned when extending or returning a book the caller
+	// does not have out.
+	NoActiveLoan = New(Conflict, "no_active_loan", "loan not found")
+	// LoanReturned is returned when renewing a loan that is no longer out.
+	LoanReturned = New(Conflict, "loan_returned", "loan was already returned")
+	// LoanOverdue is returned when renewing a loan that is overdue beyond the
+	// grace period of its policy.
+	LoanOverdue = New(Conflict, "loan_overdue", "loan is overdue beyond the renewal grace period")
+	// LoanLimitReached is returned when borrowing would take a patron over the
+	// concurrent loans their policy allows.
+	LoanLimitReached = New(Forbidden, "loan_limit_reached", "the maximum number of concurrent loans is reached")
+	// RenewalLimitReached is returned when a loan was already renewed as
+	// often as its policy allows.
+	RenewalLimitReached = New(Conflict, "renewal_limit_reached", "the maximum number of renewals is reached")
+	// RenewalBlocked is returned when renewing a loan of a book that other
+	// patrons hold, under a policy that puts them first.
+	RenewalBlocked  = New(Conflict, "renewal_blocked", "other patrons are waiting for this book")
+	DuplicatePolicy = New(Conflict, "duplicate_loan_policy", "a loan policy for this patron category and item type exists")
+)
+
+// Errors about digital items.
+var (
+	// UnsupportedFileType is returned when uploading a file that is neither
+	// an EPUB nor a PDF.
+	UnsupportedFileType = New(Invalid, "unsupported_file_type", "only EPUB and PDF files are accepted")
+	FileTooLarge        = New(TooLarge, "file_too_large", "file exceeds the maximum upload size")
+	// NoLicensesAvailable is returned when every license of a file is out
+	// on loan.
+	NoLicensesAvailable = New(Conflict, "no_licenses_available", "there are no more licenses of this file to borrow")
+	// NotDigitalLoan is returned when asking for a download link of, or
+	// recording reading progress under, a loan of a physical copy.
+	NotDigitalLoan = New(Conflict, "not_digital_loan", "loan is not of a digital item")
+	// LoanExpired is returned when asking for a download link of a loan past
+	// its return date.
+	LoanExpired = New(Conflict, "loan_expired", "loan is past its return date")
+	// InvalidDownloadLink is returned for download links that were tampered
+	// with, have expired or belong to a loan that ended.
+	InvalidDownloadLink = New(Forbidden, "invalid_download_link", "download link is invalid or expired")
+)
+
+// Errors about importing catalog records.
+var (
+	UnsupportedFormat = New(Invalid, "unsupported_format", "unsupported catalog file format")
+	// InvalidImportFile is wrapped with the reason an import file as a whole
+	// cannot be read, such as a CSV header naming unknown columns.
+	InvalidImportFile = New(Invalid, "invalid_import_file", "import file cannot be read")
+	// InvalidRecord is wrapped with the reason one record of an import file
+	// cannot be read; the other records are still imported.
+	InvalidRecord = New(Invalid, "invalid_record", "record cannot be read")
+	// DeletedISBN is returned when importing a record whose ISBN belongs to
+	// a deleted book, which has to be restored to be updated.
+	DeletedISBN = New(Conflict, "deleted_isbn", "a deleted book has this isbn")
+)
+
+// Errors about holds.
+var (
+	// CopiesAvailable is returned when placing a hold on a book that can be
+	// borrowed right away.
+	CopiesAvailable = New(Conflict, "copies_available", "copies of this book are available to borrow")
+	HoldExists      = New(Conflict, "hold_exists", "a hold for this book exists")
+	// HoldNotActive is returned when cancelling or moving a hold that was
+	// already fulfilled, cancelled or expired, or moving one that is ready.
+	HoldNotActive = New(Conflict, "hold_not_active", "hold is no longer active")
+)
+
+// Errors about fines.
+var (
+	// FinesOutstanding is returned when a user owes more than the fine policy
+	// lets them while borrowing.
+	FinesOutstanding = New(Forbidden, "fines_outstanding", "outstanding fines exceed the borrowing limit")
+	// FineSettled is returned when paying or waiving a fine with nothing left
+	// to pay.
+	FineSettled = New(Conflict, "fine_settled", "fine is already settled")
+	Overpayment = New(Invalid, "overpayment", "payment exceeds the outstanding amount")
+)
@@ -0,0 +1,65 @@
+// Package errs defines the typed errors of the library. Every error has a kind,
+// which decides how it is reported to clients, and a stable code they can
+// switch on; the service layer returns them without knowing about HTTP.
+package errs
+
+import "errors"
+
+// Kind classifies what went wrong, independent of any transport.
+type Kind int
+
+const (
+	// Internal errors are unexpected failures such as a lost database
+	// connection. Their details are never shown to clients.
+	Internal Kind = iota
+	// Invalid errors reject malformed or nonsensical input.
+	Invalid
+	// Unauthenticated errors mean the caller could not be identified.
+	Unauthenticated
+	// Forbidden errors mean the caller may not do what they asked.
+	Forbidden
+	NotFound
+	// Conflict errors refuse a request the current state does not allow.
+	Conflict
+	// Stale errors refuse an update based on an outdated version.
+	Stale
+	// Unconditional errors refuse an update that names no version.
+	Unconditional
+	// TooLarge errors refuse input over a size limit.
+	TooLarge
+)
+
+// Error is a domain error. Message is a short summary that is the same for
+// every occurrence; specifics are added by wrapping it, e.g. with fmt.Errorf
+// and %w.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+}
+
+func New(kind Kind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// As returns the first domain error in err's chain, or nil when there is none.
+func As(err error) *Error {
+	var target *Error
+	if errors.As(err, &target) {
+		return target
+	}
+	return nil
+}
+
+// KindOf returns the kind of the domain error in err's chain, Internal when
+// there is none.
+func KindOf(err error) Kind {
+	if e := As(err); e != nil {
+		return e.Kind
+	}
+	return Internal
+}
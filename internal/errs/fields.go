@@ -0,0 +1,28 @@
+package errs
+
+import "strings"
+
+// FieldError is one reason a field of a request was rejected. Field is the
+// JSON path of the field, e.g. "authors[1]", and Rule the name of the
+// validation rule it broke.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Fields lists every field a request failed validation on. It is a
+// ValidationFailed error.
+type Fields []FieldError
+
+func (f Fields) Error() string {
+	reasons := make([]string, 0, len(f))
+	for _, field := range f {
+		reasons = append(reasons, field.Field+" "+field.Message)
+	}
+	return ValidationFailed.Message + ": " + strings.Join(reasons, "; ")
+}
+
+func (f Fields) Unwrap() error {
+	return ValidationFailed
+}
@@ -0,0 +1,257 @@
+// Package export streams books, users and loans out of the library as CSV,
+// NDJSON or MARCXML, for the HTTP API and the CLI alike. Records are read and
+// written a batch at a time, so exports of any size run in constant memory.
+package export
+
+import (
+	"bufio"
+	"context"
+	"eLibrary/internal/api"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/interchange"
+	"eLibrary/internal/repository"
+	"eLibrary/internal/service"
+	"eLibrary/marc"
+	"eLibrary/model"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Kind is what is exported.
+type Kind string
+
+const (
+	Books Kind = "books"
+	Users Kind = "users"
+	Loans Kind = "loans"
+)
+
+func ParseKind(name string) (Kind, error) {
+	switch kind := Kind(name); kind {
+	case Books, Users, Loans:
+		return kind, nil
+	}
+	return "", fmt.Errorf("%w: cannot export %q, use books, users or loans", errs.InvalidParameter, name)
+}
+
+// Check reports whether kind can be exported in format. Only books have a
+// MARC form.
+func Check(kind Kind, format interchange.Format) error {
+	switch {
+	case format == interchange.CSV || format == interchange.NDJSON:
+		return nil
+	case format == interchange.MARCXML && kind == Books:
+		return nil
+	}
+	return fmt.Errorf("%w: %s cannot be exported as %s", errs.UnsupportedFormat, kind, format)
+}
+
+// ContentType is the media type of an export in format.
+func ContentType(format interchange.Format) string {
+	switch format {
+	case interchange.NDJSON:
+		return "application/x-ndjson"
+	case interchange.MARCXML:
+		return "application/marcxml+xml"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Filename is the name an export of kind in format is offered for download
+// under, e.g. "books-2024-05-01.csv".
+func Filename(kind Kind, format interchange.Format, now time.Time) string {
+	extension := "csv"
+	switch format {
+	case interchange.NDJSON:
+		extension = "ndjson"
+	case interchange.MARCXML:
+		extension = "xml"
+	}
+	return fmt.Sprintf("%s-%s.%s", kind, now.Format(time.DateOnly), extension)
+}
+
+// ParseFilter reads the bounds and returned flag of an export. The bounds are
+// RFC 3339 times or dates; to given as a date includes that whole day. Empty
+// values do not filter.
+func ParseFilter(from string, to string, returned string) (filter repository.ExportFilter, err error) {
+	if from != "" {
+		if filter.From, _, err = parseTime(from); err != nil {
+			return filter, fmt.Errorf("%w: from: %v", errs.InvalidParameter, err)
+		}
+	}
+	if to != "" {
+		var date bool
+		if filter.To, date, err = parseTime(to); err != nil {
+			return filter, fmt.Errorf("%w: to: %v", errs.InvalidParameter, err)
+		} else if date {
+			filter.To = filter.To.AddDate(0, 0, 1)
+		}
+	}
+	if returned != "" {
+		parsed, parseErr := strconv.ParseBool(returned)
+		if parseErr != nil {
+			return filter, fmt.Errorf("%w: returned: %v", errs.InvalidParameter, parseErr)
+		}
+		filter.IsReturned = &parsed
+	}
+	return filter, nil
+}
+
+// parseTime reads a date or an RFC 3339 time, and reports whether it was a
+// date.
+func parseTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, false, fmt.Errorf("must be a date or an RFC 3339 time, not %q", value)
+	}
+	return t, false, nil
+}
+
+// Write writes the records of kind matching filter to w in format, which Check
+// must allow. Each batch is flushed to w as soon as it is written. On error it
+// stops, leaving what was written so far.
+func Write(ctx context.Context, svc *service.Service, kind Kind, format interchange.Format, filter repository.ExportFilter, w io.Writer) error {
+	if err := Check(kind, format); err != nil {
+		return err
+	}
+	switch kind {
+	case Books:
+		return write(w, format, bookTable, func(fn func([]model.BookDetail) error) error {
+			return svc.ExportBooks(ctx, filter, fn)
+		})
+	case Users:
+		return write(w, format, userTable, func(fn func([]model.User) error) error {
+			return svc.ExportUsers(ctx, filter, fn)
+		})
+	case Loans:
+		return write(w, format, loanTable, func(fn func([]model.LoanDetail) error) error {
+			return svc.ExportLoans(ctx, filter, fn)
+		})
+	}
+	return fmt.Errorf("%w: cannot export %q", errs.InvalidParameter, kind)
+}
+
+// table describes how records of one kind are written: as CSV rows under
+// header, in the JSON the HTTP API responds with, and as MARC records when
+// they have a MARC form.
+type table[T any] struct {
+	header []string
+	row    func(T) []string
+	json   func(T) interface{}
+	marc   func(T) marc.Record
+}
+
+// bookTable writes books with the columns they are imported from, after
+// their id.
+var bookTable = table[model.BookDetail]{
+	header: append([]string{"id"}, interchange.BookColumns...),
+	row: func(book model.BookDetail) []string {
+		return append([]string{formatID(book.ID)}, interchange.BookRow(book)...)
+	},
+	json: func(book model.BookDetail) interface{} { return api.NewBook(book) },
+	marc: interchange.BookRecord,
+}
+
+var userTable = table[model.User]{
+	header: []string{"id", "username", "first_name", "last_name", "email", "role", "category", "created_at"},
+	row: func(user model.User) []string {
+		return []string{formatID(user.ID), user.Username, user.FirstName, user.LastName, user.Email,
+			string(user.Role), user.Category, formatTime(user.CreatedAt)}
+	},
+	json: func(user model.User) interface{} { return api.NewUser(user) },
+}
+
+var loanTable = table[model.LoanDetail]{
+	header: []string{"id", "book_id", "title", "isbn", "copy_id", "file_id", "user_id", "name_of_borrower",
+		"loan_date", "return_date", "is_returned", "returned_at", "renewals", "overdue"},
+	row: func(loan model.LoanDetail) []string {
+		returnedAt := ""
+		if loan.ReturnedAt != nil {
+			returnedAt = formatTime(*loan.ReturnedAt)
+		}
+		return []string{formatID(loan.ID), formatID(loan.BookID), loan.BookDetail.Title, loan.BookDetail.ISBN,
+			formatOptionalID(loan.CopyID), formatOptionalID(loan.FileID), formatID(loan.UserID), loan.NameOfBorrower,
+			formatTime(loan.LoanDate), formatTime(loan.ReturnDate), strconv.FormatBool(loan.IsReturned), returnedAt,
+			strconv.Itoa(loan.Renewals), strconv.FormatBool(loan.Overdue)}
+	},
+	json: func(loan model.LoanDetail) interface{} { return api.NewLoan(loan) },
+}
+
+// encoder writes records of one kind in one format. flush pushes out what was
+// encoded so far and finish ends the output.
+type encoder[T any] struct {
+	encode func(T) error
+	flush  func() error
+	finish func() error
+}
+
+func newEncoder[T any](w io.Writer, format interchange.Format, t table[T]) (e encoder[T], err error) {
+	switch {
+	case format == interchange.CSV:
+		rows := csv.NewWriter(w)
+		flush := func() error {
+			rows.Flush()
+			return rows.Error()
+		}
+		return encoder[T]{encode: func(record T) error { return rows.Write(t.row(record)) }, flush: flush, finish: flush},
+			rows.Write(t.header)
+	case format == interchange.NDJSON:
+		lines := json.NewEncoder(w)
+		none := func() error { return nil }
+		return encoder[T]{encode: func(record T) error { return lines.Encode(t.json(record)) }, flush: none, finish: none}, nil
+	case format == interchange.MARCXML && t.marc != nil:
+		records := marc.NewXMLWriter(w)
+		return encoder[T]{encode: func(record T) error { return records.Write(t.marc(record)) }, flush: records.Flush, finish: records.Close}, nil
+	}
+	return e, fmt.Errorf("%w %q", errs.UnsupportedFormat, format)
+}
+
+func write[T any](w io.Writer, format interchange.Format, t table[T], each func(func([]T) error) error) error {
+	out := bufio.NewWriter(w)
+	e, err := newEncoder(out, format, t)
+	if err != nil {
+		return err
+	}
+
+	err = each(func(batch []T) error {
+		for _, record := range batch {
+			if err := e.encode(record); err != nil {
+				return err
+			}
+		}
+		if err := e.flush(); err != nil {
+			return err
+		}
+		return out.Flush()
+	})
+	if err != nil {
+		return err
+	}
+	if err = e.finish(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func formatOptionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return formatID(*id)
+}
+
+// formatTime writes times like api.Time does.
+func formatTime(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/auth"
+	"eLibrary/model"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func (h *Handler) Login(c *gin.Context) {
+	var loginRequest model.LoginRequest
+	if err := bind(c, &loginRequest); err != nil {
+		abort(c, err, nil)
+	} else if user, err := h.service.Login(c.Request.Context(), loginRequest.Username, loginRequest.Password); err != nil {
+		abort(c, err, nil)
+	} else if tokens, err := h.tokens.Issue(auth.Principal{UserID: user.ID, Role: user.Role}); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"tokens": tokens, "user": api.NewUser(user)})
+	}
+}
+
+// Refresh exchanges a refresh token for a new token pair, as long as its user
+// has not been deleted in the meantime. The new tokens carry the user's
+// current role.
+func (h *Handler) Refresh(c *gin.Context) {
+	var refreshRequest model.RefreshRequest
+	if err := bind(c, &refreshRequest); err != nil {
+		abort(c, err, nil)
+	} else if principal, err := h.tokens.Verify(refreshRequest.RefreshToken, auth.RefreshToken); err != nil {
+		abort(c, err, nil)
+	} else if user, err := h.service.GetUser(c.Request.Context(), principal.UserID); err != nil {
+		abort(c, err, auth.ErrInvalidToken)
+	} else if tokens, err := h.tokens.Issue(auth.Principal{UserID: user.ID, Role: user.Role}); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"tokens": tokens, "user": api.NewUser(user)})
+	}
+}
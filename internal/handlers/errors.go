@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"eLibrary/internal/errs"
+	"eLibrary/internal/problem"
+	"eLibrary/internal/repository"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+)
+
+// abort responds with the problem err describes. A repository.ErrNotFound is
+// reported as missing, the resource the request is about, since repositories
+// do not know what they were asked for.
+func abort(c *gin.Context, err error, missing *errs.Error) {
+	if missing != nil && errors.Is(err, repository.ErrNotFound) {
+		err = missing
+	}
+	problem.Abort(c, err)
+}
+
+// invalidID rejects a request whose id path parameter is not a positive
+// integer.
+func invalidID(c *gin.Context, resource string) {
+	problem.Abort(c, fmt.Errorf("%w: invalid %s id %q", errs.InvalidParameter, resource, c.Param("id")))
+}
+
+// invalidQuery wraps the reason the query parameters of a request were
+// rejected.
+func invalidQuery(err error) error {
+	return fmt.Errorf("%w: %v", errs.InvalidParameter, err)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/middleware"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ListEvents pages through the event log, filtered by ?type=, ?user_id= and
+// ?loan_id=. Patrons only ever see the events about themselves.
+func (h *Handler) ListEvents(c *gin.Context) {
+	if filter, err := parseEventFilter(c); err != nil {
+		abort(c, invalidQuery(err), nil)
+	} else if !auth.Can(middleware.Role(c), auth.ViewAllLoans) && filter.UserID != 0 && filter.UserID != middleware.UserID(c) {
+		abort(c, errs.NotAllowed, nil)
+	} else if page, err := h.service.ListEvents(c.Request.Context(), ownEvents(c, filter)); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, pageResponse(page, api.NewEvent))
+	}
+}
+
+// ownEvents restricts filter to the caller's events unless they may view
+// everyone's loans.
+func ownEvents(c *gin.Context, filter repository.EventFilter) repository.EventFilter {
+	if !auth.Can(middleware.Role(c), auth.ViewAllLoans) {
+		filter.UserID = middleware.UserID(c)
+	}
+	return filter
+}
+
+func parseEventFilter(c *gin.Context) (filter repository.EventFilter, err error) {
+	filter.Type = model.EventType(c.Query("type"))
+	if value := c.Query("user_id"); value != "" {
+		if filter.UserID, err = parseID(value); err != nil {
+			return filter, fmt.Errorf("user_id: %w", err)
+		}
+	}
+	if value := c.Query("loan_id"); value != "" {
+		if filter.LoanID, err = parseID(value); err != nil {
+			return filter, fmt.Errorf("loan_id: %w", err)
+		}
+	}
+	filter.ListOptions, err = parseListOptions(c)
+	return filter, err
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/middleware"
+	"eLibrary/internal/service"
+	"eLibrary/model"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ListFiles returns the e-book files of a book with their free licenses.
+func (h *Handler) ListFiles(c *gin.Context) {
+	if bookID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if files, err := h.service.ListFiles(c.Request.Context(), bookID); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"files": api.Map(files, api.NewFile)})
+	}
+}
+
+// UploadFile adds or replaces the EPUB or PDF file of a book. The multipart
+// form carries the content as "file" and the number of concurrent licenses
+// as "licenses".
+func (h *Handler) UploadFile(c *gin.Context) {
+	var upload model.FileUpload
+	h.limitUpload(c)
+	if bookID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if err := bindForm(c, &upload); err != nil {
+		abort(c, err, nil)
+	} else if header, err := c.FormFile("file"); err != nil {
+		abort(c, errs.Fields{{Field: "file", Rule: "required", Message: "is required"}}, nil)
+	} else if content, err := header.Open(); err != nil {
+		abort(c, err, nil)
+	} else {
+		defer content.Close()
+		if file, err := h.service.UploadFile(c.Request.Context(), bookID, header.Filename, content, upload.Licenses); err != nil {
+			abort(c, err, errs.BookNotFound)
+		} else {
+			c.JSON(http.StatusOK, gin.H{"file": api.NewFile(file)})
+		}
+	}
+}
+
+// BorrowFile lends a license of an e-book file to the caller.
+func (h *Handler) BorrowFile(c *gin.Context) {
+	if fileID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "file")
+	} else if loan, err := h.service.BorrowFile(c.Request.Context(), middleware.UserID(c), fileID); err != nil {
+		abort(c, err, errs.FileNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"loan": api.NewLoan(loan)})
+	}
+}
+
+// CreateDownloadLink hands the borrower of a digital loan a signed link to
+// its file.
+func (h *Handler) CreateDownloadLink(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "loan")
+	} else if link, err := h.service.DownloadLink(c.Request.Context(), id, middleware.UserID(c)); err != nil {
+		abort(c, err, errs.LoanNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"link": api.DownloadLink{
+			URL:       downloadURL(link),
+			ExpiresAt: api.NewTime(link.ExpiresAt),
+		}})
+	}
+}
+
+// Download serves the file of a digital loan to anyone holding a valid link
+// from CreateDownloadLink; the link itself is the credential.
+func (h *Handler) Download(c *gin.Context) {
+	if link, err := parseDownloadLink(c); err != nil {
+		abort(c, err, nil)
+	} else if file, content, err := h.service.OpenDownload(c.Request.Context(), link); err != nil {
+		abort(c, err, errs.FileNotFound)
+	} else {
+		defer content.Close()
+		c.Header("Cache-Control", "private, no-store")
+		c.DataFromReader(http.StatusOK, file.Size, file.Format.ContentType(), content, map[string]string{
+			"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}),
+		})
+	}
+}
+
+// parseDownloadLink reads a link made by downloadURL back. Mangled links are
+// as invalid as tampered ones.
+func parseDownloadLink(c *gin.Context) (link service.DownloadLink, err error) {
+	if link.LoanID, err = parseID(c.Param("id")); err != nil {
+		return link, errs.InvalidDownloadLink
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		return link, errs.InvalidDownloadLink
+	}
+	link.ExpiresAt = time.Unix(expires, 0)
+	link.Signature = c.Query("signature")
+	return link, nil
+}
+
+// downloadURL is the path of the Download route for link.
+func downloadURL(link service.DownloadLink) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(link.ExpiresAt.Unix(), 10))
+	query.Set("signature", link.Signature)
+	return fmt.Sprintf("/elibrary/v1/loans/%d/download?%s", link.LoanID, query.Encode())
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/middleware"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ListFines lists fines, filtered by ?user_id= and ?status=. Patrons only ever
+// see their own fines.
+func (h *Handler) ListFines(c *gin.Context) {
+	if filter, err := parseFineFilter(c); err != nil {
+		abort(c, invalidQuery(err), nil)
+	} else if !auth.Can(middleware.Role(c), auth.ManageFines) && filter.UserID != 0 && filter.UserID != middleware.UserID(c) {
+		abort(c, errs.NotAllowed, nil)
+	} else if page, err := h.service.ListFines(c.Request.Context(), ownFines(c, filter)); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, pageResponse(page, api.NewFine))
+	}
+}
+
+// GetFineBalance returns what a user owes in fines and whether that stops
+// them borrowing.
+func (h *Handler) GetFineBalance(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "user")
+	} else if balance, blocked, err := h.service.FineBalance(c.Request.Context(), id); err != nil {
+		abort(c, err, errs.UserNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"user_id": id, "balance": balance, "borrowing_blocked": blocked})
+	}
+}
+
+// PayFine records a payment towards a fine taken by staff at the desk.
+func (h *Handler) PayFine(c *gin.Context) {
+	var payment model.FinePayment
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "fine")
+	} else if err := bind(c, &payment); err != nil {
+		abort(c, err, nil)
+	} else if fine, err := h.service.PayFine(c.Request.Context(), id, payment.Amount, middleware.UserID(c)); err != nil {
+		abort(c, err, errs.FineNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"fine": api.NewFine(fine)})
+	}
+}
+
+// WaiveFine forgives what is left of a fine.
+func (h *Handler) WaiveFine(c *gin.Context) {
+	var waiver model.FineWaiver
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "fine")
+	} else if err := bind(c, &waiver); err != nil {
+		abort(c, err, nil)
+	} else if fine, err := h.service.WaiveFine(c.Request.Context(), id, waiver.Reason, middleware.UserID(c)); err != nil {
+		abort(c, err, errs.FineNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"fine": api.NewFine(fine)})
+	}
+}
+
+// ownFines restricts filter to the caller's fines unless they may manage
+// everyone's.
+func ownFines(c *gin.Context, filter repository.FineFilter) repository.FineFilter {
+	if !auth.Can(middleware.Role(c), auth.ManageFines) {
+		filter.UserID = middleware.UserID(c)
+	}
+	return filter
+}
+
+func parseFineFilter(c *gin.Context) (filter repository.FineFilter, err error) {
+	if value := c.Query("user_id"); value != "" {
+		if filter.UserID, err = parseID(value); err != nil {
+			return filter, fmt.Errorf("user_id: %w", err)
+		}
+	}
+	if value := c.Query("status"); value != "" {
+		if filter.Status = model.FineStatus(value); !filter.Status.IsValid() {
+			return filter, fmt.Errorf("status: unknown fine status %q", value)
+		}
+	}
+	filter.ListOptions, err = parseListOptions(c)
+	return filter, err
+}
@@ -1,98 +1,168 @@
 package handlers
 
 import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/middleware"
 	"eLibrary/internal/service"
 	"eLibrary/model"
-	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
-	"gorm.io/gorm"
 	"net/http"
 	"regexp"
+	"strconv"
 )
 
-var validate = validator.New()
+type Handler struct {
+	service *service.Service
+	tokens  *auth.Issuer
+}
+
+func New(service *service.Service, tokens *auth.Issuer) *Handler {
+	return &Handler{service: service, tokens: tokens}
+}
 
-func GetBook(c *gin.Context) {
+func (h *Handler) GetBook(c *gin.Context) {
 	title := c.Param("title")
 	if !isValidBookTitle(title) {
-		c.JSON(http.StatusBadRequest, gin.H{"bad request": "invalid book title provided"})
-	} else if book, err := service.GetBook(title); err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusNotFound, gin.H{"bad request": "book not found"})
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to retrieve net worth"})
+		abort(c, errs.InvalidTitle, nil)
+	} else if books, err := h.service.GetBooks(c.Request.Context(), title); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"books": api.Map(books, api.NewBook)})
+	}
+}
+
+func (h *Handler) GetBookByID(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if book, err := h.service.GetBookByID(c.Request.Context(), id); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		setETag(c, book.Version)
+		c.JSON(http.StatusOK, gin.H{"book": api.NewBook(book)})
+	}
+}
+
+func (h *Handler) GetBookByISBN(c *gin.Context) {
+	if book, err := h.service.GetBookByISBN(c.Request.Context(), c.Param("isbn")); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"book": api.NewBook(book)})
+	}
+}
+
+func (h *Handler) GetWork(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "work")
+	} else if work, err := h.service.GetWork(c.Request.Context(), id); err != nil {
+		abort(c, err, errs.WorkNotFound)
 	} else {
-		c.JSON(http.StatusOK, gin.H{"book": book})
+		c.JSON(http.StatusOK, gin.H{"work": api.NewWork(work)})
 	}
 }
 
-func BorrowBook(c *gin.Context) {
+func (h *Handler) BorrowBook(c *gin.Context) {
 	var loanRequest model.LoanRequest
-	if err := c.ShouldBindJSON(&loanRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"bad request": "invalid request body", "details:": err.Error()})
-	} else if err := validate.Struct(loanRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"bad request": "validation failed", "details:": err.Error()})
-	} else if loan, err := service.BorrowBook(loanRequest); err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusConflict, gin.H{"bad request": "there are no more available books to borrow"})
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to borrow book", "details:": err.Error()})
+	if err := bind(c, &loanRequest); err != nil {
+		abort(c, err, nil)
+	} else if loan, err := h.service.BorrowBook(c.Request.Context(), middleware.UserID(c), loanRequest.Title); err != nil {
+		abort(c, err, errs.BookNotFound)
 	} else {
-		c.JSON(http.StatusOK, gin.H{"loan": loan})
+		c.JSON(http.StatusOK, gin.H{"loan": api.NewLoan(loan)})
 	}
 }
 
-func ExtendBook(c *gin.Context) {
+func (h *Handler) ExtendBook(c *gin.Context) {
 	var loanRequest model.LoanRequest
-	if err := c.ShouldBindJSON(&loanRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"bad request": "invalid request body", "details:": err.Error()})
-	} else if err := validate.Struct(loanRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"bad request": "validation failed", "details:": err.Error()})
+	if err := bind(c, &loanRequest); err != nil {
+		abort(c, err, nil)
 	} else if !isValidBookTitle(loanRequest.Title) {
-		c.JSON(http.StatusBadRequest, gin.H{"bad request": "invalid book title provided"})
-	} else if loan, err := service.ExtendBook(loanRequest.UserId, loanRequest.Title); err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusConflict, gin.H{"bad request": "loan not found", "details:": err.Error()})
+		abort(c, errs.InvalidTitle, nil)
+	} else if loan, err := h.service.ExtendBook(c.Request.Context(), middleware.UserID(c), loanRequest.Title); err != nil {
+		abort(c, err, errs.LoanNotFound)
 	} else {
-		c.JSON(http.StatusOK, gin.H{"loan": loan})
+		c.JSON(http.StatusOK, gin.H{"loan": api.NewLoan(loan)})
 	}
 }
 
-func ReturnBook(c *gin.Context) {
+func (h *Handler) ReturnBook(c *gin.Context) {
 	var loanRequest model.LoanRequest
-	if err := c.ShouldBindJSON(&loanRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"bad request": "invalid request body", "details:": err.Error()})
-	} else if err := validate.Struct(loanRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"bad request": "validation failed", "details:": err.Error()})
+	if err := bind(c, &loanRequest); err != nil {
+		abort(c, err, nil)
 	} else if !isValidBookTitle(loanRequest.Title) {
-		c.JSON(http.StatusBadRequest, gin.H{"bad request": "invalid book title provided"})
-	} else if loan, err := service.ReturnBook(loanRequest.UserId, loanRequest.Title); err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusConflict, gin.H{"bad request": "loan not found", "details:": err.Error()})
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"internal server error": "something went wrong", "details:": err.Error()})
+		abort(c, errs.InvalidTitle, nil)
+	} else if loan, err := h.service.ReturnBook(c.Request.Context(), middleware.UserID(c), loanRequest.Title); err != nil {
+		abort(c, err, errs.LoanNotFound)
 	} else {
-		c.JSON(http.StatusOK, gin.H{"loan": loan})
+		c.JSON(http.StatusOK, gin.H{"loan": api.NewLoan(loan)})
 	}
 }
 
-func CreateBook(c *gin.Context) {
-	bookDetail := model.BookDetail{}
-	if err := c.ShouldBindJSON(&bookDetail); err != nil {
+func (h *Handler) CreateBook(c *gin.Context) {
+	var bookRequest model.BookRequest
+	if err := bind(c, &bookRequest); err != nil {
+		abort(c, err, nil)
+	} else if book, err := h.service.CreateBook(c.Request.Context(), bookRequest); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"book": api.NewBook(book)})
 	}
-	book, err := service.CreateBook(bookDetail)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to create book", "details:": err.Error()})
+}
+
+func (h *Handler) CreateUser(c *gin.Context) {
+	var userRequest model.UserRequest
+	if err := bind(c, &userRequest); err != nil {
+		abort(c, err, nil)
+	} else if userRequest.Role != "" && userRequest.Role != model.RolePatron && !auth.Can(middleware.Role(c), auth.AssignRoles) {
+		abort(c, errs.NotAllowed, nil)
+	} else if user, err := h.service.CreateUser(c.Request.Context(), userRequest); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"user": api.NewUser(user)})
 	}
-	c.JSON(http.StatusOK, gin.H{"book": book})
 }
 
-func CreateUser(c *gin.Context) {
-	user := model.User{}
-	if err := c.ShouldBindJSON(&user); err != nil {
+func (h *Handler) ListCopies(c *gin.Context) {
+	if bookID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if copies, err := h.service.ListCopies(c.Request.Context(), bookID); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"copies": api.Map(copies, api.NewCopy)})
 	}
-	user, err := service.CreateUser(user.FirstName, user.LastName, user.Username, user.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to create user", "details:": err.Error()})
+}
+
+func (h *Handler) AddCopy(c *gin.Context) {
+	var copyRequest model.CopyRequest
+	if bookID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if err := bind(c, &copyRequest); err != nil {
+		abort(c, err, nil)
+	} else if bookCopy, err := h.service.AddCopy(c.Request.Context(), bookID, copyRequest); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"copy": api.NewCopy(bookCopy)})
+	}
+}
+
+func (h *Handler) UpdateCopy(c *gin.Context) {
+	var copyRequest model.CopyRequest
+	if err := bind(c, &copyRequest); err != nil {
+		abort(c, err, nil)
+	} else if bookCopy, err := h.service.UpdateCopy(c.Request.Context(), c.Param("barcode"), copyRequest); err != nil {
+		abort(c, err, errs.CopyNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"copy": api.NewCopy(bookCopy)})
+	}
+}
+
+func parseID(param string) (uint, error) {
+	id, err := strconv.ParseUint(param, 10, 64)
+	if err == nil && id == 0 {
+		err = strconv.ErrRange
 	}
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	return uint(id), err
 }
 
 func isValidBookTitle(title string) bool {
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"eLibrary/database"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+const pingTimeout = 2 * time.Second
+
+var errDatabaseNotInitialized = errors.New("database not initialized")
+
+type HealthHandler struct {
+	db *gorm.DB
+}
+
+func NewHealthHandler(db *gorm.DB) *HealthHandler {
+	return &HealthHandler{db: db}
+}
+
+// Healthz reports whether the process is up and can still reach the database.
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	if _, err := h.pingDatabase(c.Request.Context()); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "database": err.Error()})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readyz reports whether the app is ready to serve traffic: the database
+// answers pings and every schema has been migrated. Connection pool stats are
+// included so operators can spot exhaustion.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ready := true
+	report := gin.H{}
+
+	sqlDB, err := h.pingDatabase(c.Request.Context())
+	if err != nil {
+		ready = false
+		report["database"] = gin.H{"status": "unreachable", "error": err.Error()}
+	} else {
+		report["database"] = gin.H{"status": "ok", "pool": poolStats(sqlDB.Stats())}
+
+		pending, err := database.PendingMigrations(h.db)
+		if err != nil {
+			ready = false
+			report["migrations"] = gin.H{"status": "unknown", "error": err.Error()}
+		} else if len(pending) > 0 {
+			ready = false
+			report["migrations"] = gin.H{"status": "pending", "pending": pending}
+		} else {
+			report["migrations"] = gin.H{"status": "complete"}
+		}
+	}
+
+	if ready {
+		report["status"] = "ready"
+		c.JSON(http.StatusOK, report)
+	} else {
+		report["status"] = "not ready"
+		c.JSON(http.StatusServiceUnavailable, report)
+	}
+}
+
+func (h *HealthHandler) pingDatabase(ctx context.Context) (*sql.DB, error) {
+	if h.db == nil {
+		return nil, errDatabaseNotInitialized
+	}
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if err = sqlDB.PingContext(ctx); err != nil {
+		return nil, err
+	}
+	return sqlDB, nil
+}
+
+func poolStats(stats sql.DBStats) gin.H {
+	return gin.H{
+		"max_open_connections": stats.MaxOpenConnections,
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"wait_count":           stats.WaitCount,
+		"wait_duration":        stats.WaitDuration.String(),
+		"max_idle_closed":      stats.MaxIdleClosed,
+		"max_lifetime_closed":  stats.MaxLifetimeClosed,
+	}
+}
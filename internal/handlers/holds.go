@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/middleware"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// PlaceHold queues the caller for the next copy of a book.
+func (h *Handler) PlaceHold(c *gin.Context) {
+	if bookID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if hold, err := h.service.PlaceHold(c.Request.Context(), middleware.UserID(c), bookID); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"hold": api.NewHold(hold)})
+	}
+}
+
+// ListBookHolds returns the waiting holds on a book in the order they are
+// served.
+func (h *Handler) ListBookHolds(c *gin.Context) {
+	if bookID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if holds, err := h.service.HoldQueue(c.Request.Context(), bookID); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"holds": api.Map(holds, api.NewHold)})
+	}
+}
+
+// ListHolds lists holds, filtered by ?user_id=, ?book_id= and ?status=.
+// Patrons only ever see their own holds.
+func (h *Handler) ListHolds(c *gin.Context) {
+	if filter, err := parseHoldFilter(c); err != nil {
+		abort(c, invalidQuery(err), nil)
+	} else if !auth.Can(middleware.Role(c), auth.ManageHolds) && filter.UserID != 0 && filter.UserID != middleware.UserID(c) {
+		abort(c, errs.NotAllowed, nil)
+	} else if page, err := h.service.ListHolds(c.Request.Context(), ownHolds(c, filter)); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, pageResponse(page, api.NewHold))
+	}
+}
+
+// CancelHold withdraws one of the caller's holds, or anyone's for staff.
+func (h *Handler) CancelHold(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "hold")
+	} else if err := h.authorizeHold(c, id); err != nil {
+		abort(c, err, errs.HoldNotFound)
+	} else if hold, err := h.service.CancelHold(c.Request.Context(), id); err != nil {
+		abort(c, err, errs.HoldNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"hold": api.NewHold(hold)})
+	}
+}
+
+// MoveHold reorders a book's hold queue by moving one waiting hold to
+// "position".
+func (h *Handler) MoveHold(c *gin.Context) {
+	var holdRequest model.HoldRequest
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "hold")
+	} else if err := bind(c, &holdRequest); err != nil {
+		abort(c, err, nil)
+	} else if hold, err := h.service.MoveHold(c.Request.Context(), id, holdRequest.Position); err != nil {
+		abort(c, err, errs.HoldNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"hold": api.NewHold(hold)})
+	}
+}
+
+// authorizeHold checks that the caller owns the hold with id or may manage
+// everyone's holds.
+func (h *Handler) authorizeHold(c *gin.Context, id uint) error {
+	if auth.Can(middleware.Role(c), auth.ManageHolds) {
+		return nil
+	}
+	hold, err := h.service.GetHold(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+	if hold.UserID != middleware.UserID(c) {
+		return errs.NotAllowed
+	}
+	return nil
+}
+
+// ownHolds restricts filter to the caller's holds unless they may manage
+// everyone's.
+func ownHolds(c *gin.Context, filter repository.HoldFilter) repository.HoldFilter {
+	if !auth.Can(middleware.Role(c), auth.ManageHolds) {
+		filter.UserID = middleware.UserID(c)
+	}
+	return filter
+}
+
+func parseHoldFilter(c *gin.Context) (filter repository.HoldFilter, err error) {
+	if value := c.Query("user_id"); value != "" {
+		if filter.UserID, err = parseID(value); err != nil {
+			return filter, fmt.Errorf("user_id: %w", err)
+		}
+	}
+	if value := c.Query("book_id"); value != "" {
+		if filter.BookID, err = parseID(value); err != nil {
+			return filter, fmt.Errorf("book_id: %w", err)
+		}
+	}
+	if value := c.Query("status"); value != "" {
+		if filter.Status = model.HoldStatus(value); !filter.Status.IsValid() {
+			return filter, fmt.Errorf("status: unknown hold status %q", value)
+		}
+	}
+	filter.ListOptions, err = parseListOptions(c)
+	return filter, err
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/export"
+	"eLibrary/internal/interchange"
+	"eLibrary/internal/middleware"
+	"eLibrary/model"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// exportPermissions are what callers need to export each kind of record.
+var exportPermissions = map[export.Kind]auth.Permission{
+	export.Books: auth.ManageCatalog,
+	export.Users: auth.ManageUsers,
+	export.Loans: auth.ViewAllLoans,
+}
+
+// ImportBooks adds or updates the books of a CSV, MARC or MARCXML file sent
+// as "file" of a multipart form, along with an optional "format" and
+// "dry_run". Rows that fail are reported in the response rather than failing
+// the request.
+func (h *Handler) ImportBooks(c *gin.Context) {
+	var form model.ImportForm
+	h.limitUpload(c)
+	if err := bindForm(c, &form); err != nil {
+		abort(c, err, nil)
+	} else if header, err := c.FormFile("file"); err != nil {
+		abort(c, errs.Fields{{Field: "file", Rule: "required", Message: "is required"}}, nil)
+	} else if format, err := interchange.DetectFormat(form.Format, header.Filename); err != nil {
+		abort(c, err, nil)
+	} else if content, err := header.Open(); err != nil {
+		abort(c, err, nil)
+	} else {
+		defer content.Close()
+		if rows, err := interchange.NewReader(format, content); err != nil {
+			abort(c, err, nil)
+		} else if report, err := h.service.ImportBooks(c.Request.Context(), rows, form.DryRun); err != nil {
+			abort(c, err, nil)
+		} else {
+			c.JSON(http.StatusOK, gin.H{"report": api.NewImportReport(report)})
+		}
+	}
+}
+
+// Export streams books, users or loans as a file download in ?format=csv (the
+// default), ndjson or, for books, marcxml. ?from= and ?to= bound when records
+// were created, or loans were lent, and ?returned= picks returned or
+// outstanding loans. Failures after the first batch went out can no longer be
+// reported to the client and cut the download short.
+func (h *Handler) Export(c *gin.Context) {
+	if kind, err := export.ParseKind(c.Param("kind")); err != nil {
+		abort(c, err, nil)
+	} else if !auth.Can(middleware.Role(c), exportPermissions[kind]) {
+		abort(c, errs.NotAllowed, nil)
+	} else if format, err := exportFormat(c); err != nil {
+		abort(c, err, nil)
+	} else if err := export.Check(kind, format); err != nil {
+		abort(c, err, nil)
+	} else if filter, err := export.ParseFilter(c.Query("from"), c.Query("to"), c.Query("returned")); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.Header("Content-Type", export.ContentType(format))
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.Filename(kind, format, time.Now())}))
+		c.Status(http.StatusOK)
+		if err := export.Write(c.Request.Context(), h.service, kind, format, filter, c.Writer); err != nil && !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Disposition")
+			abort(c, err, nil)
+		} else if err != nil {
+			log.WithError(err).WithField("kind", kind).Error("Export failed partway")
+			c.Abort()
+		}
+	}
+}
+
+// exportFormat reads ?format=, which defaults to CSV.
+func exportFormat(c *gin.Context) (interchange.Format, error) {
+	if name := c.Query("format"); name != "" {
+		return interchange.ParseFormat(name)
+	}
+	return interchange.CSV, nil
+}
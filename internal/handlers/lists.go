@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/middleware"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// SearchBooks lists the catalog. ?q= searches title, author and ISBN, ranking
+// the best matches first; ?author= and ?available= filter.
+func (h *Handler) SearchBooks(c *gin.Context) {
+	if search, err := parseBookSearch(c); err != nil {
+		abort(c, invalidQuery(err), nil)
+	} else if page, err := h.service.SearchBooks(c.Request.Context(), search); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, pageResponse(page, api.NewBook))
+	}
+}
+
+// ListUsers lists users, filtered by ?q= against usernames, names and emails
+// and by ?role=.
+func (h *Handler) ListUsers(c *gin.Context) {
+	filter := repository.UserFilter{Query: c.Query("q"), Role: model.Role(c.Query("role"))}
+	var err error
+	if filter.Role != "" && !filter.Role.IsValid() {
+		abort(c, fmt.Errorf("%w: %q", errs.InvalidRole, filter.Role), nil)
+	} else if filter.ListOptions, err = parseListOptions(c); err != nil {
+		abort(c, invalidQuery(err), nil)
+	} else if page, err := h.service.ListUsers(c.Request.Context(), filter); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, pageResponse(page, api.NewUser))
+	}
+}
+
+// ListLoans lists loans, filtered by ?user_id=, ?book_id=, ?returned=,
+// ?status=current|past, ?overdue= and ?due_soon=. Patrons only ever see their
+// own loans.
+func (h *Handler) ListLoans(c *gin.Context) {
+	if filter, err := h.parseLoanFilter(c); err != nil {
+		abort(c, invalidQuery(err), nil)
+	} else if !auth.Can(middleware.Role(c), auth.ViewAllLoans) && filter.UserID != 0 && filter.UserID != middleware.UserID(c) {
+		abort(c, errs.NotAllowed, nil)
+	} else if page, err := h.service.ListLoans(c.Request.Context(), ownLoans(c, filter)); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, pageResponse(page, api.NewLoan))
+	}
+}
+
+// ListUserLoans lists the loans of the user in the path, taking the same
+// filters as ListLoans apart from ?user_id=.
+func (h *Handler) ListUserLoans(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "user")
+	} else {
+		h.listUserLoans(c, id)
+	}
+}
+
+// ListMyLoans lists the caller's own loans, see ListUserLoans.
+func (h *Handler) ListMyLoans(c *gin.Context) {
+	h.listUserLoans(c, middleware.UserID(c))
+}
+
+func (h *Handler) listUserLoans(c *gin.Context, userID uint) {
+	if filter, err := h.parseLoanFilter(c); err != nil {
+		abort(c, invalidQuery(err), nil)
+	} else if page, err := h.service.ListUserLoans(c.Request.Context(), userID, filter); err != nil {
+		abort(c, err, errs.UserNotFound)
+	} else {
+		c.JSON(http.StatusOK, pageResponse(page, api.NewLoan))
+	}
+}
+
+// ownLoans restricts filter to the caller's loans unless they may see
+// everyone's.
+func ownLoans(c *gin.Context, filter repository.LoanFilter) repository.LoanFilter {
+	if !auth.Can(middleware.Role(c), auth.ViewAllLoans) {
+		filter.UserID = middleware.UserID(c)
+	}
+	return filter
+}
+
+// pageResponse is the envelope every collection endpoint responds with; convert
+// maps each item to its API representation.
+func pageResponse[T any, U any](page repository.Page[T], convert func(T) U) gin.H {
+	return gin.H{"data": api.Map(page.Items, convert), "next_cursor": page.NextCursor, "total": page.Total}
+}
+
+// parseListOptions reads ?cursor=, ?limit= and ?sort= (e.g. "title" or
+// "-created_at" for descending order).
+func parseListOptions(c *gin.Context) (options repository.ListOptions, err error) {
+	options = repository.ListOptions{
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+		Limit:  repository.DefaultPageSize,
+	}
+	if value := c.Query("limit"); value != "" {
+		if options.Limit, err = strconv.Atoi(value); err != nil || options.Limit < 1 || options.Limit > repository.MaxPageSize {
+			return options, fmt.Errorf("limit must be between 1 and %d", repository.MaxPageSize)
+		}
+	}
+	return options, nil
+}
+
+func parseBookSearch(c *gin.Context) (search repository.BookSearch, err error) {
+	search = repository.BookSearch{
+		Query:  c.Query("q"),
+		Author: c.Query("author"),
+	}
+	if search.Available, err = parseOptionalBool(c, "available"); err != nil {
+		return search, err
+	}
+	search.ListOptions, err = parseListOptions(c)
+	return search, err
+}
+
+func (h *Handler) parseLoanFilter(c *gin.Context) (filter repository.LoanFilter, err error) {
+	if value := c.Query("user_id"); value != "" {
+		if filter.UserID, err = parseID(value); err != nil {
+			return filter, fmt.Errorf("user_id: %w", err)
+		}
+	}
+	if value := c.Query("book_id"); value != "" {
+		if filter.BookID, err = parseID(value); err != nil {
+			return filter, fmt.Errorf("book_id: %w", err)
+		}
+	}
+	if filter.IsReturned, err = parseOptionalBool(c, "returned"); err != nil {
+		return filter, err
+	}
+	if filter.Overdue, err = parseOptionalBool(c, "overdue"); err != nil {
+		return filter, err
+	}
+	if dueSoon, dueErr := parseOptionalBool(c, "due_soon"); dueErr != nil {
+		return filter, dueErr
+	} else if dueSoon != nil && *dueSoon {
+		filter.DueWithin = h.service.DueSoonWindow()
+	}
+	switch status := c.Query("status"); status {
+	case "":
+	case "current", "past":
+		returned := status == "past"
+		filter.IsReturned = &returned
+	default:
+		return filter, fmt.Errorf("status: must be current or past, not %q", status)
+	}
+	filter.ListOptions, err = parseListOptions(c)
+	return filter, err
+}
+
+func parseOptionalBool(c *gin.Context, key string) (*bool, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return &parsed, nil
+}
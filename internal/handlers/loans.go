@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetLoan returns one of the caller's loans, or anyone's for staff, with its
+// renewal history.
+func (h *Handler) GetLoan(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "loan")
+	} else if loan, err := h.service.GetLoan(c.Request.Context(), id); err != nil {
+		abort(c, err, errs.LoanNotFound)
+	} else if loan.UserID != middleware.UserID(c) && !auth.Can(middleware.Role(c), auth.ViewAllLoans) {
+		abort(c, errs.NotAllowed, nil)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"loan": api.NewLoan(loan)})
+	}
+}
+
+// RenewLoan renews one of the caller's loans, or anyone's for staff renewing
+// on a borrower's behalf. The renewal is recorded as performed by the caller.
+func (h *Handler) RenewLoan(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "loan")
+	} else if err := h.authorizeLoan(c, id); err != nil {
+		abort(c, err, errs.LoanNotFound)
+	} else if loan, err := h.service.RenewLoan(c.Request.Context(), id, middleware.UserID(c)); err != nil {
+		abort(c, err, errs.LoanNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"loan": api.NewLoan(loan)})
+	}
+}
+
+// authorizeLoan checks that the caller borrowed the loan with id or may
+// manage everyone's loans.
+func (h *Handler) authorizeLoan(c *gin.Context, id uint) error {
+	if auth.Can(middleware.Role(c), auth.ManageLoans) {
+		return nil
+	}
+	loan, err := h.service.GetLoan(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+	if loan.UserID != middleware.UserID(c) {
+		return errs.NotAllowed
+	}
+	return nil
+}
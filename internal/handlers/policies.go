@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/errs"
+	"eLibrary/model"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func (h *Handler) ListLoanPolicies(c *gin.Context) {
+	if policies, err := h.service.ListLoanPolicies(c.Request.Context()); err != nil {
+		abort(c, err, nil)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"loan_policies": api.Map(policies, api.NewLoanPolicy)})
+	}
+}
+
+func (h *Handler) GetLoanPolicy(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "loan policy")
+	} else if policy, err := h.service.GetLoanPolicy(c.Request.Context(), id); err != nil {
+		abort(c, err, errs.LoanPolicyNotFound)
+	} else {
+		setETag(c, policy.Version)
+		c.JSON(http.StatusOK, gin.H{"loan_policy": api.NewLoanPolicy(policy)})
+	}
+}
+
+func (h *Handler) CreateLoanPolicy(c *gin.Context) {
+	var policyRequest model.LoanPolicyRequest
+	if err := bind(c, &policyRequest); err != nil {
+		abort(c, err, nil)
+	} else if policy, err := h.service.CreateLoanPolicy(c.Request.Context(), policyRequest); err != nil {
+		abort(c, err, errs.LoanPolicyNotFound)
+	} else {
+		setETag(c, policy.Version)
+		c.JSON(http.StatusOK, gin.H{"loan_policy": api.NewLoanPolicy(policy)})
+	}
+}
+
+func (h *Handler) ReplaceLoanPolicy(c *gin.Context) {
+	var policyRequest model.LoanPolicyRequest
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "loan policy")
+	} else if version, err := ifMatchVersion(c, true); err != nil {
+		abort(c, err, nil)
+	} else if err := bind(c, &policyRequest); err != nil {
+		abort(c, err, nil)
+	} else if policy, err := h.service.ReplaceLoanPolicy(c.Request.Context(), id, version, policyRequest); err != nil {
+		abort(c, err, errs.LoanPolicyNotFound)
+	} else {
+		setETag(c, policy.Version)
+		c.JSON(http.StatusOK, gin.H{"loan_policy": api.NewLoanPolicy(policy)})
+	}
+}
+
+func (h *Handler) DeleteLoanPolicy(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "loan policy")
+	} else if err := h.service.DeleteLoanPolicy(c.Request.Context(), id); err != nil {
+		abort(c, err, errs.LoanPolicyNotFound)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/middleware"
+	"eLibrary/model"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetReading returns the caller's progress, bookmarks and highlights in a
+// book, for reader apps to sync from. Progress is null until first saved.
+func (h *Handler) GetReading(c *gin.Context) {
+	if bookID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if reading, err := h.service.GetReading(c.Request.Context(), middleware.UserID(c), bookID); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		var progress *api.ReadingProgress
+		if reading.Progress != nil {
+			saved := api.NewReadingProgress(*reading.Progress)
+			progress = &saved
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"progress":   progress,
+			"bookmarks":  api.Map(reading.Bookmarks, api.NewBookmark),
+			"highlights": api.Map(reading.Highlights, api.NewHighlight),
+		})
+	}
+}
+
+// SaveProgress records the caller's position in a book they have on digital
+// loan, and responds with the progress kept, which is the stored one when
+// the request was recorded earlier.
+func (h *Handler) SaveProgress(c *gin.Context) {
+	var req model.ProgressRequest
+	if bookID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if err := bind(c, &req); err != nil {
+		abort(c, err, nil)
+	} else if progress, err := h.service.SaveProgress(c.Request.Context(), middleware.UserID(c), bookID, req); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"progress": api.NewReadingProgress(progress)})
+	}
+}
+
+// AddBookmark bookmarks a location in a book the caller has on digital loan.
+func (h *Handler) AddBookmark(c *gin.Context) {
+	var req model.BookmarkRequest
+	if bookID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if err := bind(c, &req); err != nil {
+		abort(c, err, nil)
+	} else if bookmark, err := h.service.AddBookmark(c.Request.Context(), middleware.UserID(c), bookID, req); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"bookmark": api.NewBookmark(bookmark)})
+	}
+}
+
+// DeleteBookmark removes one of the caller's bookmarks.
+func (h *Handler) DeleteBookmark(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "bookmark")
+	} else if err := h.service.DeleteBookmark(c.Request.Context(), id, middleware.UserID(c)); err != nil {
+		abort(c, err, errs.BookmarkNotFound)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// AddHighlight highlights a passage of a book the caller has on digital loan.
+func (h *Handler) AddHighlight(c *gin.Context) {
+	var req model.HighlightRequest
+	if bookID, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if err := bind(c, &req); err != nil {
+		abort(c, err, nil)
+	} else if highlight, err := h.service.AddHighlight(c.Request.Context(), middleware.UserID(c), bookID, req); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.JSON(http.StatusOK, gin.H{"highlight": api.NewHighlight(highlight)})
+	}
+}
+
+// DeleteHighlight removes one of the caller's highlights.
+func (h *Handler) DeleteHighlight(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "highlight")
+	} else if err := h.service.DeleteHighlight(c.Request.Context(), id, middleware.UserID(c)); err != nil {
+		abort(c, err, errs.HighlightNotFound)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
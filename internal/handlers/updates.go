@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"eLibrary/internal/api"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/middleware"
+	"eLibrary/model"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (h *Handler) ReplaceBook(c *gin.Context) {
+	var bookRequest model.BookRequest
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if version, err := ifMatchVersion(c, true); err != nil {
+		abort(c, err, nil)
+	} else if err := bind(c, &bookRequest); err != nil {
+		abort(c, err, nil)
+	} else if book, err := h.service.ReplaceBook(c.Request.Context(), id, version, bookRequest); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		setETag(c, book.Version)
+		c.JSON(http.StatusOK, gin.H{"book": api.NewBook(book)})
+	}
+}
+
+func (h *Handler) PatchBook(c *gin.Context) {
+	var bookPatch model.BookPatch
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if version, err := ifMatchVersion(c, true); err != nil {
+		abort(c, err, nil)
+	} else if err := bind(c, &bookPatch); err != nil {
+		abort(c, err, nil)
+	} else if book, err := h.service.PatchBook(c.Request.Context(), id, version, bookPatch); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		setETag(c, book.Version)
+		c.JSON(http.StatusOK, gin.H{"book": api.NewBook(book)})
+	}
+}
+
+func (h *Handler) DeleteBook(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if version, err := ifMatchVersion(c, false); err != nil {
+		abort(c, err, nil)
+	} else if err := h.service.DeleteBook(c.Request.Context(), id, version); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *Handler) RestoreBook(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "book")
+	} else if book, err := h.service.RestoreBook(c.Request.Context(), id); err != nil {
+		abort(c, err, errs.BookNotFound)
+	} else {
+		setETag(c, book.Version)
+		c.JSON(http.StatusOK, gin.H{"book": api.NewBook(book)})
+	}
+}
+
+func (h *Handler) GetUser(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "user")
+	} else if user, err := h.service.GetUser(c.Request.Context(), id); err != nil {
+		abort(c, err, errs.UserNotFound)
+	} else {
+		setETag(c, user.Version)
+		c.JSON(http.StatusOK, gin.H{"user": api.NewUser(user)})
+	}
+}
+
+func (h *Handler) ReplaceUser(c *gin.Context) {
+	var userRequest model.UserRequest
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "user")
+	} else if version, err := ifMatchVersion(c, true); err != nil {
+		abort(c, err, nil)
+	} else if err := bind(c, &userRequest); err != nil {
+		abort(c, err, nil)
+	} else if err := h.authorizeUserChange(c, id, userRequest.Role); err != nil {
+		abort(c, err, errs.UserNotFound)
+	} else if userRequest.Category != "" && !auth.Can(middleware.Role(c), auth.ManageUsers) {
+		abort(c, errs.NotAllowed, nil)
+	} else if user, err := h.service.ReplaceUser(c.Request.Context(), id, version, userRequest); err != nil {
+		abort(c, err, errs.UserNotFound)
+	} else {
+		setETag(c, user.Version)
+		c.JSON(http.StatusOK, gin.H{"user": api.NewUser(user)})
+	}
+}
+
+func (h *Handler) PatchUser(c *gin.Context) {
+	var userPatch model.UserPatch
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "user")
+	} else if version, err := ifMatchVersion(c, true); err != nil {
+		abort(c, err, nil)
+	} else if err := bind(c, &userPatch); err != nil {
+		abort(c, err, nil)
+	} else if err := h.authorizeUserChange(c, id, optionalRole(userPatch.Role)); err != nil {
+		abort(c, err, errs.UserNotFound)
+	} else if userPatch.Category != nil && !auth.Can(middleware.Role(c), auth.ManageUsers) {
+		abort(c, errs.NotAllowed, nil)
+	} else if user, err := h.service.PatchUser(c.Request.Context(), id, version, userPatch); err != nil {
+		abort(c, err, errs.UserNotFound)
+	} else {
+		setETag(c, user.Version)
+		c.JSON(http.StatusOK, gin.H{"user": api.NewUser(user)})
+	}
+}
+
+func (h *Handler) DeleteUser(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "user")
+	} else if version, err := ifMatchVersion(c, false); err != nil {
+		abort(c, err, nil)
+	} else if err := h.authorizeUserChange(c, id, ""); err != nil {
+		abort(c, err, errs.UserNotFound)
+	} else if err := h.service.DeleteUser(c.Request.Context(), id, version); err != nil {
+		abort(c, err, errs.UserNotFound)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *Handler) RestoreUser(c *gin.Context) {
+	if id, err := parseID(c.Param("id")); err != nil {
+		invalidID(c, "user")
+	} else if user, err := h.service.RestoreUser(c.Request.Context(), id); err != nil {
+		abort(c, err, errs.UserNotFound)
+	} else {
+		setETag(c, user.Version)
+		c.JSON(http.StatusOK, gin.H{"user": api.NewUser(user)})
+	}
+}
+
+// authorizeUserChange checks that the caller may change the user with id to
+// role, where an empty role leaves it as is. Only admins may hand out staff
+// roles or touch other staff accounts; the route decides who else gets here.
+func (h *Handler) authorizeUserChange(c *gin.Context, id uint, role model.Role) error {
+	if auth.Can(middleware.Role(c), auth.AssignRoles) {
+		return nil
+	}
+	if role != "" && role != model.RolePatron {
+		return errs.NotAllowed
+	}
+	if id == middleware.UserID(c) {
+		return nil
+	}
+
+	target, err := h.service.GetUser(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+	if target.Role != model.RolePatron {
+		return errs.NotAllowed
+	}
+	return nil
+}
+
+func optionalRole(role *model.Role) model.Role {
+	if role == nil {
+		return ""
+	}
+	return *role
+}
+
+// setETag exposes the version of a record as its entity tag.
+func setETag(c *gin.Context, version uint) {
+	c.Header("ETag", fmt.Sprintf("%q", strconv.FormatUint(uint64(version), 10)))
+}
+
+// ifMatchVersion returns the version named by the If-Match header, or zero for
+// "*" and, unless required, for a missing header. Weak tags are accepted since
+// versions are only ever compared, never used for byte ranges.
+func ifMatchVersion(c *gin.Context, required bool) (uint, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if required {
+			return 0, errs.IfMatchRequired
+		}
+		return 0, nil
+	}
+	if header == "*" {
+		return 0, nil
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		return 0, fmt.Errorf("%w: entity tag %s is not quoted", errs.InvalidIfMatch, header)
+	}
+	version, err := parseID(unquoted)
+	if err != nil {
+		return 0, fmt.Errorf("%w: entity tag %s is not a version", errs.InvalidIfMatch, header)
+	}
+	return version, nil
+}
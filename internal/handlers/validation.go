@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"eLibrary/internal/errs"
+	"eLibrary/internal/validation"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+// bind decodes the JSON body of a request into target and validates it. Values
+// of the wrong type and failed rules are reported per field as errs.Fields.
+func bind(c *gin.Context, target interface{}) error {
+	if err := c.ShouldBindJSON(target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return errs.Fields{{Field: typeErr.Field, Rule: "type", Message: "must be a " + typeErr.Type.String()}}
+		}
+		return fmt.Errorf("%w: %v", errs.InvalidBody, err)
+	}
+	return validation.Struct(target)
+}
+
+// bindForm decodes the form fields of a request, such as those sent along
+// with an upload, into target and validates it like bind. A body cut off by
+// limitUpload is reported as errs.FileTooLarge.
+func bindForm(c *gin.Context, target interface{}) error {
+	if err := c.ShouldBindWith(target, binding.Form); err != nil {
+		var sizeErr *http.MaxBytesError
+		if errors.As(err, &sizeErr) {
+			return errs.FileTooLarge
+		}
+		return fmt.Errorf("%w: %v", errs.InvalidBody, err)
+	}
+	return validation.Struct(target)
+}
+
+// uploadOverhead is how much larger than the file it carries a multipart
+// form may be, for its boundaries, part headers and other fields.
+const uploadOverhead = 64 << 10
+
+// limitUpload caps the body of an upload at the maximum upload size, so an
+// oversized form is cut off while being read rather than spooled to disk
+// first. It goes before bindForm, which reads the form.
+func (h *Handler) limitUpload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.service.MaxUploadSize()+uploadOverhead)
+}
@@ -0,0 +1,149 @@
+package interchange
+
+import (
+	"eLibrary/internal/errs"
+	"eLibrary/model"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvColumns are the columns a CSV file may have, named after the fields of
+// model.BookRequest. Authors are separated by semicolons. The id a book has in
+// the library a file was exported from is ignored, books are matched by ISBN.
+var csvColumns = map[string]func(book *model.BookRequest, value string) error{
+	"id":        func(book *model.BookRequest, value string) error { return nil },
+	"title":     func(book *model.BookRequest, value string) error { book.Title = value; return nil },
+	"author":    func(book *model.BookRequest, value string) error { book.Author = value; return nil },
+	"isbn":      func(book *model.BookRequest, value string) error { book.ISBN = value; return nil },
+	"isbn_10":   func(book *model.BookRequest, value string) error { book.ISBN10 = value; return nil },
+	"isbn_13":   func(book *model.BookRequest, value string) error { book.ISBN13 = value; return nil },
+	"publisher": func(book *model.BookRequest, value string) error { book.Publisher = value; return nil },
+	"language":  func(book *model.BookRequest, value string) error { book.Language = value; return nil },
+	"format":    func(book *model.BookRequest, value string) error { book.Format = value; return nil },
+	"authors": func(book *model.BookRequest, value string) error {
+		book.Authors = nil
+		for _, name := range strings.Split(value, ";") {
+			if name = strings.TrimSpace(name); name != "" {
+				book.Authors = append(book.Authors, name)
+			}
+		}
+		return nil
+	},
+	"publication_year": func(book *model.BookRequest, value string) (err error) {
+		book.PublicationYear, err = parseInt(value)
+		return err
+	},
+	"available_copies": func(book *model.BookRequest, value string) (err error) {
+		book.AvailableCopies, err = parseInt(value)
+		return err
+	},
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+}
+
+// newCSVReader reads the header of a CSV file. Its columns may come in any
+// order and be left out, but must all be known.
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: the file is empty", errs.InvalidImportFile)
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.InvalidImportFile, err)
+	}
+
+	columns := make([]string, len(header))
+	seen := make(map[string]bool)
+	for i, name := range header {
+		if i == 0 {
+			// spreadsheets like to start UTF-8 files with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := csvColumns[name]; !ok {
+			return nil, fmt.Errorf("%w: unknown column %q", errs.InvalidImportFile, name)
+		} else if seen[name] {
+			return nil, fmt.Errorf("%w: duplicate column %q", errs.InvalidImportFile, name)
+		}
+		seen[name] = true
+		columns[i] = name
+	}
+	return &csvReader{r: reader, columns: columns}, nil
+}
+
+func (r *csvReader) Read() (row Row, err error) {
+	record, err := r.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+		row.Number = parseErr.StartLine
+		row.Err = fmt.Errorf("%w: has %d columns, the header has %d", errs.InvalidRecord, len(record), len(r.columns))
+		return row, nil
+	} else if parseErr != nil {
+		return row, fmt.Errorf("%w: %v", errs.InvalidImportFile, parseErr)
+	} else if err != nil {
+		return row, err
+	}
+
+	row.Number, _ = r.r.FieldPos(0)
+	var fields errs.Fields
+	for i, value := range record {
+		if err := csvColumns[r.columns[i]](&row.Book, strings.TrimSpace(value)); err != nil {
+			fields = append(fields, errs.FieldError{Field: r.columns[i], Rule: "type", Message: err.Error()})
+		}
+	}
+	if len(fields) > 0 {
+		row.Err = fields
+	}
+	return row, nil
+}
+
+// parseInt reads an optional whole number.
+func parseInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("must be a whole number")
+	}
+	return n, nil
+}
+
+// BookColumns are the columns books are written to CSV with by BookRow.
+var BookColumns = []string{"title", "author", "authors", "isbn", "isbn_10", "isbn_13", "publisher", "publication_year", "language", "format"}
+
+// BookRow returns the values of book for BookColumns.
+func BookRow(book model.BookDetail) []string {
+	var authors []string
+	if book.Work != nil {
+		for _, author := range book.Work.Authors {
+			authors = append(authors, author.Name)
+		}
+	}
+	year := ""
+	if book.PublicationYear != 0 {
+		year = strconv.Itoa(book.PublicationYear)
+	}
+	return []string{
+		book.Title,
+		book.Author,
+		strings.Join(authors, "; "),
+		book.ISBN,
+		book.ISBN10,
+		book.ISBN13,
+		book.Publisher,
+		year,
+		book.Language,
+		book.Format,
+	}
+}
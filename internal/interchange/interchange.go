@@ -0,0 +1,94 @@
+// Package interchange reads and writes catalog records in the files other
+// library systems exchange them in: CSV, and MARC 21 as ISO 2709 or MARCXML.
+// Books written out by it read back in as they were.
+package interchange
+
+import (
+	"eLibrary/internal/errs"
+	"eLibrary/marc"
+	"eLibrary/model"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+type Format string
+
+const (
+	CSV     Format = "csv"
+	MARC    Format = "marc"
+	MARCXML Format = "marcxml"
+	// NDJSON is newline delimited JSON, one record per line. It is only
+	// written, in the shape the HTTP API responds with.
+	NDJSON Format = "ndjson"
+)
+
+// ParseFormat returns the format named name, ignoring case.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case CSV, MARC, MARCXML, NDJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w %q, use csv, ndjson, marc or marcxml", errs.UnsupportedFormat, name)
+}
+
+// FormatOf tells the format of a file from the extension of its name.
+func FormatOf(filename string) (Format, bool) {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return CSV, true
+	case ".mrc", ".marc":
+		return MARC, true
+	case ".xml":
+		return MARCXML, true
+	case ".ndjson", ".jsonl":
+		return NDJSON, true
+	}
+	return "", false
+}
+
+// DetectFormat returns the format named name, or when name is empty the one
+// told by the extension of filename.
+func DetectFormat(name string, filename string) (Format, error) {
+	if name != "" {
+		return ParseFormat(name)
+	}
+	if format, ok := FormatOf(filename); ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("%w: cannot tell the format of %q, name it", errs.UnsupportedFormat, filename)
+}
+
+// Row is one record of a file, read into the request that would create its
+// book.
+type Row struct {
+	// Number is the line of the row in a CSV file, or the position of the
+	// record in a MARC file, counting from 1.
+	Number int
+	Book   model.BookRequest
+	// Err is why the record could not be read into Book, such as a
+	// malformed MARC record or a year that is not a number.
+	Err error
+}
+
+// Reader reads the rows of a file one at a time. Read returns io.EOF after
+// the last row, and any other error when the rest of the file cannot be
+// read; problems confined to one row are reported in its Err instead.
+type Reader interface {
+	Read() (Row, error)
+}
+
+// NewReader returns a reader of the rows of r in format. CSV files are read
+// up to their header right away, so a bad header is reported here.
+func NewReader(format Format, r io.Reader) (Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r)
+	case MARC:
+		return &marcReader{records: marc.NewReader(r)}, nil
+	case MARCXML:
+		return &marcReader{records: marc.NewXMLReader(r)}, nil
+	}
+	return nil, fmt.Errorf("%w %q", errs.UnsupportedFormat, format)
+}
@@ -0,0 +1,194 @@
+package interchange
+
+import (
+	"eLibrary/internal/errs"
+	"eLibrary/isbn"
+	"eLibrary/marc"
+	"eLibrary/model"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// year matches the first four digit year in a date such as "c1965." or
+// "[1965?]".
+var year = regexp.MustCompile(`\d{4}`)
+
+type marcReader struct {
+	records interface {
+		Read() (marc.Record, error)
+	}
+	number int
+}
+
+func (r *marcReader) Read() (row Row, err error) {
+	record, err := r.records.Read()
+	if errors.Is(err, marc.ErrMalformed) {
+		r.number++
+		return Row{Number: r.number, Err: fmt.Errorf("%w: %v", errs.InvalidRecord, err)}, nil
+	} else if err != nil {
+		return row, fileError(err)
+	}
+
+	r.number++
+	row.Number = r.number
+	row.Book.Title = trimISBD(record.Value("245", 'a'))
+	row.Book.ISBN = recordISBN(record)
+	for _, tag := range []string{"100", "110", "700", "710"} {
+		for _, name := range record.Values(tag, 'a') {
+			row.Book.Authors = append(row.Book.Authors, trimISBD(name))
+		}
+	}
+
+	// RDA records put the publisher in 264 with the second indicator 1,
+	// older ones in 260
+	var published marc.DataField
+	for _, field := range record.Fields("264") {
+		if field.Ind2 == '1' {
+			published = field
+			break
+		}
+	}
+	if published.Tag == "" {
+		if fields := record.Fields("260"); len(fields) > 0 {
+			published = fields[0]
+		}
+	}
+	row.Book.Publisher = trimISBD(published.Value('b'))
+
+	// 008 holds the date of publication at 7-10 and the language at 35-37
+	fixed := record.Control("008")
+	if date := year.FindString(published.Value('c')); date != "" {
+		row.Book.PublicationYear, _ = strconv.Atoi(date)
+	} else if len(fixed) >= 11 && year.MatchString(fixed[7:11]) {
+		row.Book.PublicationYear, _ = strconv.Atoi(fixed[7:11])
+	}
+	if len(fixed) >= 38 && isLetters(fixed[35:38]) {
+		row.Book.Language = fixed[35:38]
+	} else {
+		row.Book.Language = record.Value("041", 'a')
+	}
+	return row, nil
+}
+
+// recordISBN returns the first valid ISBN in the 020 fields of a record, or
+// the first one given when none is valid so that validation reports it.
+// ISBNs are often followed by a qualifier, as in "0441172717 (pbk.)".
+func recordISBN(record marc.Record) string {
+	var first string
+	for _, value := range record.Values("020", 'a') {
+		number, _, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if isbn.IsValid(number) {
+			return number
+		} else if first == "" {
+			first = number
+		}
+	}
+	return first
+}
+
+// trimISBD strips the punctuation cataloguing rules end subfields with, as in
+// "Dune /" or "Herbert, Frank,".
+func trimISBD(value string) string {
+	return strings.TrimRightFunc(value, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("/:;,=", r)
+	})
+}
+
+func isLetters(value string) bool {
+	for _, r := range value {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// fileError reports errors after which a MARC file cannot be read on.
+func fileError(err error) error {
+	if errors.Is(err, io.EOF) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", errs.InvalidImportFile, err)
+}
+
+// bookLeader describes a new record of a book in Unicode. The writer fills in
+// the record length and base address.
+const bookLeader = "00000nam a2200000 i 4500"
+
+// BookRecord describes book as a MARC record with the fields the rows of a
+// MARC file are read from: the book's id as control number, 008 with the
+// publication year and language, ISBNs in 020, the first author in 100 and
+// the others in 700, the title in 245 and the publisher in 264.
+func BookRecord(book model.BookDetail) marc.Record {
+	record := marc.Record{
+		Leader: bookLeader,
+		ControlFields: []marc.ControlField{
+			{Tag: "001", Value: strconv.FormatUint(uint64(book.ID), 10)},
+			{Tag: "008", Value: fixedField(book)},
+		},
+	}
+	field := func(tag string, ind1 byte, ind2 byte, subfields ...marc.Subfield) {
+		record.DataFields = append(record.DataFields, marc.DataField{Tag: tag, Ind1: ind1, Ind2: ind2, Subfields: subfields})
+	}
+
+	for _, number := range []string{book.ISBN, book.ISBN10} {
+		if number != "" {
+			field("020", ' ', ' ', marc.Subfield{Code: 'a', Value: number})
+		}
+	}
+
+	var authors []string
+	if book.Work != nil {
+		for _, author := range book.Work.Authors {
+			authors = append(authors, author.Name)
+		}
+	}
+	if len(authors) == 0 && book.Author != "" {
+		authors = []string{book.Author}
+	}
+	for i, name := range authors {
+		tag := "700"
+		if i == 0 {
+			tag = "100"
+		}
+		field(tag, '1', ' ', marc.Subfield{Code: 'a', Value: name})
+	}
+
+	field("245", '1', '0', marc.Subfield{Code: 'a', Value: book.Title})
+	var published []marc.Subfield
+	if book.Publisher != "" {
+		published = append(published, marc.Subfield{Code: 'b', Value: book.Publisher})
+	}
+	if book.PublicationYear != 0 {
+		published = append(published, marc.Subfield{Code: 'c', Value: strconv.Itoa(book.PublicationYear)})
+	}
+	if len(published) > 0 {
+		field("264", ' ', '1', published...)
+	}
+	return record
+}
+
+// fixedField returns the 40 characters of the 008 field of book: when the
+// record was made, the publication year at 7-10 and the language at 35-37,
+// blank when unknown.
+func fixedField(book model.BookDetail) string {
+	fixed := []byte(strings.Repeat(" ", 40))
+	copy(fixed[0:6], book.CreatedAt.Format("060102"))
+	fixed[6] = 's'
+	if book.PublicationYear > 0 && book.PublicationYear <= 9999 {
+		copy(fixed[7:11], fmt.Sprintf("%04d", book.PublicationYear))
+	} else {
+		fixed[6] = 'n'
+		copy(fixed[7:11], "uuuu")
+	}
+	if len(book.Language) == 3 && isLetters(book.Language) {
+		copy(fixed[35:38], book.Language)
+	}
+	fixed[39] = 'd'
+	return string(fixed)
+}
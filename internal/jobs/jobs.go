@@ -0,0 +1,43 @@
+// Package jobs runs the periodic maintenance tasks of the library, such as
+// expiring uncollected holds, inside the API process.
+package jobs
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Task is one run of a periodic job. It returns how many records it changed.
+type Task func(ctx context.Context, now time.Time) (int, error)
+
+// Every runs task right away, catching up on whatever fell due while the
+// process was down, and then once per interval until ctx is done. Failures
+// are logged and retried on the next tick.
+func Every(ctx context.Context, name string, interval time.Duration, task Task) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	run(ctx, name, time.Now(), task)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			run(ctx, name, now, task)
+		}
+	}
+}
+
+// run runs task once. A task may change some records before it fails, so
+// both are logged.
+func run(ctx context.Context, name string, now time.Time, task Task) {
+	changed, err := task(ctx, now)
+	if err != nil {
+		log.WithError(err).Errorf("job %s failed", name)
+	}
+	if changed > 0 {
+		log.Infof("job %s updated %d records", name, changed)
+	}
+}
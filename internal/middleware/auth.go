@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/problem"
+	"eLibrary/model"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"strings"
+)
+
+// principalKey is where Authenticate leaves the caller in the gin context.
+const principalKey = "principal"
+
+// Authenticate rejects requests without a valid bearer access token and
+// records the user it was issued to for UserID and Role.
+func Authenticate(tokens *auth.Issuer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			c.Header("WWW-Authenticate", `Bearer realm="elibrary"`)
+			problem.Abort(c, errs.MissingToken)
+			return
+		}
+
+		principal, err := tokens.Verify(strings.TrimSpace(token), auth.AccessToken)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="elibrary", error="invalid_token"`)
+			problem.Abort(c, err)
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// Require lets only callers whose role holds permission through.
+func Require(permission auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Can(Role(c), permission) {
+			forbid(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelfOr lets callers through that either hold permission or are the
+// user named by the route parameter param.
+func RequireSelfOr(permission auth.Permission, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Can(Role(c), permission) && c.Param(param) != strconv.FormatUint(uint64(UserID(c)), 10) {
+			forbid(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// UserID returns the caller authenticated by Authenticate, or zero outside of
+// it.
+func UserID(c *gin.Context) uint {
+	return principal(c).UserID
+}
+
+// Role returns the role of the caller authenticated by Authenticate.
+func Role(c *gin.Context) model.Role {
+	return principal(c).Role
+}
+
+func principal(c *gin.Context) auth.Principal {
+	principal, _ := c.Get(principalKey)
+	p, _ := principal.(auth.Principal)
+	return p
+}
+
+func forbid(c *gin.Context) {
+	problem.Abort(c, errs.NotAllowed)
+}
@@ -6,9 +6,38 @@ import (
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
 	"time"
 )
 
+// secretField matches JSON string fields whose values must never be logged,
+// url among them as download links are signed to grant access on their own.
+var secretField = regexp.MustCompile(`"(password|access_token|refresh_token|url)"\s*:\s*"(?:[^"\\]|\\.)*"`)
+
+func redactBody(body string) string {
+	return secretField.ReplaceAllString(body, `"$1":"[REDACTED]"`)
+}
+
+// isJSON reports whether a body of contentType is JSON and so worth logging.
+// Other bodies, such as uploaded and downloaded files, are neither read into
+// memory nor logged.
+func isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func redactHeaders(headers http.Header) http.Header {
+	if headers.Get("Authorization") == "" {
+		return headers
+	}
+	redacted := headers.Clone()
+	redacted.Set("Authorization", "[REDACTED]")
+	return redacted
+}
+
 func Logger2() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
@@ -40,7 +69,7 @@ func Logger() gin.HandlerFunc {
 
 		// Log request details
 		var bodyBytes []byte
-		if c.Request.Body != nil {
+		if c.Request.Body != nil && isJSON(c.GetHeader("Content-Type")) {
 			var err error
 			bodyBytes, err = io.ReadAll(c.Request.Body)
 			if err != nil {
@@ -54,8 +83,8 @@ func Logger() gin.HandlerFunc {
 		log.WithFields(log.Fields{
 			"method":  c.Request.Method,
 			"path":    c.Request.URL.Path,
-			"headers": c.Request.Header,
-			"body":    string(bodyBytes),
+			"headers": redactHeaders(c.Request.Header),
+			"body":    redactBody(string(bodyBytes)),
 		}).Info("Incoming Request")
 
 		// Capture response body
@@ -70,7 +99,7 @@ func Logger() gin.HandlerFunc {
 		endTime := time.Since(startTime)
 		log.WithFields(log.Fields{
 			"status":       writer.Status(),
-			"responseBody": responseBody.String(),
+			"responseBody": redactBody(responseBody.String()),
 			"responseTime": endTime,
 		}).Info("Response Details")
 	}
@@ -82,6 +111,8 @@ type responseWriter struct {
 }
 
 func (rw *responseWriter) Write(data []byte) (int, error) {
-	rw.body.Write(data)                  // Copy response to buffer
+	if isJSON(rw.Header().Get("Content-Type")) {
+		rw.body.Write(data) // Copy response to buffer
+	}
 	return rw.ResponseWriter.Write(data) // Write response to client
 }
@@ -0,0 +1,94 @@
+// Package problem reports errors to API clients as RFC 7807 problem details.
+package problem
+
+import (
+	"eLibrary/internal/errs"
+	"errors"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// ContentType is the media type of every error response.
+const ContentType = "application/problem+json"
+
+// typePrefix namespaces the problem types, which are derived from error codes.
+const typePrefix = "urn:elibrary:problem:"
+
+// Problem is an RFC 7807 problem details object. Code repeats the error code
+// the type is derived from, so clients can switch on it without parsing URNs,
+// and Errors lists the fields of a request that failed validation.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code,omitempty"`
+	Errors   []errs.FieldError `json:"errors,omitempty"`
+}
+
+var statuses = map[errs.Kind]int{
+	errs.Internal:        http.StatusInternalServerError,
+	errs.Invalid:         http.StatusBadRequest,
+	errs.Unauthenticated: http.StatusUnauthorized,
+	errs.Forbidden:       http.StatusForbidden,
+	errs.NotFound:        http.StatusNotFound,
+	errs.Conflict:        http.StatusConflict,
+	errs.Stale:           http.StatusPreconditionFailed,
+	errs.Unconditional:   http.StatusPreconditionRequired,
+	errs.TooLarge:        http.StatusRequestEntityTooLarge,
+}
+
+// Status returns the HTTP status errors of kind are reported with.
+func Status(kind errs.Kind) int {
+	if status, ok := statuses[kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// New describes err for the request to instance. Errors outside of package
+// errs are internal and described only by their status, since their messages
+// may reveal implementation details.
+func New(err error, instance string) Problem {
+	domain := errs.As(err)
+	if domain == nil || domain.Kind == errs.Internal {
+		return Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(http.StatusInternalServerError),
+			Status:   http.StatusInternalServerError,
+			Instance: instance,
+		}
+	}
+
+	p := Problem{
+		Type:     typePrefix + domain.Code,
+		Title:    domain.Message,
+		Status:   Status(domain.Kind),
+		Instance: instance,
+		Code:     domain.Code,
+	}
+	if detail := err.Error(); detail != domain.Message {
+		p.Detail = detail
+	}
+	var fields errs.Fields
+	if errors.As(err, &fields) {
+		p.Errors = fields
+	}
+	return p
+}
+
+// Abort stops the request with the problem err describes. Internal errors are
+// logged, as clients never see what they were.
+func Abort(c *gin.Context, err error) {
+	p := New(err, c.Request.URL.Path)
+	if p.Status == http.StatusInternalServerError {
+		log.WithError(err).WithFields(log.Fields{
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+		}).Error("Request failed")
+	}
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+)
+
+type BookRepository interface {
+	// FindByID returns an edition with its work and the work's authors.
+	FindByID(ctx context.Context, id uint) (model.BookDetail, error)
+	// FindByISBN matches either the ISBN-10 or ISBN-13 of an edition.
+	FindByISBN(ctx context.Context, isbn string) (model.BookDetail, error)
+	// FindByTitle returns every edition with the given title.
+	FindByTitle(ctx context.Context, title string) ([]model.BookDetail, error)
+	// FindAvailableByTitle returns the titled book if at least one of its
+	// copies is available.
+	FindAvailableByTitle(ctx context.Context, title string) (model.BookDetail, error)
+	Search(ctx context.Context, search BookSearch) (Page[model.BookDetail], error)
+	// Export hands the books matching filter, with their copy counts and
+	// authors, to fn a batch at a time.
+	Export(ctx context.Context, filter ExportFilter, fn func([]model.BookDetail) error) error
+	// ISBNTaken reports whether a book other than excludeID, including deleted
+	// books, has the given ISBN.
+	ISBNTaken(ctx context.Context, isbn string, excludeID uint) (bool, error)
+	Create(ctx context.Context, book *model.BookDetail) error
+	// Update applies changes to a book still at version, see ErrVersionConflict.
+	// A zero version skips the check.
+	Update(ctx context.Context, id uint, version uint, changes map[string]interface{}) error
+	Delete(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) error
+}
+
+type gormBookRepository struct {
+	db *gorm.DB
+}
+
+func NewBookRepository(db *gorm.DB) BookRepository {
+	return &gormBookRepository{db: db}
+}
+
+func (r *gormBookRepository) FindByID(ctx context.Context, id uint) (book model.BookDetail, err error) {
+	err = conn(ctx, r.db).Scopes(withCopyCounts).Preload("Work.Authors").
+		Where("id = ?", id).First(&book).Error
+	return book, translate(err)
+}
+
+func (r *gormBookRepository) FindByISBN(ctx context.Context, isbn string) (book model.BookDetail, err error) {
+	err = conn(ctx, r.db).Scopes(withCopyCounts).Preload("Work.Authors").
+		Where("isbn = ? OR isbn10 = ? OR isbn13 = ?", isbn, isbn, isbn).First(&book).Error
+	return book, translate(err)
+}
+
+func (r *gormBookRepository) FindByTitle(ctx context.Context, title string) (books []model.BookDetail, err error) {
+	err = conn(ctx, r.db).Scopes(withCopyCounts).Preload("Work.Authors").
+		Where("title = ?", title).Order("id").Find(&books).Error
+	return books, err
+}
+
+func (r *gormBookRepository) FindAvailableByTitle(ctx context.Context, title string) (book model.BookDetail, err error) {
+	err = conn(ctx, r.db).Scopes(withCopyCounts).
+		Where("title = ?", title).
+		Where("EXISTS (SELECT 1 FROM book_copies WHERE book_copies.book_id = book_details.id AND book_copies.status = ? AND book_copies.deleted_at IS NULL)", model.CopyAvailable).
+		First(&book).Error
+	return book, translate(err)
+}
+
+func (r *gormBookRepository) ISBNTaken(ctx context.Context, isbn string, excludeID uint) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Unscoped().Model(&model.BookDetail{}).
+		Where("isbn = ? AND id <> ?", isbn, excludeID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormBookRepository) Create(ctx context.Context, book *model.BookDetail) error {
+	return conn(ctx, r.db).Create(book).Error
+}
+
+func (r *gormBookRepository) Export(ctx context.Context, filter ExportFilter, fn func([]model.BookDetail) error) error {
+	query := filter.between(conn(ctx, r.db).Model(&model.BookDetail{}), "book_details.created_at")
+	return eachBatch(query, "book_details", func(b model.BookDetail) uint { return b.ID }, fn,
+		func(db *gorm.DB) *gorm.DB { return db.Scopes(withCopyCounts).Preload("Work.Authors") })
+}
+
+func (r *gormBookRepository) Update(ctx context.Context, id uint, version uint, changes map[string]interface{}) error {
+	return updateVersioned(conn(ctx, r.db), &model.BookDetail{}, id, version, changes)
+}
+
+func (r *gormBookRepository) Delete(ctx context.Context, id uint) error {
+	return softDelete(conn(ctx, r.db), &model.BookDetail{}, id)
+}
+
+func (r *gormBookRepository) Restore(ctx context.Context, id uint) error {
+	return restore(conn(ctx, r.db), &model.BookDetail{}, id)
+}
+
+// withCopyCounts fills in the derived AvailableCopies and TotalCopies of
+// every book the query returns. Withdrawn copies no longer count as owned.
+func withCopyCounts(db *gorm.DB) *gorm.DB {
+	return db.Select("book_details.*, "+
+		"(SELECT COUNT(*) FROM book_copies WHERE book_copies.book_id = book_details.id AND book_copies.status = ? AND book_copies.deleted_at IS NULL) AS available_copies, "+
+		"(SELECT COUNT(*) FROM book_copies WHERE book_copies.book_id = book_details.id AND book_copies.status <> ? AND book_copies.deleted_at IS NULL) AS total_copies",
+		model.CopyAvailable, model.CopyWithdrawn)
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+)
+
+// claimCandidates bounds how many available copies Claim tries before giving
+// up; each attempt only fails when a concurrent borrower took that copy.
+const claimCandidates = 5
+
+type CopyRepository interface {
+	ListByBook(ctx context.Context, bookID uint) ([]model.BookCopy, error)
+	FindByBarcode(ctx context.Context, barcode string) (model.BookCopy, error)
+	// CountByBook counts every copy ever added to a book, including deleted ones.
+	CountByBook(ctx context.Context, bookID uint) (int64, error)
+	Create(ctx context.Context, bookCopy *model.BookCopy) error
+	Save(ctx context.Context, bookCopy *model.BookCopy) error
+	// Claim atomically marks one available copy of a book as on loan and
+	// returns it, or ErrNotFound when no copy is available.
+	Claim(ctx context.Context, bookID uint) (model.BookCopy, error)
+	// Release puts a copy that was on loan or on hold back on the shelf.
+	// Copies reported lost while on loan are treated as found.
+	Release(ctx context.Context, copyID uint) error
+	// Transition atomically changes the status of a copy that is still in
+	// status from, and reports whether it was.
+	Transition(ctx context.Context, copyID uint, from model.CopyStatus, to model.CopyStatus) (bool, error)
+}
+
+type gormCopyRepository struct {
+	db *gorm.DB
+}
+
+func NewCopyRepository(db *gorm.DB) CopyRepository {
+	return &gormCopyRepository{db: db}
+}
+
+func (r *gormCopyRepository) ListByBook(ctx context.Context, bookID uint) (copies []model.BookCopy, err error) {
+	err = conn(ctx, r.db).Where("book_id = ?", bookID).Order("id").Find(&copies).Error
+	return copies, err
+}
+
+func (r *gormCopyRepository) FindByBarcode(ctx context.Context, barcode string) (bookCopy model.BookCopy, err error) {
+	err = conn(ctx, r.db).Where("barcode = ?", barcode).First(&bookCopy).Error
+	return bookCopy, translate(err)
+}
+
+func (r *gormCopyRepository) CountByBook(ctx context.Context, bookID uint) (count int64, err error) {
+	err = conn(ctx, r.db).Unscoped().Model(&model.BookCopy{}).Where("book_id = ?", bookID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormCopyRepository) Create(ctx context.Context, bookCopy *model.BookCopy) error {
+	return conn(ctx, r.db).Create(bookCopy).Error
+}
+
+func (r *gormCopyRepository) Save(ctx context.Context, bookCopy *model.BookCopy) error {
+	return conn(ctx, r.db).Save(bookCopy).Error
+}
+
+func (r *gormCopyRepository) Claim(ctx context.Context, bookID uint) (bookCopy model.BookCopy, err error) {
+	db := conn(ctx, r.db)
+
+	var candidates []model.BookCopy
+	err = db.Where("book_id = ? AND status = ?", bookID, model.CopyAvailable).
+		Order("id").Limit(claimCandidates).Find(&candidates).Error
+	if err != nil {
+		return bookCopy, err
+	}
+
+	for _, candidate := range candidates {
+		// the status condition makes the claim atomic: a copy another borrower
+		// took in the meantime simply affects no rows
+		result := db.Model(&model.BookCopy{}).
+			Where("id = ? AND status = ?", candidate.ID, model.CopyAvailable).
+			Update("status", model.CopyOnLoan)
+		if result.Error != nil {
+			return bookCopy, result.Error
+		}
+		if result.RowsAffected > 0 {
+			candidate.Status = model.CopyOnLoan
+			return candidate, nil
+		}
+	}
+
+	return bookCopy, ErrNotFound
+}
+
+func (r *gormCopyRepository) Release(ctx context.Context, copyID uint) error {
+	return conn(ctx, r.db).Model(&model.BookCopy{}).
+		Where("id = ? AND status IN ?", copyID, []model.CopyStatus{model.CopyOnLoan, model.CopyLost, model.CopyOnHold}).
+		Update("status", model.CopyAvailable).Error
+}
+
+func (r *gormCopyRepository) Transition(ctx context.Context, copyID uint, from model.CopyStatus, to model.CopyStatus) (bool, error) {
+	result := conn(ctx, r.db).Model(&model.BookCopy{}).
+		Where("id = ? AND status = ?", copyID, from).
+		Update("status", to)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+)
+
+// EventFilter narrows an event listing; zero fields match everything.
+type EventFilter struct {
+	Type   model.EventType
+	UserID uint
+	LoanID uint
+	ListOptions
+}
+
+var eventSortKeys = map[string]sortKey[model.Event]{
+	"id":          {"id", func(e model.Event) interface{} { return e.ID }},
+	"occurred_at": {"occurred_at", func(e model.Event) interface{} { return e.OccurredAt }},
+}
+
+// EventRepository is an append-only log of events.
+type EventRepository interface {
+	Create(ctx context.Context, event *model.Event) error
+	List(ctx context.Context, filter EventFilter) (Page[model.Event], error)
+}
+
+type gormEventRepository struct {
+	db *gorm.DB
+}
+
+func NewEventRepository(db *gorm.DB) EventRepository {
+	return &gormEventRepository{db: db}
+}
+
+func (r *gormEventRepository) Create(ctx context.Context, event *model.Event) error {
+	return conn(ctx, r.db).Create(event).Error
+}
+
+func (r *gormEventRepository) List(ctx context.Context, filter EventFilter) (Page[model.Event], error) {
+	query := conn(ctx, r.db).Model(&model.Event{})
+	if filter.Type != "" {
+		query = query.Where("events.type = ?", filter.Type)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("events.user_id = ?", filter.UserID)
+	}
+	if filter.LoanID != 0 {
+		query = query.Where("events.loan_id = ?", filter.LoanID)
+	}
+	return paginate(query, "events", filter.ListOptions, eventSortKeys, func(e model.Event) uint { return e.ID })
+}
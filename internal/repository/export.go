@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// exportBatchSize is how many records an export reads at a time.
+const exportBatchSize = 500
+
+// ExportFilter narrows an export; zero fields match everything. From and To
+// bound when records were created, or for loans when they were lent, From
+// inclusive and To exclusive.
+type ExportFilter struct {
+	From time.Time
+	To   time.Time
+	// IsReturned selects returned or outstanding loans and is ignored for
+	// other records.
+	IsReturned *bool
+}
+
+// between applies the time bounds of the filter to column.
+func (f ExportFilter) between(query *gorm.DB, column string) *gorm.DB {
+	if !f.From.IsZero() {
+		query = query.Where(column+" >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		query = query.Where(column+" < ?", f.To)
+	}
+	return query
+}
+
+// eachBatch hands query to fn in batches of exportBatchSize records in the
+// order of their ids, seeking past the last id of each batch rather than
+// holding a cursor open, so no batch waits on the consumer of the one before.
+// table qualifies the id column and scopes, e.g. preloads, are applied to
+// every batch.
+func eachBatch[T any](query *gorm.DB, table string, id func(T) uint, fn func([]T) error, scopes ...func(*gorm.DB) *gorm.DB) error {
+	idColumn := table + ".id"
+	var last uint
+	for {
+		var batch []T
+		err := query.Session(&gorm.Session{}).Scopes(scopes...).
+			Where(fmt.Sprintf("%s > ?", idColumn), last).Order(idColumn).Limit(exportBatchSize).
+			Find(&batch).Error
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err = fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		last = id(batch[len(batch)-1])
+	}
+}
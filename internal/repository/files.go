@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+)
+
+type FileRepository interface {
+	ListByBook(ctx context.Context, bookID uint) ([]model.DigitalFile, error)
+	FindByID(ctx context.Context, id uint) (model.DigitalFile, error)
+	// FindByFormat returns the file of a book in the given format.
+	FindByFormat(ctx context.Context, bookID uint, format model.EbookFormat) (model.DigitalFile, error)
+	Create(ctx context.Context, file *model.DigitalFile) error
+	// Replace swaps the content and license count of a file, leaving the
+	// licenses in use alone.
+	Replace(ctx context.Context, file *model.DigitalFile) error
+	// Claim atomically takes one license of a file, and reports false when
+	// all of them are in use.
+	Claim(ctx context.Context, fileID uint) (bool, error)
+	// Release gives back a license taken by Claim.
+	Release(ctx context.Context, fileID uint) error
+}
+
+type gormFileRepository struct {
+	db *gorm.DB
+}
+
+func NewFileRepository(db *gorm.DB) FileRepository {
+	return &gormFileRepository{db: db}
+}
+
+func (r *gormFileRepository) ListByBook(ctx context.Context, bookID uint) (files []model.DigitalFile, err error) {
+	err = conn(ctx, r.db).Where("book_id = ?", bookID).Order("id").Find(&files).Error
+	return files, err
+}
+
+func (r *gormFileRepository) FindByID(ctx context.Context, id uint) (file model.DigitalFile, err error) {
+	err = conn(ctx, r.db).First(&file, id).Error
+	return file, translate(err)
+}
+
+func (r *gormFileRepository) FindByFormat(ctx context.Context, bookID uint, format model.EbookFormat) (file model.DigitalFile, err error) {
+	err = conn(ctx, r.db).Where("book_id = ? AND format = ?", bookID, format).First(&file).Error
+	return file, translate(err)
+}
+
+func (r *gormFileRepository) Create(ctx context.Context, file *model.DigitalFile) error {
+	return conn(ctx, r.db).Create(file).Error
+}
+
+func (r *gormFileRepository) Replace(ctx context.Context, file *model.DigitalFile) error {
+	db := conn(ctx, r.db)
+	err := db.Model(file).Select("filename", "size", "checksum", "storage_key", "licenses").Updates(file).Error
+	if err != nil {
+		return err
+	}
+	return translate(db.First(file, file.ID).Error)
+}
+
+func (r *gormFileRepository) Claim(ctx context.Context, fileID uint) (bool, error) {
+	// like claiming a copy, the condition makes the claim atomic: of several
+	// concurrent borrowers of the last license only one updates the row
+	result := conn(ctx, r.db).Model(&model.DigitalFile{}).
+		Where("id = ? AND licenses_in_use < licenses", fileID).
+		Update("licenses_in_use", gorm.Expr("licenses_in_use + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormFileRepository) Release(ctx context.Context, fileID uint) error {
+	return conn(ctx, r.db).Model(&model.DigitalFile{}).
+		Where("id = ? AND licenses_in_use > 0", fileID).
+		Update("licenses_in_use", gorm.Expr("licenses_in_use - 1")).Error
+}
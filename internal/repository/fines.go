@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FineFilter narrows a fine listing; zero fields match everything.
+type FineFilter struct {
+	UserID uint
+	Status model.FineStatus
+	ListOptions
+}
+
+type FineRepository interface {
+	// FindByID returns a fine. Inside a transaction its row stays locked until
+	// the transaction ends.
+	FindByID(ctx context.Context, id uint) (model.Fine, error)
+	// FindByLoan returns the fine charged for a loan, locked like FindByID.
+	FindByLoan(ctx context.Context, loanID uint) (model.Fine, error)
+	List(ctx context.Context, filter FineFilter) (Page[model.Fine], error)
+	// Balance sums what a user still owes on outstanding fines.
+	Balance(ctx context.Context, userID uint) (int64, error)
+	Create(ctx context.Context, fine *model.Fine) error
+	Save(ctx context.Context, fine *model.Fine) error
+}
+
+var fineSortKeys = map[string]sortKey[model.Fine]{
+	"id":         {"id", func(f model.Fine) interface{} { return f.ID }},
+	"amount":     {"amount", func(f model.Fine) interface{} { return f.Amount }},
+	"created_at": {"created_at", func(f model.Fine) interface{} { return f.CreatedAt }},
+}
+
+type gormFineRepository struct {
+	db *gorm.DB
+}
+
+func NewFineRepository(db *gorm.DB) FineRepository {
+	return &gormFineRepository{db: db}
+}
+
+func (r *gormFineRepository) FindByID(ctx context.Context, id uint) (fine model.Fine, err error) {
+	err = r.locked(ctx).Where("id = ?", id).First(&fine).Error
+	return fine, translate(err)
+}
+
+func (r *gormFineRepository) FindByLoan(ctx context.Context, loanID uint) (fine model.Fine, err error) {
+	err = r.locked(ctx).Where("loan_id = ?", loanID).First(&fine).Error
+	return fine, translate(err)
+}
+
+func (r *gormFineRepository) locked(ctx context.Context) *gorm.DB {
+	return conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+func (r *gormFineRepository) List(ctx context.Context, filter FineFilter) (Page[model.Fine], error) {
+	query := conn(ctx, r.db).Model(&model.Fine{})
+	if filter.UserID != 0 {
+		query = query.Where("fines.user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("fines.status = ?", filter.Status)
+	}
+	return paginate(query, "fines", filter.ListOptions, fineSortKeys, func(f model.Fine) uint { return f.ID })
+}
+
+func (r *gormFineRepository) Balance(ctx context.Context, userID uint) (balance int64, err error) {
+	err = conn(ctx, r.db).Model(&model.Fine{}).
+		Where("user_id = ? AND status = ?", userID, model.FineOutstanding).
+		Select("COALESCE(SUM(amount - paid), 0)").Scan(&balance).Error
+	return balance, err
+}
+
+func (r *gormFineRepository) Create(ctx context.Context, fine *model.Fine) error {
+	return conn(ctx, r.db).Create(fine).Error
+}
+
+func (r *gormFineRepository) Save(ctx context.Context, fine *model.Fine) error {
+	return conn(ctx, r.db).Save(fine).Error
+}
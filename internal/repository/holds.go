@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+// HoldFilter narrows a hold listing; zero fields match everything.
+type HoldFilter struct {
+	UserID uint
+	BookID uint
+	Status model.HoldStatus
+	ListOptions
+}
+
+type HoldRepository interface {
+	FindByID(ctx context.Context, id uint) (model.Hold, error)
+	List(ctx context.Context, filter HoldFilter) (Page[model.Hold], error)
+	// Queue returns the waiting holds on a book, first in line first.
+	Queue(ctx context.Context, bookID uint) ([]model.Hold, error)
+	// ListReady returns the ready holds on a book.
+	ListReady(ctx context.Context, bookID uint) ([]model.Hold, error)
+	// FindActive returns the waiting or ready hold of a user on a book.
+	FindActive(ctx context.Context, bookID uint, userID uint) (model.Hold, error)
+	// FindReadyByTitle returns a ready hold of a user on any edition with
+	// the given title.
+	FindReadyByTitle(ctx context.Context, userID uint, title string) (model.Hold, error)
+	// ListExpired returns the ready holds whose pickup window closed before now.
+	ListExpired(ctx context.Context, now time.Time) ([]model.Hold, error)
+	// NextPosition returns the position a new hold on a book queues up at.
+	NextPosition(ctx context.Context, bookID uint) (int, error)
+	Create(ctx context.Context, hold *model.Hold) error
+	// Transition saves the status, copy, position and timestamps of a hold
+	// provided it is still in status from, and reports whether it was.
+	Transition(ctx context.Context, hold *model.Hold, from model.HoldStatus) (bool, error)
+}
+
+var holdSortKeys = map[string]sortKey[model.Hold]{
+	"id":         {"id", func(h model.Hold) interface{} { return h.ID }},
+	"position":   {"position", func(h model.Hold) interface{} { return h.Position }},
+	"created_at": {"created_at", func(h model.Hold) interface{} { return h.CreatedAt }},
+}
+
+type gormHoldRepository struct {
+	db *gorm.DB
+}
+
+func NewHoldRepository(db *gorm.DB) HoldRepository {
+	return &gormHoldRepository{db: db}
+}
+
+func (r *gormHoldRepository) FindByID(ctx context.Context, id uint) (hold model.Hold, err error) {
+	err = conn(ctx, r.db).Preload("Book").Where("id = ?", id).First(&hold).Error
+	return hold, translate(err)
+}
+
+func (r *gormHoldRepository) List(ctx context.Context, filter HoldFilter) (Page[model.Hold], error) {
+	query := conn(ctx, r.db).Model(&model.Hold{})
+	if filter.UserID != 0 {
+		query = query.Where("holds.user_id = ?", filter.UserID)
+	}
+	if filter.BookID != 0 {
+		query = query.Where("holds.book_id = ?", filter.BookID)
+	}
+	if filter.Status != "" {
+		query = query.Where("holds.status = ?", filter.Status)
+	}
+	return paginate(query, "holds", filter.ListOptions, holdSortKeys, func(h model.Hold) uint { return h.ID },
+		func(db *gorm.DB) *gorm.DB {
+			return db.Preload("Book")
+		})
+}
+
+func (r *gormHoldRepository) Queue(ctx context.Context, bookID uint) (holds []model.Hold, err error) {
+	err = conn(ctx, r.db).Preload("User").
+		Where("book_id = ? AND status = ?", bookID, model.HoldWaiting).
+		Order("position, id").Find(&holds).Error
+	return holds, err
+}
+
+func (r *gormHoldRepository) ListReady(ctx context.Context, bookID uint) (holds []model.Hold, err error) {
+	err = conn(ctx, r.db).Where("book_id = ? AND status = ?", bookID, model.HoldReady).Order("id").Find(&holds).Error
+	return holds, err
+}
+
+func (r *gormHoldRepository) FindActive(ctx context.Context, bookID uint, userID uint) (hold model.Hold, err error) {
+	err = conn(ctx, r.db).
+		Where("book_id = ? AND user_id = ? AND status IN ?", bookID, userID, []model.HoldStatus{model.HoldWaiting, model.HoldReady}).
+		First(&hold).Error
+	return hold, translate(err)
+}
+
+func (r *gormHoldRepository) FindReadyByTitle(ctx context.Context, userID uint, title string) (hold model.Hold, err error) {
+	err = conn(ctx, r.db).
+		Joins("JOIN book_details ON book_details.id = holds.book_id AND book_details.deleted_at IS NULL").
+		Where("holds.user_id = ? AND holds.status = ? AND book_details.title = ?", userID, model.HoldReady, title).
+		Order("holds.id").First(&hold).Error
+	return hold, translate(err)
+}
+
+func (r *gormHoldRepository) ListExpired(ctx context.Context, now time.Time) (holds []model.Hold, err error) {
+	err = conn(ctx, r.db).Where("status = ? AND expires_at < ?", model.HoldReady, now).Order("id").Find(&holds).Error
+	return holds, err
+}
+
+func (r *gormHoldRepository) NextPosition(ctx context.Context, bookID uint) (int, error) {
+	var last *int
+	err := conn(ctx, r.db).Model(&model.Hold{}).
+		Where("book_id = ? AND status = ?", bookID, model.HoldWaiting).
+		Select("MAX(position)").Scan(&last).Error
+	if err != nil || last == nil {
+		return 1, err
+	}
+	return *last + 1, nil
+}
+
+func (r *gormHoldRepository) Create(ctx context.Context, hold *model.Hold) error {
+	return conn(ctx, r.db).Create(hold).Error
+}
+
+func (r *gormHoldRepository) Transition(ctx context.Context, hold *model.Hold, from model.HoldStatus) (bool, error) {
+	result := conn(ctx, r.db).Model(&model.Hold{}).
+		Where("id = ? AND status = ?", hold.ID, from).
+		Select("status", "copy_id", "position", "ready_at", "expires_at").
+		Updates(hold)
+	return result.RowsAffected > 0, result.Error
+}
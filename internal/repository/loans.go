@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// LoanFilter narrows a loan listing. Unset fields do not filter.
+type LoanFilter struct {
+	UserID     uint
+	BookID     uint
+	IsReturned *bool
+	// Overdue selects unreturned loans past their return date (true) or every
+	// other loan (false).
+	Overdue *bool
+	// DueWithin, when set, selects unreturned loans that are not overdue yet
+	// but due within that long from now.
+	DueWithin time.Duration
+	ListOptions
+}
+
+var loanSortKeys = map[string]sortKey[model.LoanDetail]{
+	"id":          {"id", func(l model.LoanDetail) interface{} { return l.ID }},
+	"loan_date":   {"loan_date", func(l model.LoanDetail) interface{} { return l.LoanDate }},
+	"return_date": {"return_date", func(l model.LoanDetail) interface{} { return l.ReturnDate }},
+	"created_at":  {"created_at", func(l model.LoanDetail) interface{} { return l.CreatedAt }},
+}
+
+type LoanRepository interface {
+	List(ctx context.Context, filter LoanFilter) (Page[model.LoanDetail], error)
+	// Export hands the loans matching filter, with their books, to fn a batch
+	// at a time.
+	Export(ctx context.Context, filter ExportFilter, fn func([]model.LoanDetail) error) error
+	// CountActive counts the unreturned loans matching filter.
+	CountActive(ctx context.Context, filter LoanFilter) (int64, error)
+	// FindActive returns the unreturned loan of a book by a user.
+	FindActive(ctx context.Context, bookID uint, userID uint) (model.LoanDetail, error)
+	// FindByID returns a loan with its book, user, copy or file, fine and
+	// renewals preloaded. Inside a transaction the loan row stays locked until it ends.
+	FindByID(ctx context.Context, id uint) (model.LoanDetail, error)
+	// FindByTitle returns a loan of the titled book by a user, preloaded and
+	// locked like FindByID. When activeOnly is false returned loans are
+	// matched too.
+	FindByTitle(ctx context.Context, userID uint, title string, activeOnly bool) (model.LoanDetail, error)
+	Create(ctx context.Context, loan *model.LoanDetail) error
+	Save(ctx context.Context, loan *model.LoanDetail) error
+	// ListOverdue returns the unreturned loans of physical copies whose
+	// return date passed before now.
+	ListOverdue(ctx context.Context, now time.Time) ([]model.LoanDetail, error)
+	// ListExpiredDigital returns the unreturned loans of digital files whose
+	// return date is not after now.
+	ListExpiredDigital(ctx context.Context, now time.Time) ([]model.LoanDetail, error)
+	// Renew moves the return date of an active loan that was renewed
+	// renewals times so far as recorded by renewal, and adds renewal to its
+	// history. It reports false when the loan was returned or renewed since.
+	Renew(ctx context.Context, renewal *model.LoanRenewal, renewals int) (bool, error)
+	// MarkReturned flags an active loan as returned at the given time. It
+	// reports false when the loan had already been returned.
+	MarkReturned(ctx context.Context, loanID uint, at time.Time) (bool, error)
+}
+
+type gormLoanRepository struct {
+	db *gorm.DB
+}
+
+func NewLoanRepository(db *gorm.DB) LoanRepository {
+	return &gormLoanRepository{db: db}
+}
+
+func (r *gormLoanRepository) List(ctx context.Context, filter LoanFilter) (Page[model.LoanDetail], error) {
+	return paginate(r.filtered(ctx, filter), "loan_details", filter.ListOptions, loanSortKeys, func(l model.LoanDetail) uint { return l.ID },
+		func(db *gorm.DB) *gorm.DB {
+			return db.Preload("User").Preload("BookDetail", withCopyCounts).Preload("Copy").Preload("File").Preload("Fine").Preload("RenewalHistory", byID)
+		})
+}
+
+func (r *gormLoanRepository) Export(ctx context.Context, filter ExportFilter, fn func([]model.LoanDetail) error) error {
+	query := filter.between(r.filtered(ctx, LoanFilter{IsReturned: filter.IsReturned}), "loan_details.loan_date")
+	return eachBatch(query, "loan_details", func(l model.LoanDetail) uint { return l.ID }, fn,
+		func(db *gorm.DB) *gorm.DB { return db.Preload("BookDetail") })
+}
+
+func (r *gormLoanRepository) CountActive(ctx context.Context, filter LoanFilter) (count int64, err error) {
+	err = r.filtered(ctx, filter).Where("loan_details.is_returned = ?", false).Count(&count).Error
+	return count, err
+}
+
+func (r *gormLoanRepository) filtered(ctx context.Context, filter LoanFilter) *gorm.DB {
+	query := conn(ctx, r.db).Model(&model.LoanDetail{})
+	if filter.UserID != 0 {
+		query = query.Where("loan_details.user_id = ?", filter.UserID)
+	}
+	if filter.BookID != 0 {
+		query = query.Where("loan_details.book_id = ?", filter.BookID)
+	}
+	if filter.IsReturned != nil {
+		query = query.Where("loan_details.is_returned = ?", *filter.IsReturned)
+	}
+	if filter.Overdue != nil && *filter.Overdue {
+		query = query.Where("loan_details.is_returned = ? AND loan_details.return_date < ?", false, time.Now())
+	} else if filter.Overdue != nil {
+		query = query.Where("loan_details.is_returned = ? OR loan_details.return_date >= ?", true, time.Now())
+	}
+	if filter.DueWithin > 0 {
+		now := time.Now()
+		query = query.Where("loan_details.is_returned = ? AND loan_details.return_date BETWEEN ? AND ?", false, now, now.Add(filter.DueWithin))
+	}
+	return query
+}
+
+func (r *gormLoanRepository) FindActive(ctx context.Context, bookID uint, userID uint) (loan model.LoanDetail, err error) {
+	err = conn(ctx, r.db).
+		Where("book_id = ? AND user_id = ? AND is_returned = ?", bookID, userID, false).
+		First(&loan).Error
+	return loan, translate(err)
+}
+
+func (r *gormLoanRepository) FindByID(ctx context.Context, id uint) (loan model.LoanDetail, err error) {
+	err = r.locked(ctx).Where("loan_details.id = ?", id).First(&loan).Error
+	return loan, translate(err)
+}
+
+func (r *gormLoanRepository) FindByTitle(ctx context.Context, userID uint, title string, activeOnly bool) (loan model.LoanDetail, err error) {
+	query := r.locked(ctx).
+		Joins("JOIN book_details ON book_details.id = loan_details.book_id").
+		Where("book_details.title = ? AND loan_details.user_id = ?", title, userID)
+	if activeOnly {
+		query = query.Where("is_returned = ?", false)
+	}
+	err = query.First(&loan).Error
+	return loan, translate(err)
+}
+
+func (r *gormLoanRepository) locked(ctx context.Context) *gorm.DB {
+	return conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "loan_details"}}).
+		Preload("User").Preload("BookDetail", withCopyCounts).Preload("Copy").Preload("File").Preload("Fine").Preload("RenewalHistory", byID)
+}
+
+func byID(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+func (r *gormLoanRepository) Create(ctx context.Context, loan *model.LoanDetail) error {
+	return conn(ctx, r.db).Create(loan).Error
+}
+
+func (r *gormLoanRepository) Save(ctx context.Context, loan *model.LoanDetail) error {
+	return conn(ctx, r.db).Save(loan).Error
+}
+
+func (r *gormLoanRepository) ListOverdue(ctx context.Context, now time.Time) (loans []model.LoanDetail, err error) {
+	err = conn(ctx, r.db).Where("is_returned = ? AND return_date < ? AND file_id IS NULL", false, now).Order("id").Find(&loans).Error
+	return loans, err
+}
+
+func (r *gormLoanRepository) ListExpiredDigital(ctx context.Context, now time.Time) (loans []model.LoanDetail, err error) {
+	err = conn(ctx, r.db).Where("is_returned = ? AND return_date <= ? AND file_id IS NOT NULL", false, now).Order("id").Find(&loans).Error
+	return loans, err
+}
+
+func (r *gormLoanRepository) Renew(ctx context.Context, renewal *model.LoanRenewal, renewals int) (bool, error) {
+	result := conn(ctx, r.db).Model(&model.LoanDetail{}).
+		Where("id = ? AND is_returned = ? AND renewals = ?", renewal.LoanID, false, renewals).
+		Updates(map[string]interface{}{
+			"return_date": renewal.NewReturnDate,
+			"renewals":    gorm.Expr("renewals + 1"),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	return true, conn(ctx, r.db).Create(renewal).Error
+}
+
+func (r *gormLoanRepository) MarkReturned(ctx context.Context, loanID uint, at time.Time) (bool, error) {
+	result := conn(ctx, r.db).Model(&model.LoanDetail{}).
+		Where("id = ? AND is_returned = ?", loanID, false).
+		Updates(map[string]interface{}{"is_returned": true, "returned_at": at})
+	return result.RowsAffected > 0, result.Error
+}
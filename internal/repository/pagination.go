@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"eLibrary/internal/errs"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCursor = errs.New(errs.Invalid, "invalid_cursor", "invalid cursor")
+	ErrInvalidSort   = errs.New(errs.Invalid, "invalid_sort", "invalid sort")
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ListOptions pages through a collection. Results are sorted by Sort, a field
+// name optionally prefixed with "-" for descending order, and always by ID
+// last so that every row has a stable position.
+type ListOptions struct {
+	// Cursor is the NextCursor of the previous page, empty for the first page.
+	Cursor string
+	// Limit is the size of a page, DefaultPageSize when not positive and at
+	// most MaxPageSize.
+	Limit int
+	Sort  string
+}
+
+// limit returns the page size options ask for, within bounds.
+func (options ListOptions) limit() int {
+	switch {
+	case options.Limit < 1:
+		return DefaultPageSize
+	case options.Limit > MaxPageSize:
+		return MaxPageSize
+	}
+	return options.Limit
+}
+
+// Page is one page of a collection. NextCursor is empty on the last page and
+// Total counts every match across all pages.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+	Total      int64
+}
+
+// sortKey maps a public sort field to its column and reads the same value off
+// a row for the cursor.
+type sortKey[T any] struct {
+	column string
+	value  func(T) interface{}
+}
+
+type cursor struct {
+	// Offset is only used by orderings that cannot be seeked, e.g. relevance.
+	Offset int    `json:"o,omitempty"`
+	Kind   string `json:"k,omitempty"`
+	Value  string `json:"v,omitempty"`
+	ID     uint   `json:"id,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (c cursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func cursorFor(value interface{}, id uint) cursor {
+	switch v := value.(type) {
+	case time.Time:
+		// keep the zone offset, SQLite compares times as text
+		return cursor{Kind: "t", Value: v.Format(time.RFC3339Nano), ID: id}
+	case int, int64:
+		return cursor{Kind: "i", Value: fmt.Sprint(v), ID: id}
+	default:
+		return cursor{Kind: "s", Value: fmt.Sprint(v), ID: id}
+	}
+}
+
+// sortValue turns a cursor value back into the type of its column so that it
+// compares the same way the stored values do.
+func (c cursor) sortValue() (interface{}, error) {
+	switch c.Kind {
+	case "t":
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return t, nil
+	case "i":
+		i, err := strconv.ParseInt(c.Value, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return i, nil
+	case "s":
+		return c.Value, nil
+	default:
+		return nil, ErrInvalidCursor
+	}
+}
+
+// paginate counts query and fetches the page of it described by options using
+// keyset pagination on the requested sort key. table qualifies the columns and
+// scopes, e.g. preloads, are applied to the fetch but not the count.
+func paginate[T any](query *gorm.DB, table string, options ListOptions, keys map[string]sortKey[T], id func(T) uint, scopes ...func(*gorm.DB) *gorm.DB) (page Page[T], err error) {
+	field, desc := strings.CutPrefix(options.Sort, "-")
+	if field == "" {
+		field = "id"
+	}
+	key, ok := keys[field]
+	if !ok {
+		return page, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, field)
+	}
+
+	if err = query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return page, err
+	}
+
+	column := table + "." + key.column
+	idColumn := table + ".id"
+	direction, comparison := "ASC", ">"
+	if desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if options.Cursor != "" {
+		after, decodeErr := decodeCursor(options.Cursor)
+		if decodeErr != nil {
+			return page, decodeErr
+		}
+		value, valueErr := after.sortValue()
+		if valueErr != nil {
+			return page, valueErr
+		}
+		if key.column == "id" {
+			query = query.Where(fmt.Sprintf("%s %s ?", idColumn, comparison), after.ID)
+		} else {
+			query = query.Where(fmt.Sprintf("%s %s ? OR (%s = ? AND %s %s ?)", column, comparison, column, idColumn, comparison),
+				value, value, after.ID)
+		}
+	}
+
+	if key.column != "id" {
+		query = query.Order(column + " " + direction)
+	}
+	query = query.Order(idColumn + " " + direction)
+
+	limit := options.limit()
+	if err = query.Scopes(scopes...).Limit(limit + 1).Find(&page.Items).Error; err != nil {
+		return page, err
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = encodeCursor(cursorFor(key.value(last), id(last)))
+	}
+	return page, nil
+}
+
+// paginateByOffset pages an ordering that cannot be seeked, such as search
+// relevance, by encoding the offset of the next page in the cursor.
+func paginateByOffset[T any](query *gorm.DB, options ListOptions, scopes ...func(*gorm.DB) *gorm.DB) (page Page[T], err error) {
+	offset := 0
+	if options.Cursor != "" {
+		after, decodeErr := decodeCursor(options.Cursor)
+		if decodeErr != nil || after.Offset < 0 {
+			return page, ErrInvalidCursor
+		}
+		offset = after.Offset
+	}
+
+	if err = query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return page, err
+	}
+
+	limit := options.limit()
+	if err = query.Scopes(scopes...).Offset(offset).Limit(limit + 1).Find(&page.Items).Error; err != nil {
+		return page, err
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.NextCursor = encodeCursor(cursor{Offset: offset + limit})
+	}
+	return page, nil
+}
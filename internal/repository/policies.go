@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+)
+
+type LoanPolicyRepository interface {
+	List(ctx context.Context) ([]model.LoanPolicy, error)
+	FindByID(ctx context.Context, id uint) (model.LoanPolicy, error)
+	// Resolve returns the most specific policy for a patron category and item
+	// type: one naming both, then one naming only the category, then one
+	// naming only the item type, then the catch-all.
+	Resolve(ctx context.Context, category string, itemType string) (model.LoanPolicy, error)
+	// ScopeTaken reports whether a policy other than excludeID already covers
+	// the category and item type.
+	ScopeTaken(ctx context.Context, category string, itemType string, excludeID uint) (bool, error)
+	Create(ctx context.Context, policy *model.LoanPolicy) error
+	// Update applies changes to the policy with id provided it is still at
+	// version; see updateVersioned.
+	Update(ctx context.Context, id uint, version uint, changes map[string]interface{}) error
+	// Delete removes a policy for good, so its scope can be reused.
+	Delete(ctx context.Context, id uint) error
+}
+
+type gormLoanPolicyRepository struct {
+	db *gorm.DB
+}
+
+func NewLoanPolicyRepository(db *gorm.DB) LoanPolicyRepository {
+	return &gormLoanPolicyRepository{db: db}
+}
+
+func (r *gormLoanPolicyRepository) List(ctx context.Context) (policies []model.LoanPolicy, err error) {
+	err = conn(ctx, r.db).Order("patron_category, item_type").Find(&policies).Error
+	return policies, err
+}
+
+func (r *gormLoanPolicyRepository) FindByID(ctx context.Context, id uint) (policy model.LoanPolicy, err error) {
+	err = conn(ctx, r.db).Where("id = ?", id).First(&policy).Error
+	return policy, translate(err)
+}
+
+func (r *gormLoanPolicyRepository) Resolve(ctx context.Context, category string, itemType string) (policy model.LoanPolicy, err error) {
+	err = conn(ctx, r.db).
+		Where("patron_category IN ? AND item_type IN ?", []string{category, ""}, []string{itemType, ""}).
+		Order("CASE WHEN patron_category = '' THEN 1 ELSE 0 END, CASE WHEN item_type = '' THEN 1 ELSE 0 END").
+		First(&policy).Error
+	return policy, translate(err)
+}
+
+func (r *gormLoanPolicyRepository) ScopeTaken(ctx context.Context, category string, itemType string, excludeID uint) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&model.LoanPolicy{}).
+		Where("patron_category = ? AND item_type = ? AND id <> ?", category, itemType, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormLoanPolicyRepository) Create(ctx context.Context, policy *model.LoanPolicy) error {
+	return conn(ctx, r.db).Create(policy).Error
+}
+
+func (r *gormLoanPolicyRepository) Update(ctx context.Context, id uint, version uint, changes map[string]interface{}) error {
+	return updateVersioned(conn(ctx, r.db), &model.LoanPolicy{}, id, version, changes)
+}
+
+func (r *gormLoanPolicyRepository) Delete(ctx context.Context, id uint) error {
+	result := conn(ctx, r.db).Unscoped().Where("id = ?", id).Delete(&model.LoanPolicy{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
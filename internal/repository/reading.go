@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReadingRepository keeps what users read in digital books: their progress,
+// bookmarks and highlights, per user and book.
+type ReadingRepository interface {
+	FindProgress(ctx context.Context, userID uint, bookID uint) (model.ReadingProgress, error)
+	// CreateProgress stores the first progress of a user in a book, and reports
+	// false when there already is one.
+	CreateProgress(ctx context.Context, progress *model.ReadingProgress) (bool, error)
+	SaveProgress(ctx context.Context, progress *model.ReadingProgress) error
+	ListBookmarks(ctx context.Context, userID uint, bookID uint) ([]model.Bookmark, error)
+	FindBookmark(ctx context.Context, id uint) (model.Bookmark, error)
+	CreateBookmark(ctx context.Context, bookmark *model.Bookmark) error
+	DeleteBookmark(ctx context.Context, id uint) error
+	ListHighlights(ctx context.Context, userID uint, bookID uint) ([]model.Highlight, error)
+	FindHighlight(ctx context.Context, id uint) (model.Highlight, error)
+	CreateHighlight(ctx context.Context, highlight *model.Highlight) error
+	DeleteHighlight(ctx context.Context, id uint) error
+	// Forget deletes the progress, bookmarks and highlights of a user in a
+	// book for good.
+	Forget(ctx context.Context, userID uint, bookID uint) error
+}
+
+type gormReadingRepository struct {
+	db *gorm.DB
+}
+
+func NewReadingRepository(db *gorm.DB) ReadingRepository {
+	return &gormReadingRepository{db: db}
+}
+
+func (r *gormReadingRepository) FindProgress(ctx context.Context, userID uint, bookID uint) (progress model.ReadingProgress, err error) {
+	err = conn(ctx, r.db).Where("user_id = ? AND book_id = ?", userID, bookID).First(&progress).Error
+	return progress, translate(err)
+}
+
+func (r *gormReadingRepository) CreateProgress(ctx context.Context, progress *model.ReadingProgress) (bool, error) {
+	result := conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
+		DoNothing: true,
+	}).Create(progress)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormReadingRepository) SaveProgress(ctx context.Context, progress *model.ReadingProgress) error {
+	return conn(ctx, r.db).Save(progress).Error
+}
+
+func (r *gormReadingRepository) ListBookmarks(ctx context.Context, userID uint, bookID uint) (bookmarks []model.Bookmark, err error) {
+	err = conn(ctx, r.db).Where("user_id = ? AND book_id = ?", userID, bookID).Order("id").Find(&bookmarks).Error
+	return bookmarks, err
+}
+
+func (r *gormReadingRepository) FindBookmark(ctx context.Context, id uint) (bookmark model.Bookmark, err error) {
+	err = conn(ctx, r.db).First(&bookmark, id).Error
+	return bookmark, translate(err)
+}
+
+func (r *gormReadingRepository) CreateBookmark(ctx context.Context, bookmark *model.Bookmark) error {
+	return conn(ctx, r.db).Create(bookmark).Error
+}
+
+func (r *gormReadingRepository) DeleteBookmark(ctx context.Context, id uint) error {
+	return conn(ctx, r.db).Unscoped().Delete(&model.Bookmark{}, id).Error
+}
+
+func (r *gormReadingRepository) ListHighlights(ctx context.Context, userID uint, bookID uint) (highlights []model.Highlight, err error) {
+	err = conn(ctx, r.db).Where("user_id = ? AND book_id = ?", userID, bookID).Order("id").Find(&highlights).Error
+	return highlights, err
+}
+
+func (r *gormReadingRepository) FindHighlight(ctx context.Context, id uint) (highlight model.Highlight, err error) {
+	err = conn(ctx, r.db).First(&highlight, id).Error
+	return highlight, translate(err)
+}
+
+func (r *gormReadingRepository) CreateHighlight(ctx context.Context, highlight *model.Highlight) error {
+	return conn(ctx, r.db).Create(highlight).Error
+}
+
+func (r *gormReadingRepository) DeleteHighlight(ctx context.Context, id uint) error {
+	return conn(ctx, r.db).Unscoped().Delete(&model.Highlight{}, id).Error
+}
+
+func (r *gormReadingRepository) Forget(ctx context.Context, userID uint, bookID uint) error {
+	for _, record := range []interface{}{&model.ReadingProgress{}, &model.Bookmark{}, &model.Highlight{}} {
+		if err := conn(ctx, r.db).Unscoped().Where("user_id = ? AND book_id = ?", userID, bookID).Delete(record).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
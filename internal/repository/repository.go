@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"eLibrary/internal/errs"
+	"errors"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by every repository when the requested record does
+// not exist, regardless of the backing store.
+var ErrNotFound = errs.New(errs.NotFound, "not_found", "record not found")
+
+// Repositories groups the stores the service layer depends on.
+type Repositories struct {
+	Works    WorkRepository
+	Authors  AuthorRepository
+	Books    BookRepository
+	Copies   CopyRepository
+	Files    FileRepository
+	Users    UserRepository
+	Loans    LoanRepository
+	Holds    HoldRepository
+	Fines    FineRepository
+	Policies LoanPolicyRepository
+	Events   EventRepository
+	Reading  ReadingRepository
+	Tx       Transactor
+}
+
+// NewGormRepositories returns GORM-backed implementations of every repository.
+func NewGormRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Works:    NewWorkRepository(db),
+		Authors:  NewAuthorRepository(db),
+		Books:    NewBookRepository(db),
+		Copies:   NewCopyRepository(db),
+		Files:    NewFileRepository(db),
+		Users:    NewUserRepository(db),
+		Loans:    NewLoanRepository(db),
+		Holds:    NewHoldRepository(db),
+		Fines:    NewFineRepository(db),
+		Policies: NewLoanPolicyRepository(db),
+		Events:   NewEventRepository(db),
+		Reading:  NewReadingRepository(db),
+		Tx:       NewTransactor(db),
+	}
+}
+
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/isbn"
+	"eLibrary/model"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BookSearch filters and pages a catalog search. Matching on Query and Author
+// is case-insensitive and partial.
+type BookSearch struct {
+	// Query matches the title, author byline, work authors or ISBN.
+	Query  string
+	Author string
+	// Available restricts results to books with (true) or without (false) an
+	// available copy when set.
+	Available *bool
+	ListOptions
+}
+
+const (
+	availableCopyExists = "EXISTS (SELECT 1 FROM book_copies WHERE book_copies.book_id = book_details.id AND book_copies.status = ? AND book_copies.deleted_at IS NULL)"
+	workAuthorMatches   = "EXISTS (SELECT 1 FROM work_authors JOIN authors ON authors.id = work_authors.author_id WHERE work_authors.work_id = book_details.work_id AND LOWER(authors.name) LIKE ? ESCAPE '\\')"
+)
+
+var bookSortKeys = map[string]sortKey[model.BookDetail]{
+	"id":               {"id", func(b model.BookDetail) interface{} { return b.ID }},
+	"title":            {"title", func(b model.BookDetail) interface{} { return b.Title }},
+	"author":           {"author", func(b model.BookDetail) interface{} { return b.Author }},
+	"publication_year": {"publication_year", func(b model.BookDetail) interface{} { return b.PublicationYear }},
+	"created_at":       {"created_at", func(b model.BookDetail) interface{} { return b.CreatedAt }},
+}
+
+// Search returns one page of books matching search. Unless another sort is
+// requested, searches with a query list the best matches first.
+func (r *gormBookRepository) Search(ctx context.Context, search BookSearch) (Page[model.BookDetail], error) {
+	query := r.searchQuery(ctx, search)
+	if search.Query != "" && search.Sort == "" {
+		return paginateByOffset[model.BookDetail](query, search.ListOptions, withCopyCounts, func(db *gorm.DB) *gorm.DB {
+			return db.Preload("Work.Authors").Order(relevance(search.Query)).
+				Order("book_details.title").Order("book_details.id")
+		})
+	}
+	return paginate(query, "book_details", search.ListOptions, bookSortKeys, bookID, withCopyCounts, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Work.Authors")
+	})
+}
+
+func bookID(b model.BookDetail) uint { return b.ID }
+
+func (r *gormBookRepository) searchQuery(ctx context.Context, search BookSearch) *gorm.DB {
+	query := conn(ctx, r.db).Model(&model.BookDetail{})
+
+	if search.Query != "" {
+		pattern := likePattern(search.Query)
+		isbnPattern := likePattern(isbn.Strip(search.Query))
+		query = query.Where(
+			"LOWER(book_details.title) LIKE ? ESCAPE '\\' OR LOWER(book_details.author) LIKE ? ESCAPE '\\' OR "+
+				"book_details.isbn LIKE ? ESCAPE '\\' OR book_details.isbn10 LIKE ? ESCAPE '\\' OR "+workAuthorMatches,
+			pattern, pattern, isbnPattern, isbnPattern, pattern)
+	}
+
+	if search.Author != "" {
+		pattern := likePattern(search.Author)
+		query = query.Where("LOWER(book_details.author) LIKE ? ESCAPE '\\' OR "+workAuthorMatches, pattern, pattern)
+	}
+
+	if search.Available != nil && *search.Available {
+		query = query.Where(availableCopyExists, model.CopyAvailable)
+	} else if search.Available != nil {
+		query = query.Where("NOT "+availableCopyExists, model.CopyAvailable)
+	}
+
+	return query
+}
+
+// relevance ranks exact title and ISBN matches first, then titles starting
+// with the term, then titles containing it and finally author-only matches.
+func relevance(term string) clause.OrderBy {
+	lower := strings.ToLower(strings.TrimSpace(term))
+	stripped := isbn.Strip(term)
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL: "CASE " +
+			"WHEN LOWER(book_details.title) = ? OR book_details.isbn = ? OR book_details.isbn10 = ? THEN 0 " +
+			"WHEN LOWER(book_details.title) LIKE ? ESCAPE '\\' THEN 1 " +
+			"WHEN LOWER(book_details.title) LIKE ? ESCAPE '\\' THEN 2 " +
+			"ELSE 3 END",
+		Vars:               []interface{}{lower, stripped, stripped, escapeLike(lower) + "%", likePattern(term)},
+		WithoutParentheses: true,
+	}}
+}
+
+func likePattern(term string) string {
+	return "%" + escapeLike(strings.ToLower(strings.TrimSpace(term))) + "%"
+}
+
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserFilter narrows a user listing. Query matches usernames, names and
+// emails partially and case-insensitively.
+type UserFilter struct {
+	Query string
+	Role  model.Role
+	ListOptions
+}
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id uint) (model.User, error)
+	// Lock holds the user's row until the transaction ends, so that changes
+	// checked against what the user already has, like their loans, happen
+	// one at a time.
+	Lock(ctx context.Context, id uint) error
+	FindByUsername(ctx context.Context, username string) (model.User, error)
+	// UsernameTaken reports whether a user other than excludeID, including
+	// deleted users, has the given username.
+	UsernameTaken(ctx context.Context, username string, excludeID uint) (bool, error)
+	List(ctx context.Context, filter UserFilter) (Page[model.User], error)
+	// Export hands the users matching filter to fn a batch at a time.
+	Export(ctx context.Context, filter ExportFilter, fn func([]model.User) error) error
+	Create(ctx context.Context, user *model.User) error
+	// Update applies changes to a user still at version, see ErrVersionConflict.
+	// A zero version skips the check.
+	Update(ctx context.Context, id uint, version uint, changes map[string]interface{}) error
+	Delete(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) error
+}
+
+var userSortKeys = map[string]sortKey[model.User]{
+	"id":         {"id", func(u model.User) interface{} { return u.ID }},
+	"username":   {"username", func(u model.User) interface{} { return u.Username }},
+	"last_name":  {"last_name", func(u model.User) interface{} { return u.LastName }},
+	"created_at": {"created_at", func(u model.User) interface{} { return u.CreatedAt }},
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+func (r *gormUserRepository) FindByID(ctx context.Context, id uint) (user model.User, err error) {
+	err = conn(ctx, r.db).Where("id = ?", id).First(&user).Error
+	return user, translate(err)
+}
+
+func (r *gormUserRepository) Lock(ctx context.Context, id uint) error {
+	var user model.User
+	err := conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", id).First(&user).Error
+	return translate(err)
+}
+
+func (r *gormUserRepository) FindByUsername(ctx context.Context, username string) (user model.User, err error) {
+	err = conn(ctx, r.db).Where("username = ?", username).First(&user).Error
+	return user, translate(err)
+}
+
+func (r *gormUserRepository) UsernameTaken(ctx context.Context, username string, excludeID uint) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Unscoped().Model(&model.User{}).
+		Where("username = ? AND id <> ?", username, excludeID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormUserRepository) List(ctx context.Context, filter UserFilter) (Page[model.User], error) {
+	query := conn(ctx, r.db).Model(&model.User{})
+	if filter.Query != "" {
+		pattern := likePattern(filter.Query)
+		query = query.Where("LOWER(users.username) LIKE ? ESCAPE '\\' OR LOWER(users.first_name) LIKE ? ESCAPE '\\' OR "+
+			"LOWER(users.last_name) LIKE ? ESCAPE '\\' OR LOWER(users.email) LIKE ? ESCAPE '\\'", pattern, pattern, pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("users.role = ?", filter.Role)
+	}
+	return paginate(query, "users", filter.ListOptions, userSortKeys, func(u model.User) uint { return u.ID })
+}
+
+func (r *gormUserRepository) Export(ctx context.Context, filter ExportFilter, fn func([]model.User) error) error {
+	query := filter.between(conn(ctx, r.db).Model(&model.User{}), "users.created_at")
+	return eachBatch(query, "users", func(u model.User) uint { return u.ID }, fn)
+}
+
+func (r *gormUserRepository) Create(ctx context.Context, user *model.User) error {
+	return conn(ctx, r.db).Create(user).Error
+}
+
+func (r *gormUserRepository) Update(ctx context.Context, id uint, version uint, changes map[string]interface{}) error {
+	return updateVersioned(conn(ctx, r.db), &model.User{}, id, version, changes)
+}
+
+func (r *gormUserRepository) Delete(ctx context.Context, id uint) error {
+	return softDelete(conn(ctx, r.db), &model.User{}, id)
+}
+
+func (r *gormUserRepository) Restore(ctx context.Context, id uint) error {
+	return restore(conn(ctx, r.db), &model.User{}, id)
+}
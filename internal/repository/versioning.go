@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"eLibrary/internal/errs"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrVersionConflict is returned when a record changed since the version
+	// the caller based its update on.
+	ErrVersionConflict = errs.New(errs.Stale, "version_conflict", "record was modified by someone else")
+	// ErrNotDeleted is returned when restoring a record that is not deleted.
+	ErrNotDeleted = errs.New(errs.Conflict, "not_deleted", "record is not deleted")
+)
+
+// updateVersioned applies changes to the row of value's table with the given
+// id, provided it is still at version, and bumps its version. A zero version
+// updates unconditionally.
+func updateVersioned(db *gorm.DB, value interface{}, id uint, version uint, changes map[string]interface{}) error {
+	values := map[string]interface{}{"version": gorm.Expr("version + 1")}
+	for column, change := range changes {
+		values[column] = change
+	}
+
+	query := db.Model(value).Where("id = ?", id)
+	if version != 0 {
+		query = query.Where("version = ?", version)
+	}
+	result := query.Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := db.Model(value).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// softDelete marks the row with the given id deleted.
+func softDelete(db *gorm.DB, value interface{}, id uint) error {
+	result := db.Where("id = ?", id).Delete(value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// restore brings back the soft-deleted row with the given id.
+func restore(db *gorm.DB, value interface{}, id uint) error {
+	result := db.Unscoped().Model(value).Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := db.Unscoped().Model(value).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+		return ErrNotDeleted
+	}
+	return nil
+}
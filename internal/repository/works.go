@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"eLibrary/model"
+	"gorm.io/gorm"
+)
+
+type WorkRepository interface {
+	// FindByID returns a work with its authors and editions.
+	FindByID(ctx context.Context, id uint) (model.Work, error)
+	// FindByTitle returns every work with the given title and its authors.
+	FindByTitle(ctx context.Context, title string) ([]model.Work, error)
+	Create(ctx context.Context, work *model.Work) error
+}
+
+type AuthorRepository interface {
+	// FindOrCreate returns the author with the given name, creating it first
+	// when there is none.
+	FindOrCreate(ctx context.Context, name string) (model.Author, error)
+}
+
+type gormWorkRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkRepository(db *gorm.DB) WorkRepository {
+	return &gormWorkRepository{db: db}
+}
+
+func (r *gormWorkRepository) FindByID(ctx context.Context, id uint) (work model.Work, err error) {
+	err = conn(ctx, r.db).Preload("Authors").Preload("Editions", withCopyCounts).
+		Where("id = ?", id).First(&work).Error
+	return work, translate(err)
+}
+
+func (r *gormWorkRepository) FindByTitle(ctx context.Context, title string) (works []model.Work, err error) {
+	err = conn(ctx, r.db).Preload("Authors").Where("title = ?", title).Order("id").Find(&works).Error
+	return works, err
+}
+
+func (r *gormWorkRepository) Create(ctx context.Context, work *model.Work) error {
+	return conn(ctx, r.db).Create(work).Error
+}
+
+type gormAuthorRepository struct {
+	db *gorm.DB
+}
+
+func NewAuthorRepository(db *gorm.DB) AuthorRepository {
+	return &gormAuthorRepository{db: db}
+}
+
+func (r *gormAuthorRepository) FindOrCreate(ctx context.Context, name string) (author model.Author, err error) {
+	err = conn(ctx, r.db).Where(model.Author{Name: name}).FirstOrCreate(&author).Error
+	return author, err
+}
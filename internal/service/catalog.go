@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/repository"
+	"eLibrary/isbn"
+	"eLibrary/model"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GetBooks returns every edition with the given title, or ErrNotFound when
+// there is none.
+func (s *Service) GetBooks(ctx context.Context, title string) (books []model.BookDetail, err error) {
+	if books, err = s.books.FindByTitle(ctx, title); err == nil && len(books) == 0 {
+		err = repository.ErrNotFound
+	}
+	return books, err
+}
+
+func (s *Service) GetBookByID(ctx context.Context, id uint) (model.BookDetail, error) {
+	return s.books.FindByID(ctx, id)
+}
+
+// GetBookByISBN looks an edition up by ISBN-10 or ISBN-13, with or without
+// hyphens.
+func (s *Service) GetBookByISBN(ctx context.Context, value string) (model.BookDetail, error) {
+	if normalized, err := isbn.Normalize(value); err == nil {
+		return s.books.FindByISBN(ctx, normalized)
+	}
+	return s.books.FindByISBN(ctx, isbn.Strip(value))
+}
+
+func (s *Service) GetWork(ctx context.Context, id uint) (model.Work, error) {
+	return s.works.FindByID(ctx, id)
+}
+
+// CreateBook adds an edition to the catalog along with request.AvailableCopies
+// copies carrying generated barcodes. The edition joins request.WorkID when
+// given, otherwise an existing work with the same title and authors, and only
+// failing that a newly created work.
+func (s *Service) CreateBook(ctx context.Context, request model.BookRequest) (book model.BookDetail, err error) {
+	isbn10, isbn13, err := bookISBNs(request)
+	if err != nil {
+		return book, err
+	}
+
+	authors := bookAuthors(request)
+	byline := request.Author
+	if byline == "" {
+		byline = strings.Join(authors, ", ")
+	}
+
+	book = model.BookDetail{
+		Title:           request.Title,
+		Author:          byline,
+		ISBN:            isbn13,
+		ISBN10:          isbn10,
+		ISBN13:          isbn13,
+		Publisher:       request.Publisher,
+		PublicationYear: request.PublicationYear,
+		Language:        request.Language,
+		Format:          request.Format,
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if taken, takenErr := s.books.ISBNTaken(ctx, isbn13, 0); takenErr != nil {
+			return takenErr
+		} else if taken {
+			return errs.DuplicateISBN
+		}
+
+		work, workErr := s.resolveWork(ctx, request.WorkID, request.Title, authors)
+		if workErr != nil {
+			return workErr
+		}
+		book.WorkID = &work.ID
+
+		if createErr := s.books.Create(ctx, &book); createErr != nil {
+			return createErr
+		}
+		book.Work = &work
+
+		for i := 1; i <= request.AvailableCopies; i++ {
+			bookCopy := model.BookCopy{
+				BookID:  book.ID,
+				Barcode: model.CopyBarcode(book.ID, i),
+				Status:  model.CopyAvailable,
+			}
+			if copyErr := s.copies.Create(ctx, &bookCopy); copyErr != nil {
+				return copyErr
+			}
+			book.Copies = append(book.Copies, bookCopy)
+		}
+		return nil
+	})
+
+	book.AvailableCopies = len(book.Copies)
+	book.TotalCopies = len(book.Copies)
+	return book, err
+}
+
+// ReplaceBook overwrites the bibliographic fields of a book at version with
+// those of request. Copies are managed separately, so AvailableCopies is
+// ignored, and the book keeps its work unless request.WorkID moves it.
+func (s *Service) ReplaceBook(ctx context.Context, id uint, version uint, request model.BookRequest) (model.BookDetail, error) {
+	isbn10, isbn13, err := bookISBNs(request)
+	if err != nil {
+		return model.BookDetail{}, err
+	}
+
+	byline := request.Author
+	if byline == "" {
+		byline = strings.Join(bookAuthors(request), ", ")
+	}
+
+	changes := map[string]interface{}{
+		"title":            request.Title,
+		"author":           byline,
+		"isbn":             isbn13,
+		"isbn10":           isbn10,
+		"isbn13":           isbn13,
+		"publisher":        request.Publisher,
+		"publication_year": request.PublicationYear,
+		"language":         request.Language,
+		"format":           request.Format,
+	}
+	return s.updateBook(ctx, id, version, request.WorkID, changes)
+}
+
+// PatchBook changes only the fields of a book at version that are set in
+// patch.
+func (s *Service) PatchBook(ctx context.Context, id uint, version uint, patch model.BookPatch) (model.BookDetail, error) {
+	changes := make(map[string]interface{})
+	if patch.ISBN != nil {
+		isbn10, isbn13, err := bookISBNs(model.BookRequest{ISBN: *patch.ISBN})
+		if err != nil {
+			return model.BookDetail{}, err
+		}
+		changes["isbn"] = isbn13
+		changes["isbn10"] = isbn10
+		changes["isbn13"] = isbn13
+	}
+	if patch.Title != nil {
+		changes["title"] = *patch.Title
+	}
+	if patch.Author != nil {
+		changes["author"] = *patch.Author
+	}
+	if patch.Publisher != nil {
+		changes["publisher"] = *patch.Publisher
+	}
+	if patch.PublicationYear != nil {
+		changes["publication_year"] = *patch.PublicationYear
+	}
+	if patch.Language != nil {
+		changes["language"] = *patch.Language
+	}
+	if patch.Format != nil {
+		changes["format"] = *patch.Format
+	}
+	return s.updateBook(ctx, id, version, patch.WorkID, changes)
+}
+
+func (s *Service) updateBook(ctx context.Context, id uint, version uint, workID *uint, changes map[string]interface{}) (book model.BookDetail, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if isbn13, ok := changes["isbn"].(string); ok {
+			if taken, takenErr := s.books.ISBNTaken(ctx, isbn13, id); takenErr != nil {
+				return takenErr
+			} else if taken {
+				return errs.DuplicateISBN
+			}
+		}
+		if workID != nil {
+			if _, workErr := s.resolveWork(ctx, workID, "", nil); workErr != nil {
+				return workErr
+			}
+			changes["work_id"] = *workID
+		}
+
+		if updateErr := s.books.Update(ctx, id, version, changes); updateErr != nil {
+			return updateErr
+		}
+		book, err = s.books.FindByID(ctx, id)
+		return err
+	})
+	return book, err
+}
+
+// DeleteBook soft-deletes a book, refusing with errs.HasActiveLoans while any
+// of its copies is on loan, and cancels the holds on it. A non-zero version
+// must match the book's.
+func (s *Service) DeleteBook(ctx context.Context, id uint, version uint) error {
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		book, err := s.books.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && book.Version != version {
+			return repository.ErrVersionConflict
+		}
+		if active, err := s.loans.CountActive(ctx, repository.LoanFilter{BookID: id}); err != nil {
+			return err
+		} else if active > 0 {
+			return errs.HasActiveLoans
+		}
+		if err := s.cancelHolds(ctx, id); err != nil {
+			return err
+		}
+		return s.books.Delete(ctx, id)
+	})
+}
+
+// RestoreBook undoes DeleteBook.
+func (s *Service) RestoreBook(ctx context.Context, id uint) (book model.BookDetail, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if restoreErr := s.books.Restore(ctx, id); restoreErr != nil {
+			return restoreErr
+		}
+		book, err = s.books.FindByID(ctx, id)
+		return err
+	})
+	return book, err
+}
+
+func (s *Service) resolveWork(ctx context.Context, workID *uint, title string, authors []string) (work model.Work, err error) {
+	if workID != nil {
+		work, err = s.works.FindByID(ctx, *workID)
+		if errors.Is(err, repository.ErrNotFound) {
+			err = errs.UnknownWork
+		}
+		work.Editions = nil
+		return work, err
+	}
+
+	candidates, err := s.works.FindByTitle(ctx, title)
+	if err != nil {
+		return work, err
+	}
+	for _, candidate := range candidates {
+		if sameAuthors(candidate.Authors, authors) {
+			return candidate, nil
+		}
+	}
+
+	work = model.Work{Title: title}
+	for _, name := range authors {
+		author, authorErr := s.authors.FindOrCreate(ctx, name)
+		if authorErr != nil {
+			return work, authorErr
+		}
+		work.Authors = append(work.Authors, author)
+	}
+	err = s.works.Create(ctx, &work)
+	return work, err
+}
+
+// bookISBNs validates the ISBNs of a request and returns the ISBN-10 (empty
+// when there is no equivalent) and normalized ISBN-13 of the edition. The isbn,
+// isbn_10 and isbn_13 fields may be given in any combination but must all
+// identify the same edition.
+func bookISBNs(request model.BookRequest) (isbn10 string, isbn13 string, err error) {
+	for _, value := range []string{request.ISBN, request.ISBN13, request.ISBN10} {
+		if value == "" {
+			continue
+		}
+		normalized, normalizeErr := isbn.Normalize(value)
+		if normalizeErr != nil {
+			return "", "", fmt.Errorf("%w %q: %v", errs.InvalidISBN, value, normalizeErr)
+		}
+		if isbn13 != "" && normalized != isbn13 {
+			return "", "", fmt.Errorf("%w: %q and %q identify different books", errs.InvalidISBN, isbn13, value)
+		}
+		isbn13 = normalized
+	}
+	if isbn13 == "" {
+		return "", "", fmt.Errorf("%w: isbn is required", errs.InvalidISBN)
+	}
+
+	// only 978-prefixed ISBN-13s have an ISBN-10
+	isbn10, _ = isbn.To10(isbn13)
+	return isbn10, isbn13, nil
+}
+
+// bookAuthors returns the trimmed, de-duplicated author names of a request,
+// falling back to its single author byline.
+func bookAuthors(request model.BookRequest) []string {
+	names := request.Authors
+	if len(names) == 0 && request.Author != "" {
+		names = []string{request.Author}
+	}
+
+	seen := make(map[string]bool)
+	var authors []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			authors = append(authors, name)
+		}
+	}
+	return authors
+}
+
+func sameAuthors(authors []model.Author, names []string) bool {
+	if len(authors) != len(names) {
+		return false
+	}
+	existing := make([]string, 0, len(authors))
+	for _, author := range authors {
+		existing = append(existing, author.Name)
+	}
+	wanted := append([]string(nil), names...)
+	sort.Strings(existing)
+	sort.Strings(wanted)
+	for i := range existing {
+		if existing[i] != wanted[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchBooks returns one page of the catalog matching search.
+func (s *Service) SearchBooks(ctx context.Context, search repository.BookSearch) (repository.Page[model.BookDetail], error) {
+	return s.books.Search(ctx, search)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"eLibrary/internal/errs"
+	"eLibrary/model"
+)
+
+func (s *Service) ListCopies(ctx context.Context, bookID uint) (copies []model.BookCopy, err error) {
+	if _, err = s.books.FindByID(ctx, bookID); err != nil {
+		return copies, err
+	}
+	return s.copies.ListByBook(ctx, bookID)
+}
+
+// AddCopy registers a new physical copy of a book. A barcode is generated when
+// the request does not carry one.
+func (s *Service) AddCopy(ctx context.Context, bookID uint, request model.CopyRequest) (bookCopy model.BookCopy, err error) {
+	status := request.Status
+	if status == "" {
+		status = model.CopyAvailable
+	}
+	if !status.IsValid() || status == model.CopyOnLoan || status == model.CopyOnHold {
+		return bookCopy, errs.InvalidCopyStatus
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if _, findErr := s.books.FindByID(ctx, bookID); findErr != nil {
+			return findErr
+		}
+
+		barcode := request.Barcode
+		if barcode == "" {
+			count, countErr := s.copies.CountByBook(ctx, bookID)
+			if countErr != nil {
+				return countErr
+			}
+			barcode = model.CopyBarcode(bookID, int(count)+1)
+		}
+
+		bookCopy = model.BookCopy{
+			BookID:        bookID,
+			Barcode:       barcode,
+			Condition:     request.Condition,
+			ShelfLocation: request.ShelfLocation,
+			Status:        status,
+		}
+		if createErr := s.copies.Create(ctx, &bookCopy); createErr != nil {
+			return createErr
+		}
+		return s.shelve(ctx, &bookCopy)
+	})
+	return bookCopy, err
+}
+
+// UpdateCopy changes the condition, shelf location or status of a copy. A copy
+// that is out on loan can only be reported lost, the status of a copy set
+// aside for a hold cannot change, and no copy can be put on loan or on hold
+// other than by borrowing it or placing a hold.
+func (s *Service) UpdateCopy(ctx context.Context, barcode string, request model.CopyRequest) (bookCopy model.BookCopy, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var findErr error
+		if bookCopy, findErr = s.copies.FindByBarcode(ctx, barcode); findErr != nil {
+			return findErr
+		}
+
+		if request.Status != "" && request.Status != bookCopy.Status {
+			if !request.Status.IsValid() {
+				return errs.InvalidCopyStatus
+			}
+			if request.Status == model.CopyOnLoan || request.Status == model.CopyOnHold {
+				return errs.CopyStatusLocked
+			}
+			if bookCopy.Status == model.CopyOnLoan && request.Status != model.CopyLost {
+				return errs.CopyStatusLocked
+			}
+			if bookCopy.Status == model.CopyOnHold {
+				return errs.CopyStatusLocked
+			}
+			bookCopy.Status = request.Status
+		}
+		if request.Condition != "" {
+			bookCopy.Condition = request.Condition
+		}
+		if request.ShelfLocation != "" {
+			bookCopy.ShelfLocation = request.ShelfLocation
+		}
+
+		if saveErr := s.copies.Save(ctx, &bookCopy); saveErr != nil {
+			return saveErr
+		}
+		return s.shelve(ctx, &bookCopy)
+	})
+	return bookCopy, err
+}
+
+// shelve offers a copy that became available to the hold queue of its book.
+func (s *Service) shelve(ctx context.Context, bookCopy *model.BookCopy) error {
+	if bookCopy.Status != model.CopyAvailable {
+		return nil
+	}
+	hold, err := s.passOn(ctx, bookCopy.BookID, bookCopy.ID)
+	if hold != nil {
+		bookCopy.Status = model.CopyOnHold
+	}
+	return err
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"eLibrary/config"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/repository"
+	"eLibrary/internal/storage"
+	"eLibrary/model"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"path"
+	"time"
+)
+
+// DefaultMaxUploadSize is the cap on uploaded files of config.Default.
+var DefaultMaxUploadSize = config.Default().Storage.MaxUploadSize
+
+// errNoStorage is returned by the operations on digital items of a service
+// that was not given a blob store.
+var errNoStorage = errors.New("file storage is not configured")
+
+// WithFileStorage keeps the files of digital items in store and hands them
+// out through download links signed by links.
+func WithFileStorage(store storage.Store, links *storage.Signer) Option {
+	return func(s *Service) {
+		s.blobs = store
+		s.links = links
+	}
+}
+
+// WithMaxUploadSize caps the size of uploaded files, in bytes.
+func WithMaxUploadSize(size int64) Option {
+	return func(s *Service) {
+		if size > 0 {
+			s.maxUploadSize = size
+		}
+	}
+}
+
+// DownloadLink grants access to the file of a digital loan until ExpiresAt.
+// Signature covers the loan and the expiry, see storage.Signer.
+type DownloadLink struct {
+	LoanID    uint
+	ExpiresAt time.Time
+	Signature string
+}
+
+// MaxUploadSize returns the size uploaded files are capped at, in bytes.
+func (s *Service) MaxUploadSize() int64 {
+	return s.maxUploadSize
+}
+
+func (s *Service) ListFiles(ctx context.Context, bookID uint) (files []model.DigitalFile, err error) {
+	if _, err = s.books.FindByID(ctx, bookID); err != nil {
+		return files, err
+	}
+	return s.files.ListByBook(ctx, bookID)
+}
+
+// UploadFile stores an EPUB or PDF file of a book, to be lent out under the
+// given number of concurrent licenses. The format is told from the content,
+// not the filename. A book has one file per format: uploading another one
+// replaces the content and license count of the file, while loans made under
+// its earlier licenses stay out.
+func (s *Service) UploadFile(ctx context.Context, bookID uint, filename string, content io.Reader, licenses int) (file model.DigitalFile, err error) {
+	if s.blobs == nil {
+		return file, errNoStorage
+	}
+	if _, err = s.books.FindByID(ctx, bookID); err != nil {
+		return file, err
+	}
+
+	reader := bufio.NewReader(content)
+	header, err := reader.Peek(epubHeaderLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return file, err
+	}
+	format, ok := sniffFormat(header)
+	if !ok {
+		return file, errs.UnsupportedFileType
+	}
+
+	// the content goes to a key of its own, so a replaced file keeps being
+	// served until the new one is recorded
+	key, err := storageKey(bookID, format)
+	if err != nil {
+		return file, err
+	}
+	hash := sha256.New()
+	size, err := s.blobs.Put(ctx, key, io.TeeReader(io.LimitReader(reader, s.maxUploadSize+1), hash))
+	if err != nil {
+		s.discard(ctx, key)
+		return file, err
+	} else if size > s.maxUploadSize {
+		s.discard(ctx, key)
+		return file, errs.FileTooLarge
+	}
+
+	var replaced string
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		existing, findErr := s.files.FindByFormat(ctx, bookID, format)
+		if findErr != nil && !errors.Is(findErr, repository.ErrNotFound) {
+			return findErr
+		}
+
+		file = existing
+		file.BookID = bookID
+		file.Format = format
+		file.Filename = path.Base(filename)
+		file.Size = size
+		file.Checksum = hex.EncodeToString(hash.Sum(nil))
+		file.StorageKey = key
+		file.Licenses = licenses
+		if findErr != nil {
+			return s.files.Create(ctx, &file)
+		}
+		replaced = existing.StorageKey
+		return s.files.Replace(ctx, &file)
+	})
+	if err != nil {
+		s.discard(ctx, key)
+		return file, err
+	}
+	if replaced != "" {
+		s.discard(ctx, replaced)
+	}
+	return file, nil
+}
+
+// BorrowFile lends a license of a digital file to the user. The same fine,
+// policy and loan limit checks apply as to borrowing a copy, but licenses
+// are never set aside for holds.
+func (s *Service) BorrowFile(ctx context.Context, userID uint, fileID uint) (loan model.LoanDetail, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, userErr := s.borrower(ctx, userID)
+		if userErr != nil {
+			return userErr
+		}
+
+		file, fileErr := s.files.FindByID(ctx, fileID)
+		if fileErr != nil {
+			return fileErr
+		}
+		book, bookErr := s.books.FindByID(ctx, file.BookID)
+		if bookErr != nil {
+			return bookErr
+		}
+
+		if _, loanErr := s.loans.FindActive(ctx, book.ID, user.ID); loanErr == nil {
+			return errs.LoanExists
+		} else if !errors.Is(loanErr, repository.ErrNotFound) {
+			return loanErr
+		}
+
+		if claimed, claimErr := s.files.Claim(ctx, file.ID); claimErr != nil {
+			return claimErr
+		} else if !claimed {
+			return errs.NoLicensesAvailable
+		}
+		file.LicensesInUse++
+
+		var loanErr error
+		if loan, loanErr = s.newLoan(ctx, user, book); loanErr != nil {
+			return loanErr
+		}
+		loan.FileID = &file.ID
+		if loanErr = s.loans.Create(ctx, &loan); loanErr != nil {
+			return loanErr
+		}
+		loan.File = &file
+		return nil
+	})
+	return loan, err
+}
+
+// releaseFile gives back the license a returned digital loan took up.
+func (s *Service) releaseFile(ctx context.Context, loan *model.LoanDetail) error {
+	if err := s.files.Release(ctx, *loan.FileID); err != nil {
+		return err
+	}
+	if loan.File != nil && loan.File.LicensesInUse > 0 {
+		loan.File.LicensesInUse--
+	}
+	return nil
+}
+
+// ExpireDigitalLoans returns every digital loan whose return date is not after
+// now, frees its license and records a loan.expired event for it. Download
+// links of the loan stop working along with it. Loans returned since they
+// were listed are skipped, so overlapping or repeated sweeps, e.g. after a
+// restart, expire each loan once. A loan that fails to expire is skipped, see
+// sweep. It returns how many loans expired.
+func (s *Service) ExpireDigitalLoans(ctx context.Context, now time.Time) (expired int, err error) {
+	loans, err := s.loans.ListExpiredDigital(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	return sweep(ctx, s, "expiring digital loans", "loan_id", loans, loanID, s.expireLoan)
+}
+
+func loanID(loan model.LoanDetail) uint {
+	return loan.ID
+}
+
+// expireLoan ends a digital loan as of its return date, and reports false
+// when it was already returned.
+func (s *Service) expireLoan(ctx context.Context, loan *model.LoanDetail) (bool, error) {
+	at := loan.ReturnDate
+	if returned, err := s.loans.MarkReturned(ctx, loan.ID, at); err != nil || !returned {
+		return false, err
+	}
+	loan.IsReturned = true
+	loan.ReturnedAt = &at
+
+	if err := s.releaseFile(ctx, loan); err != nil {
+		return false, err
+	}
+	if err := s.endReading(ctx, loan); err != nil {
+		return false, err
+	}
+	return true, s.events.Create(ctx, &model.Event{
+		Type:       model.EventLoanExpired,
+		LoanID:     &loan.ID,
+		UserID:     &loan.UserID,
+		BookID:     &loan.BookID,
+		OccurredAt: at,
+	})
+}
+
+// DownloadLink signs a link to the file of one of the user's digital loans.
+// The link is valid for the signer's lifetime but never beyond the return
+// date, and stops working as soon as the loan is returned.
+func (s *Service) DownloadLink(ctx context.Context, loanID uint, userID uint) (link DownloadLink, err error) {
+	if s.links == nil {
+		return link, errNoStorage
+	}
+	loan, err := s.loans.FindByID(ctx, loanID)
+	if err != nil {
+		return link, err
+	}
+
+	now := time.Now()
+	switch {
+	case loan.UserID != userID:
+		return link, errs.NotAllowed
+	case loan.FileID == nil:
+		return link, errs.NotDigitalLoan
+	case loan.IsReturned:
+		return link, errs.LoanReturned
+	case !now.Before(loan.ReturnDate):
+		return link, errs.LoanExpired
+	}
+
+	expires := now.Add(s.links.TTL()).Truncate(time.Second)
+	if expires.After(loan.ReturnDate) {
+		expires = loan.ReturnDate.Truncate(time.Second)
+	}
+	return DownloadLink{LoanID: loan.ID, ExpiresAt: expires, Signature: s.links.Sign(loan.ID, expires)}, nil
+}
+
+// OpenDownload checks a download link and opens the file of its loan, which
+// must still be out. The caller closes the content.
+func (s *Service) OpenDownload(ctx context.Context, link DownloadLink) (file model.DigitalFile, content io.ReadCloser, err error) {
+	if s.blobs == nil || s.links == nil {
+		return file, nil, errNoStorage
+	}
+	now := time.Now()
+	if err = s.links.Verify(link.LoanID, link.ExpiresAt, link.Signature, now); err != nil {
+		return file, nil, err
+	}
+
+	loan, err := s.loans.FindByID(ctx, link.LoanID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return file, nil, errs.InvalidDownloadLink
+	} else if err != nil {
+		return file, nil, err
+	}
+	if loan.IsReturned || loan.File == nil || !now.Before(loan.ReturnDate) {
+		return file, nil, errs.InvalidDownloadLink
+	}
+
+	file = *loan.File
+	content, err = s.blobs.Open(ctx, file.StorageKey)
+	return file, content, err
+}
+
+// discard deletes a blob that is no longer referenced. Failing to is only
+// logged, as it leaves nothing but an orphaned blob behind.
+func (s *Service) discard(ctx context.Context, key string) {
+	if err := s.blobs.Delete(ctx, key); err != nil {
+		log.WithError(err).WithField("key", key).Warn("Failed to delete stored file")
+	}
+}
+
+// epubHeaderLen is how much of a file sniffFormat needs to recognize an EPUB.
+const epubHeaderLen = 58
+
+// sniffFormat tells the format of a file from its first bytes. PDFs start
+// with "%PDF-"; EPUBs are ZIP archives whose first, uncompressed entry is a
+// file named "mimetype" holding "application/epub+zip".
+func sniffFormat(header []byte) (model.EbookFormat, bool) {
+	switch {
+	case bytes.HasPrefix(header, []byte("%PDF-")):
+		return model.EbookPDF, true
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) && len(header) >= epubHeaderLen &&
+		string(header[30:epubHeaderLen]) == "mimetypeapplication/epub+zip":
+		return model.EbookEPUB, true
+	}
+	return "", false
+}
+
+// storageKey returns a fresh key for a file of a book.
+func storageKey(bookID uint, format model.EbookFormat) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("generating storage key: %w", err)
+	}
+	return fmt.Sprintf("books/%d/%s.%s", bookID, hex.EncodeToString(random), format), nil
+}
@@ -1,121 +1,424 @@
 package service
 
 import (
-	"eLibrary/global"
+	"context"
+	"eLibrary/config"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/repository"
+	"eLibrary/internal/storage"
 	"eLibrary/model"
 	"errors"
 	"fmt"
-	"gorm.io/gorm"
 	"time"
 )
 
-func GetBook(title string) (book model.BookDetail, err error) {
-	err = global.Database.Where("title = ?", title).First(&book).Error
-	return book, err
+// The lending defaults are those of config.Default, so that a service built
+// without options behaves like one configured with none.
+var (
+	// DefaultHoldPickupWindow is how long a copy stays set aside for a ready
+	// hold.
+	DefaultHoldPickupWindow = config.Default().Lending.HoldPickupWindow.Duration
+	// DefaultDueSoonWindow is how long before its return date a loan is
+	// flagged as due soon.
+	DefaultDueSoonWindow = config.Default().Lending.DueSoonWindow.Duration
+)
+
+type Service struct {
+	works    repository.WorkRepository
+	authors  repository.AuthorRepository
+	books    repository.BookRepository
+	copies   repository.CopyRepository
+	files    repository.FileRepository
+	users    repository.UserRepository
+	loans    repository.LoanRepository
+	holds    repository.HoldRepository
+	fines    repository.FineRepository
+	policies repository.LoanPolicyRepository
+	events   repository.EventRepository
+	reading  repository.ReadingRepository
+	tx       repository.Transactor
+
+	blobs         storage.Store
+	links         *storage.Signer
+	maxUploadSize int64
+
+	holdPickupWindow time.Duration
+	dueSoonWindow    time.Duration
+	finePolicy       FinePolicy
 }
 
-func BorrowBook(request model.LoanRequest) (loan model.LoanDetail, err error) {
-	book := model.BookDetail{}
-	bookErr := global.Database.Where("title = ? AND available_copies > 0", request.Title).First(&book).Error
-	if bookErr != nil {
-		return loan, bookErr
+// Option tunes the lending rules of a Service.
+type Option func(*Service)
+
+// WithHoldPickupWindow sets how long a returned copy waits for the patron at
+// the front of the hold queue.
+func WithHoldPickupWindow(window time.Duration) Option {
+	return func(s *Service) {
+		if window > 0 {
+			s.holdPickupWindow = window
+		}
 	}
+}
 
-	user := model.User{}
-	userErr := global.Database.Where("id = ?", request.UserId).First(&user).Error
-	if userErr != nil && errors.Is(userErr, gorm.ErrRecordNotFound) {
-		return loan, errors.New("user not found")
-	} else if userErr != nil {
-		return loan, userErr
+// WithDueSoonWindow sets how long before its return date a loan is flagged
+// as due soon.
+func WithDueSoonWindow(window time.Duration) Option {
+	return func(s *Service) {
+		if window > 0 {
+			s.dueSoonWindow = window
+		}
 	}
+}
+
+func New(repos repository.Repositories, options ...Option) *Service {
+	s := &Service{
+		works:    repos.Works,
+		authors:  repos.Authors,
+		books:    repos.Books,
+		copies:   repos.Copies,
+		files:    repos.Files,
+		users:    repos.Users,
+		loans:    repos.Loans,
+		holds:    repos.Holds,
+		fines:    repos.Fines,
+		policies: repos.Policies,
+		events:   repos.Events,
+		reading:  repos.Reading,
+		tx:       repos.Tx,
+
+		maxUploadSize: DefaultMaxUploadSize,
 
-	loan = model.LoanDetail{}
-	loanErr := global.Database.Where("book_id = ? AND user_id = ? AND is_returned = ?", book.ID, user.ID, false).First(&loan).Error
-	if loanErr == nil {
-		return loan, errors.New("a loan for this book exists")
+		holdPickupWindow: DefaultHoldPickupWindow,
+		dueSoonWindow:    DefaultDueSoonWindow,
+		finePolicy:       DefaultFinePolicy,
 	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
 
-	book.AvailableCopies = book.AvailableCopies - 1
-	global.Database.Save(&book)
+// BorrowBook lends a copy of the book with the given title to the user. A copy
+// set aside for one of the user's ready holds is lent before any other.
+func (s *Service) BorrowBook(ctx context.Context, userID uint, title string) (loan model.LoanDetail, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, userErr := s.borrower(ctx, userID)
+		if userErr != nil {
+			return userErr
+		}
 
-	loan = model.LoanDetail{
-		BookDetail:     book,
-		User:           user,
-		NameOfBorrower: fmt.Sprintf("%s %s", user.FirstName, user.LastName),
-		LoanDate:       time.Now(),
-		ReturnDate:     time.Now().AddDate(0, 0, 28),
-		IsReturned:     false,
+		if hold, holdErr := s.holds.FindReadyByTitle(ctx, userID, title); holdErr == nil {
+			var lendErr error
+			loan, lendErr = s.lendHeld(ctx, user, hold)
+			return lendErr
+		} else if !errors.Is(holdErr, repository.ErrNotFound) {
+			return holdErr
+		}
+
+		book, bookErr := s.books.FindAvailableByTitle(ctx, title)
+		if bookErr != nil && errors.Is(bookErr, repository.ErrNotFound) {
+			return errs.NoCopiesAvailable
+		} else if bookErr != nil {
+			return bookErr
+		}
+
+		if _, loanErr := s.loans.FindActive(ctx, book.ID, user.ID); loanErr == nil {
+			return errs.LoanExists
+		}
+
+		// claiming is a conditional update on the copy's status, so of several
+		// concurrent borrowers only one can take the last copy
+		bookCopy, claimErr := s.copies.Claim(ctx, book.ID)
+		if claimErr != nil && errors.Is(claimErr, repository.ErrNotFound) {
+			return errs.NoCopiesAvailable
+		} else if claimErr != nil {
+			return claimErr
+		}
+		book.AvailableCopies = book.AvailableCopies - 1
+
+		var lendErr error
+		loan, lendErr = s.lend(ctx, user, book, bookCopy)
+		return lendErr
+	})
+	return loan, err
+}
+
+// borrower returns the user with userID, provided they do not owe too much
+// to borrow.
+func (s *Service) borrower(ctx context.Context, userID uint) (user model.User, err error) {
+	if user, err = s.users.FindByID(ctx, userID); errors.Is(err, repository.ErrNotFound) {
+		return user, errs.UserNotFound
+	} else if err != nil {
+		return user, err
 	}
 
-	result := global.Database.Create(&loan)
-	return loan, result.Error
+	if balance, balanceErr := s.fines.Balance(ctx, user.ID); balanceErr != nil {
+		return user, balanceErr
+	} else if balance > s.finePolicy.BlockThreshold {
+		return user, fmt.Errorf("%w: %d owed", errs.FinesOutstanding, balance)
+	}
+	return user, nil
 }
 
-func ExtendBook(userId int, title string) (loan model.LoanDetail, err error) {
-	if loan, err = findLoan(userId, title); err != nil {
+// lendHeld lends the copy set aside for a ready hold to its patron.
+func (s *Service) lendHeld(ctx context.Context, user model.User, hold model.Hold) (loan model.LoanDetail, err error) {
+	book, err := s.books.FindByID(ctx, hold.BookID)
+	if err != nil {
 		return loan, err
 	}
 
-	loan.ReturnDate = loan.ReturnDate.AddDate(0, 0, 21)
-	result := global.Database.Save(&loan)
+	if claimed, claimErr := s.copies.Transition(ctx, *hold.CopyID, model.CopyOnHold, model.CopyOnLoan); claimErr != nil {
+		return loan, claimErr
+	} else if !claimed {
+		return loan, errs.NoCopiesAvailable
+	}
+	bookCopy := model.BookCopy{BookID: book.ID, Status: model.CopyOnLoan}
+	bookCopy.ID = *hold.CopyID
 
-	return loan, result.Error
+	return s.lend(ctx, user, book, bookCopy)
 }
 
-func ReturnBook(userId int, title string) (loan model.LoanDetail, err error) {
-	loan = model.LoanDetail{}
+// lend records the loan of a claimed copy and fulfils any hold the user had on
+// the book.
+func (s *Service) lend(ctx context.Context, user model.User, book model.BookDetail, bookCopy model.BookCopy) (loan model.LoanDetail, err error) {
+	if loan, err = s.newLoan(ctx, user, book); err != nil {
+		return loan, err
+	}
+
+	if hold, holdErr := s.holds.FindActive(ctx, book.ID, user.ID); holdErr == nil {
+		from := hold.Status
+		hold.Status = model.HoldFulfilled
+		if _, err = s.holds.Transition(ctx, &hold, from); err != nil {
+			return loan, err
+		}
+	} else if !errors.Is(holdErr, repository.ErrNotFound) {
+		return loan, holdErr
+	}
 
-	err = global.Database.Preload("User").Preload("BookDetail").
-		Joins("JOIN book_details ON book_details.id = loan_details.book_id").
-		Where("book_details.title = ? AND loan_details.user_id = ? AND is_returned = ?", title, userId, false).
-		First(&loan).Error
-	if err != nil {
+	loan.CopyID = &bookCopy.ID
+	if err = s.loans.Create(ctx, &loan); err != nil {
 		return loan, err
 	}
+	loan.Copy = &bookCopy
+	return loan, nil
+}
 
-	loan.IsReturned = true
-	result := global.Database.Save(&loan)
-	if result.Error != nil {
-		return loan, result.Error
+// newLoan prepares, without recording it, a loan of a book to a user under
+// the loan policy that applies to them, provided it would not take them over
+// the policy's concurrent loans.
+func (s *Service) newLoan(ctx context.Context, user model.User, book model.BookDetail) (loan model.LoanDetail, err error) {
+	policy, err := s.loanPolicy(ctx, user, book)
+	if err != nil {
+		return loan, err
 	}
+	if policy.MaxConcurrentLoans > 0 {
+		// without the lock two borrows could both count the same loans
+		if err = s.users.Lock(ctx, user.ID); err != nil {
+			return loan, err
+		}
+		active, countErr := s.loans.CountActive(ctx, repository.LoanFilter{UserID: user.ID})
+		if countErr != nil {
+			return loan, countErr
+		} else if active >= int64(policy.MaxConcurrentLoans) {
+			return loan, errs.LoanLimitReached
+		}
+	}
+
+	return model.LoanDetail{
+		BookDetail:     book,
+		User:           user,
+		NameOfBorrower: fmt.Sprintf("%s %s", user.FirstName, user.LastName),
+		LoanDate:       time.Now(),
+		ReturnDate:     time.Now().AddDate(0, 0, policy.LoanDays),
+		IsReturned:     false,
+	}, nil
+}
 
-	loan.BookDetail.AvailableCopies = loan.BookDetail.AvailableCopies + 1
-	saveBook := global.Database.Save(&loan.BookDetail)
+// ExtendBook renews the user's active loan of the book with the given title
+// on their own behalf.
+func (s *Service) ExtendBook(ctx context.Context, userID uint, title string) (loan model.LoanDetail, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var findErr error
+		if loan, findErr = s.loans.FindByTitle(ctx, userID, title, true); errors.Is(findErr, repository.ErrNotFound) {
+			return errs.NoActiveLoan
+		} else if findErr != nil {
+			return findErr
+		}
+		return s.renew(ctx, &loan, userID)
+	})
+	return loan, err
+}
 
-	return loan, saveBook.Error
+// RenewLoan renews a loan on behalf of the user with id by, who may be the
+// borrower or staff.
+func (s *Service) RenewLoan(ctx context.Context, id uint, by uint) (loan model.LoanDetail, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var findErr error
+		if loan, findErr = s.loans.FindByID(ctx, id); findErr != nil {
+			return findErr
+		}
+		return s.renew(ctx, &loan, by)
+	})
+	return loan, err
 }
 
-func CreateBook(detail model.BookDetail) (book model.BookDetail, err error) {
-	book = model.BookDetail{
-		Title:           detail.Title,
-		Author:          detail.Author,
-		ISBN:            detail.ISBN,
-		AvailableCopies: detail.AvailableCopies,
+func (s *Service) GetLoan(ctx context.Context, id uint) (loan model.LoanDetail, err error) {
+	if loan, err = s.loans.FindByID(ctx, id); err == nil {
+		s.flagLoan(&loan, time.Now())
 	}
-
-	result := global.Database.Create(&book)
-	return book, result.Error
+	return loan, err
 }
 
-func CreateUser(firstName string, lastName string, username string, email string) (user model.User, err error) {
-	user = model.User{
-		FirstName: firstName,
-		LastName:  lastName,
-		Username:  username,
-		Email:     email,
+// renew extends a loan by the renewal period of its policy, provided it is
+// still out, not overdue beyond the grace period, under the renewal limit and,
+// if the policy says so, nobody is waiting for the book.
+func (s *Service) renew(ctx context.Context, loan *model.LoanDetail, by uint) error {
+	if loan.IsReturned {
+		return errs.LoanReturned
+	}
+	policy, err := s.loanPolicy(ctx, loan.User, loan.BookDetail)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if loan.FileID != nil && !now.Before(loan.ReturnDate) {
+		// digital loans have no grace period, they expire at their return date
+		return errs.LoanExpired
+	}
+	if now.After(loan.ReturnDate.AddDate(0, 0, policy.RenewalGraceDays)) {
+		return errs.LoanOverdue
+	}
+	if loan.Renewals >= policy.MaxRenewals {
+		return errs.RenewalLimitReached
+	}
+	if policy.BlockRenewalOnHolds {
+		if queue, queueErr := s.holds.Queue(ctx, loan.BookID); queueErr != nil {
+			return queueErr
+		} else if len(queue) > 0 {
+			return errs.RenewalBlocked
+		}
 	}
 
-	result := global.Database.Create(&user)
-	return user, result.Error
+	renewal := model.LoanRenewal{
+		LoanID:             loan.ID,
+		RenewedBy:          by,
+		RenewedAt:          now,
+		PreviousReturnDate: loan.ReturnDate,
+		NewReturnDate:      loan.ReturnDate.AddDate(0, 0, policy.RenewalDays),
+	}
+	if renewed, renewErr := s.loans.Renew(ctx, &renewal, loan.Renewals); renewErr != nil {
+		return renewErr
+	} else if !renewed {
+		return errs.LoanReturned
+	}
+	loan.Renewals++
+	loan.ReturnDate = renewal.NewReturnDate
+	loan.RenewalHistory = append(loan.RenewalHistory, renewal)
+	return nil
 }
 
-func findLoan(userId int, title string) (loan model.LoanDetail, err error) {
-	loan = model.LoanDetail{}
+func (s *Service) ReturnBook(ctx context.Context, userID uint, title string) (loan model.LoanDetail, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var findErr error
+		if loan, findErr = s.loans.FindByTitle(ctx, userID, title, true); errors.Is(findErr, repository.ErrNotFound) {
+			return errs.NoActiveLoan
+		} else if findErr != nil {
+			return findErr
+		}
+
+		now := time.Now()
+		if returned, returnErr := s.loans.MarkReturned(ctx, loan.ID, now); returnErr != nil {
+			return returnErr
+		} else if !returned {
+			return errs.NoActiveLoan
+		}
+		loan.IsReturned = true
+		loan.ReturnedAt = &now
+
+		// digital loans give back their license; they are never late, so
+		// there is no fine and no copy to pass on
+		if loan.FileID != nil {
+			if releaseErr := s.releaseFile(ctx, &loan); releaseErr != nil {
+				return releaseErr
+			}
+			return s.endReading(ctx, &loan)
+		}
+
+		// loans made before copies were tracked have no copy to put back, the
+		// returned item becomes a tracked copy instead
+		if loan.CopyID == nil {
+			count, countErr := s.copies.CountByBook(ctx, loan.BookID)
+			if countErr != nil {
+				return countErr
+			}
+			loan.Copy = &model.BookCopy{
+				BookID:  loan.BookID,
+				Barcode: model.CopyBarcode(loan.BookID, int(count)+1),
+				Status:  model.CopyAvailable,
+			}
+			if copyErr := s.copies.Create(ctx, loan.Copy); copyErr != nil {
+				return copyErr
+			}
+			loan.CopyID = &loan.Copy.ID
+			if saveErr := s.loans.Save(ctx, &loan); saveErr != nil {
+				return saveErr
+			}
+		} else if copyErr := s.copies.Release(ctx, *loan.CopyID); copyErr != nil {
+			return copyErr
+		}
 
-	err = global.Database.Preload("User").Preload("BookDetail").
-		Joins("JOIN book_details ON book_details.id = loan_details.book_id").
-		Where("book_details.title = ? AND loan_details.user_id = ?", title, userId).
-		First(&loan).Error
+		hold, passErr := s.passOn(ctx, loan.BookID, *loan.CopyID)
+		if passErr != nil {
+			return passErr
+		}
+		status := model.CopyAvailable
+		if hold != nil {
+			status = model.CopyOnHold
+		} else {
+			loan.BookDetail.AvailableCopies = loan.BookDetail.AvailableCopies + 1
+		}
+		if loan.Copy != nil {
+			loan.Copy.Status = status
+		}
 
+		_, fineErr := s.accrueFine(ctx, &loan, now, true)
+		return fineErr
+	})
 	return loan, err
 }
+
+func (s *Service) ListUsers(ctx context.Context, filter repository.UserFilter) (repository.Page[model.User], error) {
+	return s.users.List(ctx, filter)
+}
+
+func (s *Service) ListLoans(ctx context.Context, filter repository.LoanFilter) (repository.Page[model.LoanDetail], error) {
+	page, err := s.loans.List(ctx, filter)
+	now := time.Now()
+	for i := range page.Items {
+		s.flagLoan(&page.Items[i], now)
+	}
+	return page, err
+}
+
+// ListUserLoans lists the loans of one user, current and past.
+func (s *Service) ListUserLoans(ctx context.Context, userID uint, filter repository.LoanFilter) (repository.Page[model.LoanDetail], error) {
+	if _, err := s.users.FindByID(ctx, userID); err != nil {
+		return repository.Page[model.LoanDetail]{}, err
+	}
+	filter.UserID = userID
+	return s.ListLoans(ctx, filter)
+}
+
+// DueSoonWindow is how long before its return date a loan counts as due soon.
+func (s *Service) DueSoonWindow() time.Duration {
+	return s.dueSoonWindow
+}
+
+func (s *Service) flagLoan(loan *model.LoanDetail, now time.Time) {
+	if loan.IsReturned {
+		return
+	}
+	loan.Overdue = now.After(loan.ReturnDate)
+	loan.DueSoon = !loan.Overdue && loan.ReturnDate.Before(now.Add(s.dueSoonWindow))
+}
@@ -0,0 +1,13 @@
+package service
+
+import (
+	"context"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+)
+
+// ListEvents pages through the event log, oldest first unless sorted
+// otherwise.
+func (s *Service) ListEvents(ctx context.Context, filter repository.EventFilter) (repository.Page[model.Event], error) {
+	return s.events.List(ctx, filter)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+	"time"
+)
+
+// ExportBooks hands the books matching filter to fn a batch at a time, in the
+// order they were added, so that exports of any size run in constant memory;
+// ExportUsers and ExportLoans do the same for users and loans. Batches are
+// read one after another rather than from a snapshot: a record changed while
+// a long export runs shows up as it was when its batch was read.
+func (s *Service) ExportBooks(ctx context.Context, filter repository.ExportFilter, fn func([]model.BookDetail) error) error {
+	return s.books.Export(ctx, filter, fn)
+}
+
+func (s *Service) ExportUsers(ctx context.Context, filter repository.ExportFilter, fn func([]model.User) error) error {
+	return s.users.Export(ctx, filter, fn)
+}
+
+// ExportLoans flags the loans it hands to fn as overdue or due soon like
+// ListLoans does.
+func (s *Service) ExportLoans(ctx context.Context, filter repository.ExportFilter, fn func([]model.LoanDetail) error) error {
+	return s.loans.Export(ctx, filter, func(loans []model.LoanDetail) error {
+		now := time.Now()
+		for i := range loans {
+			s.flagLoan(&loans[i], now)
+		}
+		return fn(loans)
+	})
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"eLibrary/config"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+	"errors"
+	"time"
+)
+
+// FinePolicy prices late returns. Amounts are in minor currency units.
+type FinePolicy struct {
+	// DailyRate is charged for every started day a loan is kept past its
+	// return date.
+	DailyRate int64
+	// MaxPerItem caps the fine of a single loan; zero leaves it uncapped.
+	MaxPerItem int64
+	// BlockThreshold is the outstanding balance above which a user may not
+	// borrow.
+	BlockThreshold int64
+}
+
+// NewFinePolicy returns the fine policy set by cfg.
+func NewFinePolicy(cfg config.FinesConfig) FinePolicy {
+	return FinePolicy{DailyRate: cfg.DailyRate, MaxPerItem: cfg.MaxPerItem, BlockThreshold: cfg.BlockThreshold}
+}
+
+// DefaultFinePolicy is the fine policy of config.Default.
+var DefaultFinePolicy = NewFinePolicy(config.Default().Fines)
+
+// WithFinePolicy sets how late returns are charged.
+func WithFinePolicy(policy FinePolicy) Option {
+	return func(s *Service) {
+		s.finePolicy = policy
+	}
+}
+
+// assess returns how many days a loan due at due is overdue at, and the fine
+// for it.
+func (p FinePolicy) assess(due time.Time, at time.Time) (days int, amount int64) {
+	late := at.Sub(due)
+	if late <= 0 {
+		return 0, 0
+	}
+	days = int((late + 24*time.Hour - 1) / (24 * time.Hour))
+	amount = int64(days) * p.DailyRate
+	if p.MaxPerItem > 0 && amount > p.MaxPerItem {
+		amount = p.MaxPerItem
+	}
+	return days, amount
+}
+
+func (s *Service) ListFines(ctx context.Context, filter repository.FineFilter) (repository.Page[model.Fine], error) {
+	return s.fines.List(ctx, filter)
+}
+
+// FineBalance returns what a user owes and whether that stops them borrowing.
+func (s *Service) FineBalance(ctx context.Context, userID uint) (balance int64, blocked bool, err error) {
+	if _, err = s.users.FindByID(ctx, userID); err != nil {
+		return 0, false, err
+	}
+	balance, err = s.fines.Balance(ctx, userID)
+	return balance, balance > s.finePolicy.BlockThreshold, err
+}
+
+// PayFine records a payment of amount towards a fine, taken by the staff
+// member with id by. A fine paid off while its loan is still out reopens as
+// it keeps accruing.
+func (s *Service) PayFine(ctx context.Context, id uint, amount int64, by uint) (fine model.Fine, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var findErr error
+		if fine, findErr = s.fines.FindByID(ctx, id); findErr != nil {
+			return findErr
+		}
+		if outstanding := fine.Outstanding(); outstanding == 0 {
+			return errs.FineSettled
+		} else if amount > outstanding {
+			return errs.Overpayment
+		}
+
+		fine.Paid += amount
+		if fine.Outstanding() == 0 {
+			settle(&fine, model.FinePaid, by)
+		}
+		return s.fines.Save(ctx, &fine)
+	})
+	return fine, err
+}
+
+// WaiveFine forgives what is left of a fine, including anything its loan
+// would still accrue.
+func (s *Service) WaiveFine(ctx context.Context, id uint, reason string, by uint) (fine model.Fine, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var findErr error
+		if fine, findErr = s.fines.FindByID(ctx, id); findErr != nil {
+			return findErr
+		}
+		if fine.Outstanding() == 0 {
+			return errs.FineSettled
+		}
+
+		settle(&fine, model.FineWaived, by)
+		fine.Note = reason
+		return s.fines.Save(ctx, &fine)
+	})
+	return fine, err
+}
+
+func settle(fine *model.Fine, status model.FineStatus, by uint) {
+	now := time.Now()
+	fine.Status = status
+	fine.SettledAt = &now
+	fine.SettledBy = &by
+}
+
+// AccrueFines brings the fines of every loan still out past its return date up
+// to now. A loan whose fine fails to accrue is skipped, see sweep. It returns
+// how many fines were raised or opened.
+func (s *Service) AccrueFines(ctx context.Context, now time.Time) (accrued int, err error) {
+	loans, err := s.loans.ListOverdue(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	return sweep(ctx, s, "accruing fines", "loan_id", loans, loanID, func(ctx context.Context, loan *model.LoanDetail) (bool, error) {
+		return s.accrueFine(ctx, loan, now, false)
+	})
+}
+
+// accrueFine charges a loan for being overdue at the given time, opening its
+// fine or raising the amount of the existing one, and attaches the fine to the
+// loan. Final fines, for returned loans, are never changed again.
+func (s *Service) accrueFine(ctx context.Context, loan *model.LoanDetail, at time.Time, final bool) (bool, error) {
+	days, amount := s.finePolicy.assess(loan.ReturnDate, at)
+
+	fine, err := s.fines.FindByLoan(ctx, loan.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		if amount == 0 {
+			return false, nil
+		}
+		fine = model.Fine{
+			LoanID:      loan.ID,
+			UserID:      loan.UserID,
+			DaysOverdue: days,
+			Amount:      amount,
+			Status:      model.FineOutstanding,
+			Final:       final,
+		}
+		if err := s.fines.Create(ctx, &fine); err != nil {
+			return false, err
+		}
+		loan.Fine = &fine
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	loan.Fine = &fine
+	if fine.Final {
+		return false, nil
+	}
+	// a fine only ever grows, and a waived one is not charged any further
+	raised := fine.Status != model.FineWaived && amount > fine.Amount
+	if raised {
+		fine.DaysOverdue = days
+		fine.Amount = amount
+		if fine.Status == model.FinePaid {
+			fine.Status = model.FineOutstanding
+			fine.SettledAt = nil
+			fine.SettledBy = nil
+		}
+	}
+	if !raised && !final {
+		return false, nil
+	}
+	fine.Final = final
+	return raised, s.fines.Save(ctx, &fine)
+}
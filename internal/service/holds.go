@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+	"errors"
+	"time"
+)
+
+// PlaceHold queues the user for the next copy of a book that has none
+// available.
+func (s *Service) PlaceHold(ctx context.Context, userID uint, bookID uint) (hold model.Hold, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		book, findErr := s.books.FindByID(ctx, bookID)
+		if findErr != nil {
+			return findErr
+		}
+		if _, userErr := s.users.FindByID(ctx, userID); userErr != nil && errors.Is(userErr, repository.ErrNotFound) {
+			return errs.UserNotFound
+		} else if userErr != nil {
+			return userErr
+		}
+
+		if book.AvailableCopies > 0 {
+			return errs.CopiesAvailable
+		}
+		if _, holdErr := s.holds.FindActive(ctx, bookID, userID); holdErr == nil {
+			return errs.HoldExists
+		} else if !errors.Is(holdErr, repository.ErrNotFound) {
+			return holdErr
+		}
+		if _, loanErr := s.loans.FindActive(ctx, bookID, userID); loanErr == nil {
+			return errs.LoanExists
+		}
+
+		position, positionErr := s.holds.NextPosition(ctx, bookID)
+		if positionErr != nil {
+			return positionErr
+		}
+		hold = model.Hold{
+			BookID:   bookID,
+			UserID:   userID,
+			Position: position,
+			Status:   model.HoldWaiting,
+		}
+		if createErr := s.holds.Create(ctx, &hold); createErr != nil {
+			return createErr
+		}
+		hold.Book = &book
+		return nil
+	})
+	return hold, err
+}
+
+func (s *Service) GetHold(ctx context.Context, id uint) (model.Hold, error) {
+	return s.holds.FindByID(ctx, id)
+}
+
+func (s *Service) ListHolds(ctx context.Context, filter repository.HoldFilter) (repository.Page[model.Hold], error) {
+	return s.holds.List(ctx, filter)
+}
+
+// HoldQueue returns the waiting holds on a book in the order they are served.
+func (s *Service) HoldQueue(ctx context.Context, bookID uint) ([]model.Hold, error) {
+	if _, err := s.books.FindByID(ctx, bookID); err != nil {
+		return nil, err
+	}
+	return s.holds.Queue(ctx, bookID)
+}
+
+// CancelHold withdraws a waiting or ready hold. The copy set aside for a ready
+// hold goes to the next patron in line.
+func (s *Service) CancelHold(ctx context.Context, id uint) (hold model.Hold, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var findErr error
+		if hold, findErr = s.holds.FindByID(ctx, id); findErr != nil {
+			return findErr
+		}
+		return s.endHold(ctx, &hold, model.HoldCancelled)
+	})
+	return hold, err
+}
+
+// MoveHold puts a waiting hold at the given 1-based position of its book's
+// queue, shifting the holds in between.
+func (s *Service) MoveHold(ctx context.Context, id uint, position int) (hold model.Hold, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var findErr error
+		if hold, findErr = s.holds.FindByID(ctx, id); findErr != nil {
+			return findErr
+		}
+		if hold.Status != model.HoldWaiting {
+			return errs.HoldNotActive
+		}
+
+		queue, queueErr := s.holds.Queue(ctx, hold.BookID)
+		if queueErr != nil {
+			return queueErr
+		}
+		reordered := make([]model.Hold, 0, len(queue))
+		for _, queued := range queue {
+			if queued.ID != hold.ID {
+				reordered = append(reordered, queued)
+			}
+		}
+		index := min(max(position, 1), len(reordered)+1) - 1
+		reordered = append(reordered[:index], append([]model.Hold{hold}, reordered[index:]...)...)
+
+		for i := range reordered {
+			if reordered[i].Position == i+1 {
+				continue
+			}
+			reordered[i].Position = i + 1
+			if _, moveErr := s.holds.Transition(ctx, &reordered[i], model.HoldWaiting); moveErr != nil {
+				return moveErr
+			}
+		}
+		hold.Position = index + 1
+		return nil
+	})
+	return hold, err
+}
+
+// ExpireHolds ends every ready hold whose pickup window closed before now and
+// passes its copy on. A hold that fails to expire is skipped, see sweep. It
+// returns how many holds expired.
+func (s *Service) ExpireHolds(ctx context.Context, now time.Time) (expired int, err error) {
+	holds, err := s.holds.ListExpired(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	return sweep(ctx, s, "expiring holds", "hold_id", holds, holdID, func(ctx context.Context, hold *model.Hold) (bool, error) {
+		err := s.endHold(ctx, hold, model.HoldExpired)
+		if errors.Is(err, errs.HoldNotActive) {
+			// picked up or cancelled since it was listed
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+func holdID(hold model.Hold) uint {
+	return hold.ID
+}
+
+// cancelHolds cancels the waiting and ready holds on a book. The waiting ones
+// go first so that the copies set aside for ready ones go back on the shelf
+// instead of to the next patron in line.
+func (s *Service) cancelHolds(ctx context.Context, bookID uint) error {
+	waiting, err := s.holds.Queue(ctx, bookID)
+	if err != nil {
+		return err
+	}
+	ready, err := s.holds.ListReady(ctx, bookID)
+	if err != nil {
+		return err
+	}
+	for _, hold := range append(waiting, ready...) {
+		if err = s.endHold(ctx, &hold, model.HoldCancelled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endHold moves an active hold to status and releases the copy set aside for
+// it, if any.
+func (s *Service) endHold(ctx context.Context, hold *model.Hold, status model.HoldStatus) error {
+	from := hold.Status
+	if from != model.HoldWaiting && from != model.HoldReady {
+		return errs.HoldNotActive
+	}
+	copyID := hold.CopyID
+
+	hold.Status = status
+	hold.CopyID = nil
+	if ended, err := s.holds.Transition(ctx, hold, from); err != nil {
+		return err
+	} else if !ended {
+		return errs.HoldNotActive
+	}
+
+	if from != model.HoldReady || copyID == nil {
+		return nil
+	}
+	if err := s.copies.Release(ctx, *copyID); err != nil {
+		return err
+	}
+	_, err := s.passOn(ctx, hold.BookID, *copyID)
+	return err
+}
+
+// passOn sets an available copy aside for the first waiting hold on its book
+// and returns that hold, or nil when nobody is waiting and the copy stays on
+// the shelf.
+func (s *Service) passOn(ctx context.Context, bookID uint, copyID uint) (*model.Hold, error) {
+	queue, err := s.holds.Queue(ctx, bookID)
+	if err != nil || len(queue) == 0 {
+		return nil, err
+	}
+
+	if reserved, err := s.copies.Transition(ctx, copyID, model.CopyAvailable, model.CopyOnHold); err != nil || !reserved {
+		return nil, err
+	}
+
+	now := time.Now()
+	expires := now.Add(s.holdPickupWindow)
+	hold := queue[0]
+	hold.Status = model.HoldReady
+	hold.CopyID = &copyID
+	hold.ReadyAt = &now
+	hold.ExpiresAt = &expires
+	if ready, err := s.holds.Transition(ctx, &hold, model.HoldWaiting); err != nil {
+		return nil, err
+	} else if !ready {
+		return nil, errs.HoldNotActive
+	}
+	return &hold, nil
+}
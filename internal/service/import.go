@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/interchange"
+	"eLibrary/internal/repository"
+	"eLibrary/internal/validation"
+	"eLibrary/model"
+	"errors"
+	"io"
+	"strings"
+)
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// ImportReport tells what an import did, or would have done on a dry run.
+// Rows counts every row read, Failed the ones skipped for Errors.
+type ImportReport struct {
+	DryRun  bool
+	Rows    int
+	Created int
+	Updated int
+	Failed  int
+	Errors  []RowError
+}
+
+// RowError is why a row of an import was skipped. Err is a domain error.
+type RowError struct {
+	Row  int
+	ISBN string
+	Err  error
+}
+
+// ImportBooks adds the books read from rows to the catalog, or updates the
+// ones whose ISBN is already in it. Each row is validated like a request to
+// create its book; rows that fail are skipped and reported while the rest
+// are imported. Updates only change the fields a row gives a value, and
+// leave the work and copies of the book alone.
+//
+// The import runs in one transaction: a dry run rolls it back after
+// reporting what would have changed, and failures other than those of
+// single rows, such as a file that breaks off halfway, undo the whole
+// import.
+func (s *Service) ImportBooks(ctx context.Context, rows interchange.Reader, dryRun bool) (report ImportReport, err error) {
+	report.DryRun = dryRun
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		for {
+			row, readErr := rows.Read()
+			if errors.Is(readErr, io.EOF) {
+				break
+			} else if readErr != nil {
+				return readErr
+			}
+
+			report.Rows++
+			created, rowErr := s.importBook(ctx, row)
+			switch {
+			case rowErr != nil && errs.KindOf(rowErr) == errs.Internal:
+				return rowErr
+			case rowErr != nil:
+				report.Failed++
+				report.Errors = append(report.Errors, RowError{Row: row.Number, ISBN: rowISBN(row.Book), Err: rowErr})
+			case created:
+				report.Created++
+			default:
+				report.Updated++
+			}
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	return report, err
+}
+
+// importBook creates the book of a row or updates the one with its ISBN, and
+// reports whether it created one.
+func (s *Service) importBook(ctx context.Context, row interchange.Row) (created bool, err error) {
+	if row.Err != nil {
+		return false, row.Err
+	}
+	if err = validation.Struct(row.Book); err != nil {
+		return false, err
+	}
+	_, isbn13, err := bookISBNs(row.Book)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := s.books.FindByISBN(ctx, isbn13)
+	if errors.Is(err, repository.ErrNotFound) {
+		if deleted, takenErr := s.books.ISBNTaken(ctx, isbn13, 0); takenErr != nil {
+			return false, takenErr
+		} else if deleted {
+			return false, errs.DeletedISBN
+		}
+		_, err = s.CreateBook(ctx, row.Book)
+		return err == nil, err
+	} else if err != nil {
+		return false, err
+	}
+
+	_, err = s.PatchBook(ctx, existing.ID, 0, importPatch(row.Book, isbn13))
+	return false, err
+}
+
+// importPatch turns the non-empty fields of an imported row into a patch of
+// the book it updates.
+func importPatch(request model.BookRequest, isbn13 string) (patch model.BookPatch) {
+	patch.ISBN = &isbn13
+	text := func(value string) *string {
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+	patch.Title = text(request.Title)
+	if patch.Author = text(request.Author); patch.Author == nil {
+		patch.Author = text(strings.Join(bookAuthors(request), ", "))
+	}
+	patch.Publisher = text(request.Publisher)
+	patch.Language = text(request.Language)
+	patch.Format = text(request.Format)
+	if request.PublicationYear != 0 {
+		patch.PublicationYear = &request.PublicationYear
+	}
+	patch.WorkID = request.WorkID
+	return patch
+}
+
+// rowISBN is the ISBN a row is reported under, whichever field it came in.
+func rowISBN(request model.BookRequest) string {
+	for _, value := range []string{request.ISBN, request.ISBN13, request.ISBN10} {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+	"errors"
+)
+
+func (s *Service) ListLoanPolicies(ctx context.Context) ([]model.LoanPolicy, error) {
+	return s.policies.List(ctx)
+}
+
+func (s *Service) GetLoanPolicy(ctx context.Context, id uint) (model.LoanPolicy, error) {
+	return s.policies.FindByID(ctx, id)
+}
+
+func (s *Service) CreateLoanPolicy(ctx context.Context, request model.LoanPolicyRequest) (policy model.LoanPolicy, err error) {
+	policy = model.LoanPolicy{
+		PatronCategory:      request.PatronCategory,
+		ItemType:            request.ItemType,
+		LoanDays:            request.LoanDays,
+		RenewalDays:         request.RenewalDays,
+		MaxRenewals:         request.MaxRenewals,
+		MaxConcurrentLoans:  request.MaxConcurrentLoans,
+		BlockRenewalOnHolds: request.BlockRenewalOnHolds,
+		RenewalGraceDays:    request.RenewalGraceDays,
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if taken, takenErr := s.policies.ScopeTaken(ctx, policy.PatronCategory, policy.ItemType, 0); takenErr != nil {
+			return takenErr
+		} else if taken {
+			return errs.DuplicatePolicy
+		}
+		return s.policies.Create(ctx, &policy)
+	})
+	return policy, err
+}
+
+// ReplaceLoanPolicy overwrites every field of a policy at version with
+// request.
+func (s *Service) ReplaceLoanPolicy(ctx context.Context, id uint, version uint, request model.LoanPolicyRequest) (policy model.LoanPolicy, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if taken, takenErr := s.policies.ScopeTaken(ctx, request.PatronCategory, request.ItemType, id); takenErr != nil {
+			return takenErr
+		} else if taken {
+			return errs.DuplicatePolicy
+		}
+
+		updateErr := s.policies.Update(ctx, id, version, map[string]interface{}{
+			"patron_category":        request.PatronCategory,
+			"item_type":              request.ItemType,
+			"loan_days":              request.LoanDays,
+			"renewal_days":           request.RenewalDays,
+			"max_renewals":           request.MaxRenewals,
+			"max_concurrent_loans":   request.MaxConcurrentLoans,
+			"block_renewal_on_holds": request.BlockRenewalOnHolds,
+			"renewal_grace_days":     request.RenewalGraceDays,
+		})
+		if updateErr != nil {
+			return updateErr
+		}
+		var findErr error
+		policy, findErr = s.policies.FindByID(ctx, id)
+		return findErr
+	})
+	return policy, err
+}
+
+// DeleteLoanPolicy removes a policy; loans it covered fall back to the next
+// most specific one.
+func (s *Service) DeleteLoanPolicy(ctx context.Context, id uint) error {
+	return s.policies.Delete(ctx, id)
+}
+
+// loanPolicy returns the policy for a user borrowing a book, falling back to
+// model.DefaultLoanPolicy when no stored policy applies.
+func (s *Service) loanPolicy(ctx context.Context, user model.User, book model.BookDetail) (model.LoanPolicy, error) {
+	policy, err := s.policies.Resolve(ctx, user.Category, book.Format)
+	if errors.Is(err, repository.ErrNotFound) {
+		return model.DefaultLoanPolicy(), nil
+	}
+	return policy, err
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+	"errors"
+	"time"
+)
+
+// Reading is what a user read in a digital book. Progress is nil until the
+// first position is recorded.
+type Reading struct {
+	Progress   *model.ReadingProgress
+	Bookmarks  []model.Bookmark
+	Highlights []model.Highlight
+}
+
+// GetReading returns the progress, bookmarks and highlights of a user in a
+// book. They can be read back without a loan, e.g. once kept after a return.
+func (s *Service) GetReading(ctx context.Context, userID uint, bookID uint) (reading Reading, err error) {
+	if _, err = s.books.FindByID(ctx, bookID); err != nil {
+		return reading, err
+	}
+
+	if progress, findErr := s.reading.FindProgress(ctx, userID, bookID); findErr == nil {
+		reading.Progress = &progress
+	} else if !errors.Is(findErr, repository.ErrNotFound) {
+		return reading, findErr
+	}
+	if reading.Bookmarks, err = s.reading.ListBookmarks(ctx, userID, bookID); err != nil {
+		return reading, err
+	}
+	reading.Highlights, err = s.reading.ListHighlights(ctx, userID, bookID)
+	return reading, err
+}
+
+// SaveProgress records the reading position of a user in a book they have on
+// digital loan. Progress recorded before the stored one is ignored, so a
+// device syncing late cannot move the reader back; the stored progress is
+// returned either way. Times in the future are taken as now, or a device with
+// its clock ahead would hold off every other save until then.
+func (s *Service) SaveProgress(ctx context.Context, userID uint, bookID uint, request model.ProgressRequest) (progress model.ReadingProgress, err error) {
+	recordedAt := time.Now()
+	if request.RecordedAt != nil && request.RecordedAt.Before(recordedAt) {
+		recordedAt = *request.RecordedAt
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		loan, loanErr := s.readingLoan(ctx, userID, bookID)
+		if loanErr != nil {
+			return loanErr
+		}
+
+		var findErr error
+		progress, findErr = s.reading.FindProgress(ctx, userID, bookID)
+		if errors.Is(findErr, repository.ErrNotFound) {
+			progress = model.ReadingProgress{UserID: userID, BookID: bookID}
+			setProgress(&progress, loan, request, recordedAt)
+			if created, createErr := s.reading.CreateProgress(ctx, &progress); createErr != nil || created {
+				return createErr
+			}
+			// another device saved the first progress in the meantime
+			progress, findErr = s.reading.FindProgress(ctx, userID, bookID)
+		}
+		if findErr != nil {
+			return findErr
+		} else if recordedAt.Before(progress.RecordedAt) {
+			return nil
+		}
+
+		setProgress(&progress, loan, request, recordedAt)
+		return s.reading.SaveProgress(ctx, &progress)
+	})
+	return progress, err
+}
+
+func setProgress(progress *model.ReadingProgress, loan model.LoanDetail, request model.ProgressRequest, recordedAt time.Time) {
+	progress.LoanID = loan.ID
+	progress.Location = request.Location
+	progress.Percent = request.Percent
+	progress.RecordedAt = recordedAt
+	if request.KeepAfterReturn != nil {
+		progress.KeepAfterReturn = *request.KeepAfterReturn
+	}
+}
+
+// AddBookmark bookmarks a location in a book the user has on digital loan.
+func (s *Service) AddBookmark(ctx context.Context, userID uint, bookID uint, request model.BookmarkRequest) (bookmark model.Bookmark, err error) {
+	loan, err := s.readingLoan(ctx, userID, bookID)
+	if err != nil {
+		return bookmark, err
+	}
+	bookmark = model.Bookmark{
+		UserID:   userID,
+		BookID:   bookID,
+		LoanID:   loan.ID,
+		Location: request.Location,
+		Label:    request.Label,
+	}
+	return bookmark, s.reading.CreateBookmark(ctx, &bookmark)
+}
+
+// DeleteBookmark removes one of the user's bookmarks.
+func (s *Service) DeleteBookmark(ctx context.Context, id uint, userID uint) error {
+	bookmark, err := s.reading.FindBookmark(ctx, id)
+	if err != nil {
+		return err
+	} else if bookmark.UserID != userID {
+		return errs.NotAllowed
+	}
+	return s.reading.DeleteBookmark(ctx, id)
+}
+
+// AddHighlight highlights a passage of a book the user has on digital loan.
+func (s *Service) AddHighlight(ctx context.Context, userID uint, bookID uint, request model.HighlightRequest) (highlight model.Highlight, err error) {
+	loan, err := s.readingLoan(ctx, userID, bookID)
+	if err != nil {
+		return highlight, err
+	}
+	highlight = model.Highlight{
+		UserID:   userID,
+		BookID:   bookID,
+		LoanID:   loan.ID,
+		Location: request.Location,
+		Text:     request.Text,
+		Note:     request.Note,
+		Color:    request.Color,
+	}
+	return highlight, s.reading.CreateHighlight(ctx, &highlight)
+}
+
+// DeleteHighlight removes one of the user's highlights.
+func (s *Service) DeleteHighlight(ctx context.Context, id uint, userID uint) error {
+	highlight, err := s.reading.FindHighlight(ctx, id)
+	if err != nil {
+		return err
+	} else if highlight.UserID != userID {
+		return errs.NotAllowed
+	}
+	return s.reading.DeleteHighlight(ctx, id)
+}
+
+// readingLoan returns the user's active digital loan of a book, which what
+// they read is recorded under.
+func (s *Service) readingLoan(ctx context.Context, userID uint, bookID uint) (loan model.LoanDetail, err error) {
+	if loan, err = s.loans.FindActive(ctx, bookID, userID); errors.Is(err, repository.ErrNotFound) {
+		return loan, errs.NoActiveLoan
+	} else if err != nil {
+		return loan, err
+	}
+	if loan.FileID == nil {
+		return loan, errs.NotDigitalLoan
+	}
+	return loan, nil
+}
+
+// endReading forgets what the borrower of a digital loan that ended read in
+// the book, unless they asked for it to be kept after the return.
+func (s *Service) endReading(ctx context.Context, loan *model.LoanDetail) error {
+	progress, err := s.reading.FindProgress(ctx, loan.UserID, loan.BookID)
+	if err == nil && progress.KeepAfterReturn {
+		return nil
+	} else if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	return s.reading.Forget(ctx, loan.UserID, loan.BookID)
+}
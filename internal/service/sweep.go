@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sweep runs step on each record a periodic job works through, each in a
+// transaction of its own, and counts the records step reports as changed. A
+// record that fails is logged, with its id under field, and skipped, so that
+// it cannot hold up the records after it on every run; the error returned then
+// says how many failed.
+func sweep[T any](ctx context.Context, s *Service, job string, field string, records []T, id func(T) uint, step func(ctx context.Context, record *T) (bool, error)) (changed int, err error) {
+	failed := 0
+	for i := range records {
+		var done bool
+		err = s.tx.WithinTransaction(ctx, func(ctx context.Context) (stepErr error) {
+			done, stepErr = step(ctx, &records[i])
+			return stepErr
+		})
+		if err != nil {
+			log.WithError(err).WithField(field, id(records[i])).Errorf("%s failed", job)
+			failed++
+		} else if done {
+			changed++
+		}
+	}
+	if failed > 0 {
+		return changed, fmt.Errorf("%s: %d of %d failed", job, failed, len(records))
+	}
+	return changed, nil
+}
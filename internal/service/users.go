@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/repository"
+	"eLibrary/model"
+	"errors"
+)
+
+// CreateUser registers a user that can log in with request.Password.
+func (s *Service) CreateUser(ctx context.Context, request model.UserRequest) (user model.User, err error) {
+	user = model.User{
+		FirstName: request.FirstName,
+		LastName:  request.LastName,
+		Username:  request.Username,
+		Email:     request.Email,
+		Role:      model.RolePatron,
+		Category:  request.Category,
+	}
+	if request.Role != "" {
+		user.Role = request.Role
+	}
+	if !user.Role.IsValid() {
+		return user, errs.InvalidRole
+	}
+	if user.PasswordHash, err = auth.HashPassword(request.Password); err != nil {
+		return user, err
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if taken, takenErr := s.users.UsernameTaken(ctx, user.Username, 0); takenErr != nil {
+			return takenErr
+		} else if taken {
+			return errs.DuplicateUsername
+		}
+		return s.users.Create(ctx, &user)
+	})
+	return user, err
+}
+
+// Login returns the user with the given username and password. An unknown
+// username and a wrong password both fail with errs.InvalidCredentials, so
+// callers cannot probe for usernames.
+func (s *Service) Login(ctx context.Context, username string, password string) (model.User, error) {
+	user, err := s.users.FindByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		return user, errs.InvalidCredentials
+	} else if err != nil {
+		return user, err
+	}
+	if !auth.CheckPassword(user.PasswordHash, password) {
+		return model.User{}, errs.InvalidCredentials
+	}
+	return user, nil
+}
+
+// EnsureAdmin creates an admin with the given credentials unless there already
+// is one, so a fresh installation has someone who can register staff. It
+// reports whether a user was created.
+func (s *Service) EnsureAdmin(ctx context.Context, username string, password string) (created bool, err error) {
+	admins, err := s.users.List(ctx, repository.UserFilter{
+		Role:        model.RoleAdmin,
+		ListOptions: repository.ListOptions{Limit: 1},
+	})
+	if err != nil || admins.Total > 0 {
+		return false, err
+	}
+
+	_, err = s.CreateUser(ctx, model.UserRequest{
+		Username: username,
+		Password: password,
+		Role:     model.RoleAdmin,
+	})
+	return err == nil, err
+}
+
+func (s *Service) GetUser(ctx context.Context, id uint) (model.User, error) {
+	return s.users.FindByID(ctx, id)
+}
+
+// ReplaceUser overwrites every field of a user at version with request.
+func (s *Service) ReplaceUser(ctx context.Context, id uint, version uint, request model.UserRequest) (model.User, error) {
+	changes := map[string]interface{}{
+		"first_name": request.FirstName,
+		"last_name":  request.LastName,
+		"username":   request.Username,
+		"email":      request.Email,
+	}
+	if request.Role != "" {
+		if !request.Role.IsValid() {
+			return model.User{}, errs.InvalidRole
+		}
+		changes["role"] = request.Role
+	}
+	if request.Category != "" {
+		changes["category"] = request.Category
+	}
+	if request.Password != "" {
+		hash, err := auth.HashPassword(request.Password)
+		if err != nil {
+			return model.User{}, err
+		}
+		changes["password_hash"] = hash
+	}
+	return s.updateUser(ctx, id, version, changes)
+}
+
+// PatchUser changes only the fields of a user at version that are set in
+// patch.
+func (s *Service) PatchUser(ctx context.Context, id uint, version uint, patch model.UserPatch) (model.User, error) {
+	changes := make(map[string]interface{})
+	if patch.FirstName != nil {
+		changes["first_name"] = *patch.FirstName
+	}
+	if patch.LastName != nil {
+		changes["last_name"] = *patch.LastName
+	}
+	if patch.Username != nil {
+		changes["username"] = *patch.Username
+	}
+	if patch.Email != nil {
+		changes["email"] = *patch.Email
+	}
+	if patch.Role != nil {
+		if !patch.Role.IsValid() {
+			return model.User{}, errs.InvalidRole
+		}
+		changes["role"] = *patch.Role
+	}
+	if patch.Category != nil {
+		changes["category"] = *patch.Category
+	}
+	if patch.Password != nil {
+		hash, err := auth.HashPassword(*patch.Password)
+		if err != nil {
+			return model.User{}, err
+		}
+		changes["password_hash"] = hash
+	}
+	return s.updateUser(ctx, id, version, changes)
+}
+
+func (s *Service) updateUser(ctx context.Context, id uint, version uint, changes map[string]interface{}) (user model.User, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if username, ok := changes["username"].(string); ok {
+			if taken, takenErr := s.users.UsernameTaken(ctx, username, id); takenErr != nil {
+				return takenErr
+			} else if taken {
+				return errs.DuplicateUsername
+			}
+		}
+		if updateErr := s.users.Update(ctx, id, version, changes); updateErr != nil {
+			return updateErr
+		}
+		user, err = s.users.FindByID(ctx, id)
+		return err
+	})
+	return user, err
+}
+
+// DeleteUser soft-deletes a user, refusing with errs.HasActiveLoans while
+// they have books out. A non-zero version must match the user's.
+func (s *Service) DeleteUser(ctx context.Context, id uint, version uint) error {
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.users.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && user.Version != version {
+			return repository.ErrVersionConflict
+		}
+		if active, err := s.loans.CountActive(ctx, repository.LoanFilter{UserID: id}); err != nil {
+			return err
+		} else if active > 0 {
+			return errs.HasActiveLoans
+		}
+		return s.users.Delete(ctx, id)
+	})
+}
+
+// RestoreUser undoes DeleteUser.
+func (s *Service) RestoreUser(ctx context.Context, id uint) (user model.User, err error) {
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if restoreErr := s.users.Restore(ctx, id); restoreErr != nil {
+			return restoreErr
+		}
+		user, err = s.users.FindByID(ctx, id)
+		return err
+	})
+	return user, err
+}
@@ -0,0 +1,36 @@
+// Package storage keeps the files of digital items, such as e-books, in a
+// blob store and signs the time-limited links they are downloaded through.
+package storage
+
+import (
+	"context"
+	"eLibrary/config"
+	"eLibrary/internal/errs"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound is returned by every store when no blob has the requested key.
+var ErrNotFound = errs.New(errs.NotFound, "blob_not_found", "file content not found")
+
+// Store keeps blobs under keys chosen by the caller. Keys are slash-separated
+// relative paths such as "books/1/dune.epub".
+type Store interface {
+	// Put stores everything read from content under key, replacing any blob
+	// stored there before, and returns how many bytes were written.
+	Put(ctx context.Context, key string, content io.Reader) (int64, error)
+	// Open returns the blob stored under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the store the configuration selects.
+func New(cfg config.StorageConfig) (Store, error) {
+	switch cfg.Backend {
+	case config.StorageLocal:
+		return NewLocal(cfg.Path)
+	}
+	return nil, fmt.Errorf("unsupported storage backend %q", cfg.Backend)
+}
@@ -0,0 +1,113 @@
+// Package validation checks requests against the rules in the validate tags
+// of their fields, as the handlers do for request bodies and the service for
+// imported rows.
+package validation
+
+import (
+	"eLibrary/internal/errs"
+	"eLibrary/isbn"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// usernamePattern is what the username rule accepts: 3 to 32 letters, digits,
+// dots, underscores and hyphens, starting with a letter or digit.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{2,31}$`)
+
+var validate = newValidator()
+
+// newValidator returns a validator that names fields after their JSON keys and
+// knows the rules specific to the library:
+//
+//   - isbn: an ISBN-10 or ISBN-13 with a valid check digit, hyphens allowed;
+//     it replaces the validator's own isbn rule to agree with package isbn
+//   - username: see usernamePattern
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	must(v.RegisterValidation("isbn", func(fl validator.FieldLevel) bool {
+		return isbn.Validate(fl.Field().String()) == nil
+	}))
+	must(v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	}))
+	return v
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Struct validates target against the rules in its validate tags and reports
+// the fields that broke them as errs.Fields.
+func Struct(target interface{}) error {
+	return fieldErrors(validate.Struct(target))
+}
+
+// fieldErrors turns the errors of the validator into errs.Fields.
+func fieldErrors(err error) error {
+	var failures validator.ValidationErrors
+	if !errors.As(err, &failures) {
+		return err
+	}
+	fields := make(errs.Fields, 0, len(failures))
+	for _, failure := range failures {
+		fields = append(fields, errs.FieldError{
+			Field:   fieldPath(failure),
+			Rule:    failure.Tag(),
+			Message: ruleMessage(failure),
+		})
+	}
+	return fields
+}
+
+// fieldPath is the JSON path of a failed field, without the name of the
+// request type it belongs to.
+func fieldPath(failure validator.FieldError) string {
+	_, path, found := strings.Cut(failure.Namespace(), ".")
+	if !found {
+		return failure.Field()
+	}
+	return path
+}
+
+func ruleMessage(failure validator.FieldError) string {
+	unit := ""
+	switch failure.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Map:
+		unit = " items"
+	}
+
+	switch failure.Tag() {
+	case "required", "required_without_all":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s%s", failure.Param(), unit)
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s%s", failure.Param(), unit)
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(failure.Param()), ", ")
+	case "isbn":
+		value, _ := failure.Value().(string)
+		return fmt.Sprintf("must be a valid ISBN-10 or ISBN-13: %v", isbn.Validate(value))
+	case "username":
+		return "must be 3 to 32 letters, digits, dots, underscores or hyphens, starting with a letter or digit"
+	}
+	return fmt.Sprintf("must satisfy %s", failure.Tag())
+}
@@ -0,0 +1,142 @@
+// Package isbn validates, normalizes and converts International Standard Book
+// Numbers in their 10 and 13 digit forms.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidLength   = errors.New("isbn must have 10 or 13 digits")
+	ErrInvalidChar     = errors.New("isbn contains invalid characters")
+	ErrInvalidChecksum = errors.New("isbn check digit does not match")
+	// ErrNotConvertible is returned when converting an ISBN-13 outside the
+	// 978 prefix, which has no ISBN-10 equivalent.
+	ErrNotConvertible = errors.New("isbn-13 has no isbn-10 equivalent")
+)
+
+// Strip removes the hyphens and spaces ISBNs are commonly printed with and
+// upper-cases a trailing "x" check digit.
+func Strip(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	return strings.ToUpper(s)
+}
+
+// Validate reports why s is not a valid ISBN-10 or ISBN-13, or nil when it is.
+// Hyphens and spaces are ignored.
+func Validate(s string) error {
+	s = Strip(s)
+	switch len(s) {
+	case 10:
+		return validate10(s)
+	case 13:
+		return validate13(s)
+	default:
+		return ErrInvalidLength
+	}
+}
+
+// IsValid reports whether s is a valid ISBN-10 or ISBN-13.
+func IsValid(s string) bool {
+	return Validate(s) == nil
+}
+
+// Normalize validates s and returns it in canonical ISBN-13 form without
+// hyphens. Every book has exactly one normalized ISBN regardless of whether it
+// was entered as an ISBN-10 or ISBN-13.
+func Normalize(s string) (string, error) {
+	s = Strip(s)
+	if err := Validate(s); err != nil {
+		return "", err
+	}
+	if len(s) == 10 {
+		return to13(s), nil
+	}
+	return s, nil
+}
+
+// To13 converts a valid ISBN-10 or ISBN-13 to ISBN-13.
+func To13(s string) (string, error) {
+	return Normalize(s)
+}
+
+// To10 converts a valid ISBN-10 or 978-prefixed ISBN-13 to ISBN-10.
+func To10(s string) (string, error) {
+	s = Strip(s)
+	if err := Validate(s); err != nil {
+		return "", err
+	}
+	if len(s) == 10 {
+		return s, nil
+	}
+	if !strings.HasPrefix(s, "978") {
+		return "", ErrNotConvertible
+	}
+	body := s[3:12]
+	return body + string(checkDigit10(body)), nil
+}
+
+func validate10(s string) error {
+	for i, r := range s {
+		if !isDigit(r) && !(i == 9 && r == 'X') {
+			return ErrInvalidChar
+		}
+	}
+	if checkDigit10(s[:9]) != s[9] {
+		return ErrInvalidChecksum
+	}
+	return nil
+}
+
+func validate13(s string) error {
+	for _, r := range s {
+		if !isDigit(r) {
+			return ErrInvalidChar
+		}
+	}
+	if checkDigit13(s[:12]) != s[12] {
+		return ErrInvalidChecksum
+	}
+	return nil
+}
+
+func to13(s string) string {
+	body := "978" + s[:9]
+	return body + string(checkDigit13(body))
+}
+
+// checkDigit10 computes the mod 11 check digit of the first 9 digits.
+func checkDigit10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(body[i]-'0')
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// checkDigit13 computes the mod 10 check digit of the first 12 digits.
+func checkDigit13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(body[i]-'0')
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
@@ -0,0 +1,64 @@
+package isbn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"ISBN-10", "0441172717", nil},
+		{"ISBN-10 With Hyphens", "0-441-17271-7", nil},
+		{"ISBN-10 With X", "080442957X", nil},
+		{"ISBN-10 With Lowercase x", "080442957x", nil},
+		{"ISBN-13", "9780441013593", nil},
+		{"ISBN-13 With Hyphens", "978-0-441-01359-3", nil},
+		{"Bad ISBN-10 Checksum", "0441172718", ErrInvalidChecksum},
+		{"Bad ISBN-13 Checksum", "9780441013594", ErrInvalidChecksum},
+		{"X In ISBN-13", "978044101359X", ErrInvalidChar},
+		{"Letters", "04411A2717", ErrInvalidChar},
+		{"Too Short", "12345", ErrInvalidLength},
+		{"Empty", "", ErrInvalidLength},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.err, Validate(tc.input))
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	normalized, err := Normalize("0-441-17271-7")
+	assert.NoError(t, err)
+	assert.Equal(t, "9780441172719", normalized)
+
+	normalized, err = Normalize("978-0-441-01359-3")
+	assert.NoError(t, err)
+	assert.Equal(t, "9780441013593", normalized)
+
+	_, err = Normalize("0441172718")
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+}
+
+func TestConvert(t *testing.T) {
+	isbn10, err := To10("9780441172719")
+	assert.NoError(t, err)
+	assert.Equal(t, "0441172717", isbn10)
+
+	isbn10, err = To10("9780804429573")
+	assert.NoError(t, err)
+	assert.Equal(t, "080442957X", isbn10)
+
+	_, err = To10("9791032305690")
+	assert.ErrorIs(t, err, ErrNotConvertible)
+
+	isbn13, err := To13("080442957X")
+	assert.NoError(t, err)
+	assert.Equal(t, "9780804429573", isbn13)
+}
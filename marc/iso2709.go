@@ -0,0 +1,186 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Delimiters of the ISO 2709 format.
+const (
+	subfieldDelimiter = 0x1f
+	fieldTerminator   = 0x1e
+	recordTerminator  = 0x1d
+)
+
+const (
+	leaderLen         = 24
+	directoryEntryLen = 12
+	maxRecordLen      = 99999
+)
+
+var (
+	// ErrMalformed is wrapped by the errors of single records that do not
+	// follow the format; the records after them can still be read.
+	ErrMalformed = errors.New("malformed marc record")
+	// ErrCorrupt is wrapped by the errors of ISO 2709 data whose record
+	// boundaries are lost, after which nothing more can be read.
+	ErrCorrupt = errors.New("corrupt marc data")
+)
+
+// Reader reads ISO 2709 records one at a time.
+type Reader struct {
+	r *bufio.Reader
+	// broken is set once the record boundaries are lost, after which
+	// nothing more can be read.
+	broken error
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record, or io.EOF when there are no more. A record
+// whose length is intact but whose content is malformed is reported with an
+// error wrapping ErrMalformed, and reading can go on with the next record.
+// When the length itself is unusable, an error wrapping ErrCorrupt is
+// returned from this and every later call.
+func (r *Reader) Read() (Record, error) {
+	if r.broken != nil {
+		return Record{}, r.broken
+	}
+
+	// tolerate the line breaks some tools put between records
+	for {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return Record{}, err
+		}
+		if b != '\n' && b != '\r' {
+			r.r.UnreadByte()
+			break
+		}
+	}
+
+	header, err := r.r.Peek(5)
+	if err != nil {
+		r.broken = fmt.Errorf("%w: truncated record length", ErrCorrupt)
+		return Record{}, r.broken
+	}
+	length, err := strconv.Atoi(string(header))
+	if err != nil || length < leaderLen+1 {
+		r.broken = fmt.Errorf("%w: invalid record length %q", ErrCorrupt, header)
+		return Record{}, r.broken
+	}
+
+	data := make([]byte, length)
+	if _, err = io.ReadFull(r.r, data); err != nil {
+		r.broken = fmt.Errorf("%w: record is shorter than its length of %d", ErrCorrupt, length)
+		return Record{}, r.broken
+	}
+	return decode(data)
+}
+
+// decode parses one complete record.
+func decode(data []byte) (record Record, err error) {
+	if data[len(data)-1] != recordTerminator {
+		return record, fmt.Errorf("%w: missing record terminator", ErrMalformed)
+	}
+	record.Leader = string(data[:leaderLen])
+
+	base, err := strconv.Atoi(string(data[12:17]))
+	if err != nil || base <= leaderLen || base > len(data) || data[base-1] != fieldTerminator {
+		return record, fmt.Errorf("%w: invalid base address of data %q", ErrMalformed, data[12:17])
+	}
+
+	directory := data[leaderLen : base-1]
+	if len(directory)%directoryEntryLen != 0 {
+		return record, fmt.Errorf("%w: directory length is not a multiple of %d", ErrMalformed, directoryEntryLen)
+	}
+	for entry := directory; len(entry) > 0; entry = entry[directoryEntryLen:] {
+		tag := string(entry[:3])
+		length, lengthErr := strconv.Atoi(string(entry[3:7]))
+		start, startErr := strconv.Atoi(string(entry[7:12]))
+		if lengthErr != nil || startErr != nil || length < 1 || base+start+length > len(data)-1 {
+			return record, fmt.Errorf("%w: invalid directory entry %q", ErrMalformed, entry[:directoryEntryLen])
+		}
+
+		// drop the field terminator
+		value := data[base+start : base+start+length-1]
+		if isControlTag(tag) {
+			record.ControlFields = append(record.ControlFields, ControlField{Tag: tag, Value: string(value)})
+		} else if field, fieldErr := decodeDataField(tag, value); fieldErr != nil {
+			return record, fieldErr
+		} else {
+			record.DataFields = append(record.DataFields, field)
+		}
+	}
+	return record, nil
+}
+
+func decodeDataField(tag string, value []byte) (field DataField, err error) {
+	if len(value) < 2 {
+		return field, fmt.Errorf("%w: field %s has no indicators", ErrMalformed, tag)
+	}
+	field = DataField{Tag: tag, Ind1: value[0], Ind2: value[1]}
+	for _, subfield := range bytes.Split(value[2:], []byte{subfieldDelimiter}) {
+		if len(subfield) > 0 {
+			field.Subfields = append(field.Subfields, Subfield{Code: subfield[0], Value: string(subfield[1:])})
+		}
+	}
+	return field, nil
+}
+
+// Writer writes records in the ISO 2709 format.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write encodes record. The record length and base address in its leader
+// are filled in; the rest of the leader is written as given, padded with
+// blanks to 24 characters.
+func (w *Writer) Write(record Record) error {
+	var directory, fields bytes.Buffer
+	addField := func(tag string, value []byte) {
+		fmt.Fprintf(&directory, "%s%04d%05d", tag, len(value)+1, fields.Len())
+		fields.Write(value)
+		fields.WriteByte(fieldTerminator)
+	}
+	for _, field := range record.ControlFields {
+		addField(field.Tag, []byte(field.Value))
+	}
+	for _, field := range record.DataFields {
+		value := []byte{field.Ind1, field.Ind2}
+		for _, subfield := range field.Subfields {
+			value = append(value, subfieldDelimiter, subfield.Code)
+			value = append(value, subfield.Value...)
+		}
+		addField(field.Tag, value)
+	}
+	directory.WriteByte(fieldTerminator)
+
+	base := leaderLen + directory.Len()
+	length := base + fields.Len() + 1
+	if length > maxRecordLen {
+		return fmt.Errorf("%w: record of %d bytes exceeds the maximum of %d", ErrMalformed, length, maxRecordLen)
+	}
+
+	leader := []byte(fmt.Sprintf("%-24.24s", record.Leader))
+	copy(leader[0:5], fmt.Sprintf("%05d", length))
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+
+	out := make([]byte, 0, length)
+	out = append(out, leader...)
+	out = append(out, directory.Bytes()...)
+	out = append(out, fields.Bytes()...)
+	out = append(out, recordTerminator)
+	_, err := w.w.Write(out)
+	return err
+}
@@ -0,0 +1,95 @@
+// Package marc reads and writes bibliographic records in MARC 21, both in
+// the ISO 2709 exchange format and as MARCXML.
+package marc
+
+import "strings"
+
+// Record is one MARC record. Fields keep the order they had in the record.
+type Record struct {
+	// Leader is the 24 character header of the record. Its positions 6 and 7
+	// give the type of record and bibliographic level, e.g. "am" for a book.
+	Leader        string
+	ControlFields []ControlField
+	DataFields    []DataField
+}
+
+// ControlField is a field without indicators or subfields, tagged 001 to 009.
+type ControlField struct {
+	Tag   string
+	Value string
+}
+
+// DataField is a field with two indicators and subfields, tagged 010 to 999.
+type DataField struct {
+	Tag       string
+	Ind1      byte
+	Ind2      byte
+	Subfields []Subfield
+}
+
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// Control returns the value of the first control field with tag, or an empty
+// string when there is none.
+func (r Record) Control(tag string) string {
+	for _, field := range r.ControlFields {
+		if field.Tag == tag {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// Fields returns the data fields with tag.
+func (r Record) Fields(tag string) []DataField {
+	var fields []DataField
+	for _, field := range r.DataFields {
+		if field.Tag == tag {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Value returns the first subfield with code of the first field with tag that
+// has one, or an empty string when there is none.
+func (r Record) Value(tag string, code byte) string {
+	for _, field := range r.Fields(tag) {
+		if value := field.Value(code); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Values returns every subfield with code of every field with tag.
+func (r Record) Values(tag string, code byte) []string {
+	var values []string
+	for _, field := range r.Fields(tag) {
+		for _, subfield := range field.Subfields {
+			if subfield.Code == code {
+				values = append(values, subfield.Value)
+			}
+		}
+	}
+	return values
+}
+
+// Value returns the first subfield with code, or an empty string when there
+// is none.
+func (f DataField) Value(code byte) string {
+	for _, subfield := range f.Subfields {
+		if subfield.Code == code {
+			return subfield.Value
+		}
+	}
+	return ""
+}
+
+// isControlTag reports whether fields tagged tag are control fields.
+func isControlTag(tag string) bool {
+	return strings.HasPrefix(tag, "00")
+}
@@ -0,0 +1,125 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var dune = Record{
+	Leader:        "00000cam a2200000 a 4500",
+	ControlFields: []ControlField{{Tag: "001", Value: "12345"}, {Tag: "008", Value: "650101s1965    nyu           000 1 eng d"}},
+	DataFields: []DataField{
+		{Tag: "020", Ind1: ' ', Ind2: ' ', Subfields: []Subfield{{Code: 'a', Value: "0441172717 (pbk.)"}}},
+		{Tag: "100", Ind1: '1', Ind2: ' ', Subfields: []Subfield{{Code: 'a', Value: "Herbert, Frank,"}}},
+		{Tag: "245", Ind1: '1', Ind2: '0', Subfields: []Subfield{{Code: 'a', Value: "Dune /"}, {Code: 'c', Value: "Frank Herbert."}}},
+	},
+}
+
+func TestISO2709(t *testing.T) {
+	t.Run("Round Trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		assert.NoError(t, w.Write(dune))
+		assert.NoError(t, w.Write(dune))
+
+		r := NewReader(&buf)
+		for i := 0; i < 2; i++ {
+			record, err := r.Read()
+			assert.NoError(t, err)
+			assert.Equal(t, dune.ControlFields, record.ControlFields)
+			assert.Equal(t, dune.DataFields, record.DataFields)
+			assert.Equal(t, "am", record.Leader[6:8])
+		}
+		_, err := r.Read()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("Malformed Record Is Skipped", func(t *testing.T) {
+		var good bytes.Buffer
+		assert.NoError(t, NewWriter(&good).Write(dune))
+		bad := append([]byte(nil), good.Bytes()...)
+		copy(bad[12:17], "99999")
+
+		r := NewReader(io.MultiReader(bytes.NewReader(bad), bytes.NewReader(good.Bytes())))
+		_, err := r.Read()
+		assert.True(t, errors.Is(err, ErrMalformed))
+
+		record, err := r.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, "Dune /", record.Value("245", 'a'))
+	})
+
+	t.Run("Broken Length Ends The File", func(t *testing.T) {
+		r := NewReader(strings.NewReader("abcde not a record"))
+		_, err := r.Read()
+		assert.True(t, errors.Is(err, ErrCorrupt))
+		_, again := r.Read()
+		assert.Equal(t, err, again)
+	})
+}
+
+func TestXMLReader(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <leader>00000cam a2200000 a 4500</leader>
+    <controlfield tag="001">12345</controlfield>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">0441172717</subfield></datafield>
+    <datafield tag="700" ind1="1" ind2=" "><subfield code="a">Anderson, Kevin J.</subfield></datafield>
+    <datafield tag="700" ind1="1" ind2=" "><subfield code="a">Herbert, Brian</subfield></datafield>
+  </record>
+  <record>
+    <datafield tag="24" ind1="1" ind2="0"><subfield code="a">Bad tag</subfield></datafield>
+  </record>
+  <record>
+    <datafield tag="245"><subfield code="a">Children of Dune</subfield></datafield>
+  </record>
+</collection>`
+
+	r := NewXMLReader(strings.NewReader(document))
+	record, err := r.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", record.Control("001"))
+	assert.Equal(t, "0441172717", record.Value("020", 'a'))
+	assert.Equal(t, []string{"Anderson, Kevin J.", "Herbert, Brian"}, record.Values("700", 'a'))
+
+	_, err = r.Read()
+	assert.True(t, errors.Is(err, ErrMalformed))
+
+	record, err = r.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "Children of Dune", record.Value("245", 'a'))
+	assert.Equal(t, byte(' '), record.Fields("245")[0].Ind1)
+
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestXMLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewXMLWriter(&buf)
+	assert.NoError(t, w.Write(dune))
+	assert.NoError(t, w.Write(dune))
+	assert.NoError(t, w.Close())
+	assert.Contains(t, buf.String(), `<collection xmlns="http://www.loc.gov/MARC21/slim">`)
+
+	r := NewXMLReader(&buf)
+	for i := 0; i < 2; i++ {
+		record, err := r.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, dune, record)
+	}
+	_, err := r.Read()
+	assert.Equal(t, io.EOF, err)
+
+	buf.Reset()
+	w = NewXMLWriter(&buf)
+	assert.NoError(t, w.Close())
+	_, err = NewXMLReader(&buf).Read()
+	assert.Equal(t, io.EOF, err)
+}
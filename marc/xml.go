@@ -0,0 +1,164 @@
+package marc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Namespace is the XML namespace of MARCXML.
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlRecord struct {
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// XMLReader reads the record elements of a MARCXML document one at a time,
+// whether they are wrapped in a collection or not.
+type XMLReader struct {
+	d *xml.Decoder
+}
+
+func NewXMLReader(r io.Reader) *XMLReader {
+	return &XMLReader{d: xml.NewDecoder(r)}
+}
+
+// Read returns the next record, or io.EOF when there are no more. Errors in
+// the XML itself end the document and are returned from every later call.
+func (r *XMLReader) Read() (Record, error) {
+	for {
+		token, err := r.d.Token()
+		if err != nil {
+			return Record{}, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var element xmlRecord
+		if err = r.d.DecodeElement(&element, &start); err != nil {
+			return Record{}, err
+		}
+		return element.record()
+	}
+}
+
+// record converts a decoded element, rejecting fields the ISO 2709 format
+// could not hold.
+func (e xmlRecord) record() (record Record, err error) {
+	record.Leader = e.Leader
+	for _, field := range e.ControlFields {
+		if len(field.Tag) != 3 {
+			return record, fmt.Errorf("%w: invalid tag %q", ErrMalformed, field.Tag)
+		}
+		record.ControlFields = append(record.ControlFields, ControlField{Tag: field.Tag, Value: field.Value})
+	}
+	for _, field := range e.DataFields {
+		if len(field.Tag) != 3 {
+			return record, fmt.Errorf("%w: invalid tag %q", ErrMalformed, field.Tag)
+		}
+		data := DataField{Tag: field.Tag, Ind1: indicator(field.Ind1), Ind2: indicator(field.Ind2)}
+		for _, subfield := range field.Subfields {
+			if len(subfield.Code) != 1 {
+				return record, fmt.Errorf("%w: invalid subfield code %q in field %s", ErrMalformed, subfield.Code, field.Tag)
+			}
+			data.Subfields = append(data.Subfields, Subfield{Code: subfield.Code[0], Value: subfield.Value})
+		}
+		record.DataFields = append(record.DataFields, data)
+	}
+	return record, nil
+}
+
+// indicator returns the byte of an indicator attribute, which is blank when
+// left out.
+func indicator(value string) byte {
+	if value == "" {
+		return ' '
+	}
+	return value[0]
+}
+
+// XMLWriter writes records as a MARCXML collection. Close ends the
+// collection.
+type XMLWriter struct {
+	e       *xml.Encoder
+	started bool
+}
+
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	return &XMLWriter{e: e}
+}
+
+var collection = xml.StartElement{Name: xml.Name{Local: "collection"}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: Namespace}}}
+
+func (w *XMLWriter) Write(record Record) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	return w.e.EncodeElement(newXMLRecord(record), xml.StartElement{Name: xml.Name{Local: "record"}})
+}
+
+// Flush writes out the records the encoder buffered so far.
+func (w *XMLWriter) Flush() error {
+	return w.e.Flush()
+}
+
+// Close ends the collection, writing an empty one when no record was
+// written. It does not close the underlying writer.
+func (w *XMLWriter) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	if err := w.e.EncodeToken(collection.End()); err != nil {
+		return err
+	}
+	return w.e.Close()
+}
+
+func (w *XMLWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	if err := w.e.EncodeToken(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)}); err != nil {
+		return err
+	}
+	return w.e.EncodeToken(collection)
+}
+
+func newXMLRecord(record Record) xmlRecord {
+	element := xmlRecord{Leader: record.Leader}
+	for _, field := range record.ControlFields {
+		element.ControlFields = append(element.ControlFields, xmlControlField{Tag: field.Tag, Value: field.Value})
+	}
+	for _, field := range record.DataFields {
+		data := xmlDataField{Tag: field.Tag, Ind1: string(field.Ind1), Ind2: string(field.Ind2)}
+		for _, subfield := range field.Subfields {
+			data.Subfields = append(data.Subfields, xmlSubfield{Code: string(subfield.Code), Value: subfield.Value})
+		}
+		element.DataFields = append(element.DataFields, data)
+	}
+	return element
+}
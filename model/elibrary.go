@@ -1,39 +1,540 @@
 package model
 
 import (
+	"fmt"
 	"gorm.io/gorm"
 	"time"
 )
 
+// Author is a person credited with writing one or more works.
+type Author struct {
+	gorm.Model
+	Name string `json:"name" gorm:"uniqueIndex;not null"`
+}
+
+// Work is the abstract creation, e.g. "Dune" by Frank Herbert, that the
+// editions in the catalog publish.
+type Work struct {
+	gorm.Model
+	Title    string       `json:"title" gorm:"not null;index"`
+	Authors  []Author     `json:"authors" gorm:"many2many:work_authors"`
+	Editions []BookDetail `json:"editions,omitempty" gorm:"foreignkey:WorkID"`
+}
+
+// BookDetail is a single edition of a work as identified by its ISBN; copies
+// are owned and lent out per edition.
 type BookDetail struct {
 	gorm.Model
-	Title           string `json:"title" gorm:"unique;not null" validate:"required"`
-	Author          string `json:"author" gorm:"not null" validate:"required"`
+	WorkID *uint  `json:"work_id" gorm:"index"`
+	Work   *Work  `json:"work,omitempty" gorm:"foreignkey:WorkID"`
+	Title  string `json:"title" gorm:"not null;index" validate:"required"`
+	Author string `json:"author" gorm:"not null" validate:"required"`
+	// ISBN is the normalized ISBN-13 of the edition, see package isbn. It is
+	// unique, see database.Migrate.
 	ISBN            string `json:"isbn" gorm:"not null" validate:"required"`
-	AvailableCopies int    `json:"available_copies" gorm:"not null" validate:"required"`
+	ISBN10          string `json:"isbn_10" gorm:"column:isbn10;index"`
+	ISBN13          string `json:"isbn_13" gorm:"column:isbn13;index"`
+	Publisher       string `json:"publisher"`
+	PublicationYear int    `json:"publication_year"`
+	Language        string `json:"language"`
+	Format          string `json:"format"`
+	// Version is bumped on every update, see repository.ErrVersionConflict.
+	Version uint `json:"version" gorm:"not null;default:1"`
+	// AvailableCopies and TotalCopies are derived from the status of the
+	// book's copies and are only filled in when queried with copy counts.
+	AvailableCopies int        `json:"available_copies" gorm:"->;-:migration"`
+	TotalCopies     int        `json:"total_copies" gorm:"->;-:migration"`
+	Copies          []BookCopy `json:"copies,omitempty" gorm:"foreignkey:BookID"`
+}
+
+type CopyStatus string
+
+const (
+	CopyAvailable CopyStatus = "available"
+	CopyOnLoan    CopyStatus = "on-loan"
+	CopyLost      CopyStatus = "lost"
+	CopyWithdrawn CopyStatus = "withdrawn"
+	// CopyOnHold copies are set aside for the patron at the front of the
+	// book's hold queue.
+	CopyOnHold CopyStatus = "on-hold"
+)
+
+func (s CopyStatus) IsValid() bool {
+	switch s {
+	case CopyAvailable, CopyOnLoan, CopyLost, CopyWithdrawn, CopyOnHold:
+		return true
+	}
+	return false
+}
+
+// CopyBarcode generates the barcode of the n-th copy of a book for copies
+// that were not given one explicitly.
+func CopyBarcode(bookID uint, n int) string {
+	return fmt.Sprintf("%06d-%03d", bookID, n)
+}
+
+// BookCopy is a single physical item of a book that can be lent out.
+type BookCopy struct {
+	gorm.Model
+	BookID        uint       `json:"book_id" gorm:"not null;index"`
+	Barcode       string     `json:"barcode" gorm:"uniqueIndex;not null"`
+	Condition     string     `json:"condition"`
+	ShelfLocation string     `json:"shelf_location"`
+	Status        CopyStatus `json:"status" gorm:"not null;default:available;index"`
+}
+
+type EbookFormat string
+
+const (
+	EbookEPUB EbookFormat = "epub"
+	EbookPDF  EbookFormat = "pdf"
+)
+
+func (f EbookFormat) IsValid() bool {
+	switch f {
+	case EbookEPUB, EbookPDF:
+		return true
+	}
+	return false
+}
+
+// ContentType is the media type files of the format are served with.
+func (f EbookFormat) ContentType() string {
+	switch f {
+	case EbookEPUB:
+		return "application/epub+zip"
+	case EbookPDF:
+		return "application/pdf"
+	}
+	return "application/octet-stream"
+}
+
+// DigitalFile is an e-book file of a book, one per format. Like physical
+// copies it is lent out, but to as many patrons at once as the library holds
+// licenses for.
+type DigitalFile struct {
+	gorm.Model
+	BookID   uint        `json:"book_id" gorm:"not null;uniqueIndex:idx_digital_file_format"`
+	Format   EbookFormat `json:"format" gorm:"not null;uniqueIndex:idx_digital_file_format"`
+	Filename string      `json:"filename"`
+	Size     int64       `json:"size" gorm:"not null"`
+	// Checksum is the hex encoded SHA-256 of the file's content.
+	Checksum string `json:"checksum" gorm:"not null"`
+	// StorageKey locates the content in the blob store; it changes whenever
+	// the file is replaced.
+	StorageKey string `json:"-" gorm:"not null"`
+	// Licenses caps how many loans of the file may be out at once, and
+	// LicensesInUse counts those that are.
+	Licenses      int `json:"licenses" gorm:"not null"`
+	LicensesInUse int `json:"licenses_in_use" gorm:"not null;default:0"`
+}
+
+// AvailableLicenses is how many more loans of the file may be made.
+func (f DigitalFile) AvailableLicenses() int {
+	if f.LicensesInUse >= f.Licenses {
+		return 0
+	}
+	return f.Licenses - f.LicensesInUse
+}
+
+// FileUpload carries the form fields sent along with an uploaded file.
+type FileUpload struct {
+	Licenses int `json:"licenses" form:"licenses" validate:"required,min=1,max=10000"`
+}
+
+// ImportForm carries the form fields sent along with a catalog import file.
+// Format defaults to the one told by the extension of the filename.
+type ImportForm struct {
+	Format string `json:"format" form:"format" validate:"omitempty,oneof=csv marc marcxml"`
+	DryRun bool   `json:"dry_run" form:"dry_run"`
 }
 
 type LoanDetail struct {
 	gorm.Model
-	BookID         uint       `json:"book_id" gorm:"not null"`
-	BookDetail     BookDetail `gorm:"foreignkey:BookID"`
-	UserID         uint       `json:"user_id" gorm:"not null"`
-	User           User       `gorm:"foreignkey:UserID"`
-	NameOfBorrower string     `json:"name_of_borrower"`
-	LoanDate       time.Time  `json:"loan_date"`
-	ReturnDate     time.Time  `json:"return_date"`
-	IsReturned     bool       `json:"is_returned"`
+	BookID     uint       `json:"book_id" gorm:"not null"`
+	BookDetail BookDetail `gorm:"foreignkey:BookID"`
+	CopyID     *uint      `json:"copy_id" gorm:"index"`
+	Copy       *BookCopy  `json:"copy,omitempty" gorm:"foreignkey:CopyID"`
+	// FileID is set instead of CopyID for loans of digital files, which
+	// take up one of the file's licenses.
+	FileID         *uint        `json:"file_id" gorm:"index"`
+	File           *DigitalFile `json:"file,omitempty" gorm:"foreignkey:FileID"`
+	UserID         uint         `json:"user_id" gorm:"not null"`
+	User           User         `gorm:"foreignkey:UserID"`
+	NameOfBorrower string       `json:"name_of_borrower"`
+	LoanDate       time.Time    `json:"loan_date"`
+	ReturnDate     time.Time    `json:"return_date"`
+	IsReturned     bool         `json:"is_returned"`
+	// Renewals counts how often the loan was extended, see
+	// LoanPolicy.MaxRenewals.
+	Renewals       int           `json:"renewals" gorm:"not null;default:0"`
+	RenewalHistory []LoanRenewal `json:"renewal_history" gorm:"foreignkey:LoanID"`
+	// Overdue and DueSoon flag unreturned loans past their return date, or
+	// coming up to it; they are filled in by the service when listing.
+	Overdue bool `json:"overdue" gorm:"-"`
+	DueSoon bool `json:"due_soon" gorm:"-"`
+	// ReturnedAt is when the loan was actually returned, nil for loans still
+	// out and for loans returned before it was recorded.
+	ReturnedAt *time.Time `json:"returned_at"`
+	Fine       *Fine      `json:"fine,omitempty" gorm:"foreignkey:LoanID"`
+}
+
+// ReadingLocation points into a digital book. Readers fill in what their
+// format offers, e.g. a chapter and EPUB CFI, or a PDF page; the library does
+// not interpret them.
+type ReadingLocation struct {
+	Chapter string `json:"chapter" validate:"max=255"`
+	CFI     string `json:"cfi" validate:"max=1024"`
+	Page    int    `json:"page" validate:"min=0"`
+}
+
+// ReadingProgress is how far a user got in a digital book, synced across
+// their devices. It is kept per book rather than per loan so it carries over
+// renewals and, when KeepAfterReturn is set, later loans of the book.
+type ReadingProgress struct {
+	gorm.Model
+	UserID uint `json:"user_id" gorm:"not null;uniqueIndex:idx_reading_progress"`
+	BookID uint `json:"book_id" gorm:"not null;uniqueIndex:idx_reading_progress"`
+	// LoanID is the loan the progress was last recorded under.
+	LoanID   uint            `json:"loan_id" gorm:"not null;index"`
+	Location ReadingLocation `json:"location" gorm:"embedded"`
+	Percent  float64         `json:"percent"`
+	// RecordedAt is when the reader recorded the position, which may be
+	// earlier than when it was synced.
+	RecordedAt      time.Time `json:"recorded_at" gorm:"not null"`
+	KeepAfterReturn bool      `json:"keep_after_return"`
+}
+
+// Bookmark marks a location in a digital book for a user.
+type Bookmark struct {
+	gorm.Model
+	UserID   uint            `json:"user_id" gorm:"not null;index:idx_bookmark_reader"`
+	BookID   uint            `json:"book_id" gorm:"not null;index:idx_bookmark_reader"`
+	LoanID   uint            `json:"loan_id" gorm:"not null"`
+	Location ReadingLocation `json:"location" gorm:"embedded"`
+	Label    string          `json:"label"`
+}
+
+// Highlight marks a passage of a digital book for a user, optionally with a
+// note. Location points at the start of the passage, or covers it for
+// readers that use CFI ranges.
+type Highlight struct {
+	gorm.Model
+	UserID   uint            `json:"user_id" gorm:"not null;index:idx_highlight_reader"`
+	BookID   uint            `json:"book_id" gorm:"not null;index:idx_highlight_reader"`
+	LoanID   uint            `json:"loan_id" gorm:"not null"`
+	Location ReadingLocation `json:"location" gorm:"embedded"`
+	Text     string          `json:"text"`
+	Note     string          `json:"note"`
+	Color    string          `json:"color"`
+}
+
+type ProgressRequest struct {
+	Location ReadingLocation `json:"location" validate:"required"`
+	Percent  float64         `json:"percent" validate:"min=0,max=100"`
+	// RecordedAt defaults to, and is capped at, now. Progress recorded
+	// earlier than what is stored, e.g. by a device syncing late, does not
+	// replace it.
+	RecordedAt *time.Time `json:"recorded_at"`
+	// KeepAfterReturn is left as it is when not sent, so devices that do not
+	// know about it cannot turn it off.
+	KeepAfterReturn *bool `json:"keep_after_return"`
+}
+
+type BookmarkRequest struct {
+	Location ReadingLocation `json:"location" validate:"required"`
+	Label    string          `json:"label" validate:"max=255"`
+}
+
+type HighlightRequest struct {
+	Location ReadingLocation `json:"location" validate:"required"`
+	Text     string          `json:"text" validate:"max=10000"`
+	Note     string          `json:"note" validate:"max=10000"`
+	Color    string          `json:"color" validate:"max=32"`
+}
+
+type EventType string
+
+const (
+	// EventLoanExpired is recorded when a digital loan ends on its own at its
+	// return date.
+	EventLoanExpired EventType = "loan.expired"
+)
+
+// Event records something that happened in the library for consumers outside
+// of it to follow. Events are written in the same transaction as the change
+// they describe, so each change is recorded exactly once.
+type Event struct {
+	gorm.Model
+	Type       EventType `json:"type" gorm:"not null;index"`
+	LoanID     *uint     `json:"loan_id" gorm:"index"`
+	UserID     *uint     `json:"user_id" gorm:"index"`
+	BookID     *uint     `json:"book_id"`
+	OccurredAt time.Time `json:"occurred_at" gorm:"not null"`
 }
 
+// LoanPolicy sets the lending rules for patrons of a category borrowing
+// books of an item type, i.e. format. An empty PatronCategory or ItemType
+// applies to every category or type; see the service for which policy wins
+// when several apply.
+type LoanPolicy struct {
+	gorm.Model
+	PatronCategory string `json:"patron_category" gorm:"not null;default:'';uniqueIndex:idx_loan_policy_scope"`
+	ItemType       string `json:"item_type" gorm:"not null;default:'';uniqueIndex:idx_loan_policy_scope"`
+	LoanDays       int    `json:"loan_days" gorm:"not null"`
+	RenewalDays    int    `json:"renewal_days" gorm:"not null"`
+	MaxRenewals    int    `json:"max_renewals" gorm:"not null"`
+	// MaxConcurrentLoans caps how many loans a patron may have out at once;
+	// zero leaves it uncapped.
+	MaxConcurrentLoans int `json:"max_concurrent_loans" gorm:"not null"`
+	// BlockRenewalOnHolds refuses renewals while other patrons wait for the
+	// book.
+	BlockRenewalOnHolds bool `json:"block_renewal_on_holds"`
+	// RenewalGraceDays is how many days past its return date a loan can
+	// still be renewed.
+	RenewalGraceDays int `json:"renewal_grace_days" gorm:"not null;default:0"`
+	// Version is bumped on every update, see repository.ErrVersionConflict.
+	Version uint `json:"version" gorm:"not null;default:1"`
+}
+
+// DefaultLoanPolicy is the catch-all policy a new installation starts with:
+// four weeks, renewable twice for three weeks each, at most ten loans at a
+// time and no renewals while others wait for the book.
+func DefaultLoanPolicy() LoanPolicy {
+	return LoanPolicy{
+		LoanDays:            28,
+		RenewalDays:         21,
+		MaxRenewals:         2,
+		MaxConcurrentLoans:  10,
+		BlockRenewalOnHolds: true,
+	}
+}
+
+type LoanPolicyRequest struct {
+	PatronCategory      string `json:"patron_category" validate:"max=64"`
+	ItemType            string `json:"item_type" validate:"max=64"`
+	LoanDays            int    `json:"loan_days" validate:"required,min=1"`
+	RenewalDays         int    `json:"renewal_days" validate:"required,min=1"`
+	MaxRenewals         int    `json:"max_renewals" validate:"min=0"`
+	MaxConcurrentLoans  int    `json:"max_concurrent_loans" validate:"min=0"`
+	BlockRenewalOnHolds bool   `json:"block_renewal_on_holds"`
+	RenewalGraceDays    int    `json:"renewal_grace_days" validate:"min=0"`
+}
+
+// LoanRenewal records one extension of a loan and who asked for it.
+type LoanRenewal struct {
+	gorm.Model
+	LoanID             uint      `json:"loan_id" gorm:"not null;index"`
+	RenewedBy          uint      `json:"renewed_by" gorm:"not null"`
+	RenewedAt          time.Time `json:"renewed_at" gorm:"not null"`
+	PreviousReturnDate time.Time `json:"previous_return_date"`
+	NewReturnDate      time.Time `json:"new_return_date"`
+}
+
+type FineStatus string
+
+const (
+	FineOutstanding FineStatus = "outstanding"
+	FinePaid        FineStatus = "paid"
+	FineWaived      FineStatus = "waived"
+)
+
+func (s FineStatus) IsValid() bool {
+	switch s {
+	case FineOutstanding, FinePaid, FineWaived:
+		return true
+	}
+	return false
+}
+
+// Fine is the ledger entry charging a user for keeping a loan past its return
+// date. It keeps accruing while the loan is out; amounts are in minor currency
+// units, e.g. cents.
+type Fine struct {
+	gorm.Model
+	LoanID      uint       `json:"loan_id" gorm:"not null;uniqueIndex"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	DaysOverdue int        `json:"days_overdue"`
+	Amount      int64      `json:"amount" gorm:"not null"`
+	Paid        int64      `json:"paid" gorm:"not null;default:0"`
+	Status      FineStatus `json:"status" gorm:"not null;index"`
+	// Final is set once the loan is returned and the amount stops changing.
+	Final bool `json:"final"`
+	// SettledAt and SettledBy record when and by whom the fine was paid off
+	// or waived.
+	SettledAt *time.Time `json:"settled_at"`
+	SettledBy *uint      `json:"settled_by"`
+	Note      string     `json:"note"`
+}
+
+// Outstanding is what is still owed on the fine.
+func (f Fine) Outstanding() int64 {
+	if f.Status == FineWaived || f.Paid >= f.Amount {
+		return 0
+	}
+	return f.Amount - f.Paid
+}
+
+// FinePayment records a payment towards a fine, in the fine's minor units.
+type FinePayment struct {
+	Amount int64 `json:"amount" validate:"required,min=1"`
+}
+
+// FineWaiver forgives what is left of a fine.
+type FineWaiver struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type HoldStatus string
+
+const (
+	// HoldWaiting holds are queued for the next copy of the book to come back.
+	HoldWaiting HoldStatus = "waiting"
+	// HoldReady holds have a copy set aside until ExpiresAt.
+	HoldReady     HoldStatus = "ready"
+	HoldFulfilled HoldStatus = "fulfilled"
+	HoldCancelled HoldStatus = "cancelled"
+	HoldExpired   HoldStatus = "expired"
+)
+
+func (s HoldStatus) IsValid() bool {
+	switch s {
+	case HoldWaiting, HoldReady, HoldFulfilled, HoldCancelled, HoldExpired:
+		return true
+	}
+	return false
+}
+
+// Hold is a patron's place in the queue for a book that had no copy available.
+// Waiting holds are served in Position order.
+type Hold struct {
+	gorm.Model
+	BookID    uint        `json:"book_id" gorm:"not null;index"`
+	Book      *BookDetail `json:"book,omitempty" gorm:"foreignkey:BookID"`
+	UserID    uint        `json:"user_id" gorm:"not null;index"`
+	User      *User       `json:"user,omitempty" gorm:"foreignkey:UserID"`
+	CopyID    *uint       `json:"copy_id"`
+	Position  int         `json:"position" gorm:"not null"`
+	Status    HoldStatus  `json:"status" gorm:"not null;index"`
+	ReadyAt   *time.Time  `json:"ready_at"`
+	ExpiresAt *time.Time  `json:"expires_at"`
+}
+
+// HoldRequest moves a waiting hold to Position in its book's queue.
+type HoldRequest struct {
+	Position int `json:"position" validate:"required,min=1"`
+}
+
+// LoanRequest names the book to borrow, extend or return; the borrower is the
+// authenticated caller.
 type LoanRequest struct {
-	Title  string `json:"title" validate:"required"`
-	UserId int    `json:"user_id" validate:"required"`
+	Title string `json:"title" validate:"required,max=255"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// BookRequest describes an edition to create or replace; at least one of its
+// ISBN fields is required.
+type BookRequest struct {
+	WorkID          *uint    `json:"work_id" validate:"omitempty,min=1"`
+	Title           string   `json:"title" validate:"required,max=255"`
+	Author          string   `json:"author" validate:"max=255"`
+	Authors         []string `json:"authors" validate:"dive,required,max=255"`
+	ISBN            string   `json:"isbn" validate:"required_without_all=ISBN10 ISBN13,omitempty,isbn"`
+	ISBN10          string   `json:"isbn_10" validate:"omitempty,isbn"`
+	ISBN13          string   `json:"isbn_13" validate:"omitempty,isbn"`
+	Publisher       string   `json:"publisher" validate:"max=255"`
+	PublicationYear int      `json:"publication_year" validate:"min=0,max=9999"`
+	Language        string   `json:"language" validate:"max=64"`
+	Format          string   `json:"format" validate:"max=64"`
+	AvailableCopies int      `json:"available_copies" validate:"min=0,max=1000"`
+}
+
+// BookPatch holds the fields of a partial book update; nil fields are left
+// unchanged.
+type BookPatch struct {
+	WorkID          *uint   `json:"work_id" validate:"omitempty,min=1"`
+	Title           *string `json:"title" validate:"omitempty,min=1,max=255"`
+	Author          *string `json:"author" validate:"omitempty,max=255"`
+	ISBN            *string `json:"isbn" validate:"omitempty,isbn"`
+	Publisher       *string `json:"publisher" validate:"omitempty,max=255"`
+	PublicationYear *int    `json:"publication_year" validate:"omitempty,min=0,max=9999"`
+	Language        *string `json:"language" validate:"omitempty,max=64"`
+	Format          *string `json:"format" validate:"omitempty,max=64"`
+}
+
+type CopyRequest struct {
+	Barcode       string     `json:"barcode" validate:"max=64"`
+	Condition     string     `json:"condition" validate:"max=255"`
+	ShelfLocation string     `json:"shelf_location" validate:"max=64"`
+	Status        CopyStatus `json:"status" validate:"omitempty,oneof=available on-loan on-hold lost withdrawn"`
+}
+
+type UserRequest struct {
+	FirstName string `json:"first_name" validate:"max=100"`
+	LastName  string `json:"last_name" validate:"max=100"`
+	Username  string `json:"username" validate:"required,username"`
+	Email     string `json:"email" validate:"required,email"`
+	// Password is required on creation; on replacement an empty one keeps
+	// the current password. Its length limits are those of auth.HashPassword.
+	Password string `json:"password" validate:"omitempty,min=8,max=72"`
+	// Role defaults to patron on creation and is kept on replacement when
+	// empty. Only admins may set it.
+	Role Role `json:"role" validate:"omitempty,oneof=patron librarian admin"`
+	// Category picks the user's loan policy and is kept on replacement when
+	// empty. Only staff may set it.
+	Category string `json:"category" validate:"max=64"`
+}
+
+// UserPatch holds the fields of a partial user update; nil fields are left
+// unchanged.
+type UserPatch struct {
+	FirstName *string `json:"first_name" validate:"omitempty,max=100"`
+	LastName  *string `json:"last_name" validate:"omitempty,max=100"`
+	Username  *string `json:"username" validate:"omitempty,username"`
+	Email     *string `json:"email" validate:"omitempty,email"`
+	Password  *string `json:"password" validate:"omitempty,min=8,max=72"`
+	Role      *Role   `json:"role" validate:"omitempty,oneof=patron librarian admin"`
+	Category  *string `json:"category" validate:"omitempty,max=64"`
+}
+
+// Role decides what a user may do; see auth.Can for the permissions of each.
+type Role string
+
+const (
+	RolePatron    Role = "patron"
+	RoleLibrarian Role = "librarian"
+	RoleAdmin     Role = "admin"
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RolePatron, RoleLibrarian, RoleAdmin:
+		return true
+	}
+	return false
 }
 
 type User struct {
 	gorm.Model
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
-	Username  string `json:"username" validate:"required"`
-	Email     string `json:"email" validate:"required,email"`
+	// Username is unique, see database.Migrate.
+	Username string `json:"username" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	// PasswordHash is the bcrypt hash of the user's password, empty for users
+	// that cannot log in.
+	PasswordHash string `json:"-"`
+	Role         Role   `json:"role" gorm:"not null;default:patron;index"`
+	// Category groups patrons that share a loan policy, e.g. "student".
+	Category string `json:"category" gorm:"not null;default:''"`
+	// Version is bumped on every update, see repository.ErrVersionConflict.
+	Version uint `json:"version" gorm:"not null;default:1"`
 }
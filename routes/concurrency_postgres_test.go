@@ -0,0 +1,65 @@
+//go:build postgres
+
+package routes
+
+import (
+	"eLibrary/database"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// setupConcurrentDB opens an empty schema of the Postgres database at
+// $ELIBRARY_TEST_POSTGRES_DSN, a postgresql:// URL, with a pool of conns
+// connections, and drops the schema when the test is done. The test is
+// skipped when the variable is not set.
+func setupConcurrentDB(t *testing.T, conns int) *gorm.DB {
+	dsn := os.Getenv("ELIBRARY_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("ELIBRARY_TEST_POSTGRES_DSN is not set")
+	}
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	schema := fmt.Sprintf("elibrary_test_%d", time.Now().UnixNano())
+	if err = admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("failed to create test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	schemaDSN, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("invalid ELIBRARY_TEST_POSTGRES_DSN: %v", err)
+	}
+	query := schemaDSN.Query()
+	query.Set("search_path", schema)
+	schemaDSN.RawQuery = query.Encode()
+
+	db, err := gorm.Open(postgres.Open(schemaDSN.String()), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test schema: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to open test schema: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(conns)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err = database.Migrate(db); err != nil {
+		t.Fatalf("failed to migrate test schema: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,38 @@
+//go:build !postgres
+
+package routes
+
+import (
+	"eLibrary/database"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupConcurrentDB opens an empty database with a pool of conns connections.
+// Unlike setupMockDB, it lives in a file, opened like database.Init opens one,
+// so that every connection sees the same data and requests do not queue up
+// for a single connection. SQLite still lets only one transaction write at a
+// time though, so here the test shows that concurrent borrows neither fail
+// nor lend a copy twice, while the conditional updates that claim copies only
+// race for real on Postgres.
+func setupConcurrentDB(t *testing.T, conns int) *gorm.DB {
+	path := filepath.Join(t.TempDir(), "elibrary.db")
+	db, err := gorm.Open(sqlite.Open(database.SQLiteDSN(path)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(conns)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err = database.Migrate(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,176 @@
+package routes
+
+import (
+	"eLibrary/model"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConcurrentBorrowAPI borrows and returns the same book from many
+// requests at once, each on a connection of its own. Run it with -tags
+// postgres to have the transactions interleave for real, see
+// setupConcurrentDB.
+func TestConcurrentBorrowAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const copies = 3
+	const borrowers = 20
+
+	db := setupConcurrentDB(t, borrowers)
+	book := model.BookDetail{
+		Title:  "Contested Book",
+		Author: "Popular Author",
+		ISBN:   "9780000000001",
+	}
+	assert.NoError(t, db.Create(&book).Error)
+	seedCopies(db, book.ID, copies)
+	for i := 0; i < borrowers; i++ {
+		assert.NoError(t, db.Create(&model.User{
+			FirstName: "Borrower",
+			LastName:  fmt.Sprint(i),
+			Username:  fmt.Sprintf("borrower%d", i),
+			Email:     fmt.Sprintf("borrower%d@example.com", i),
+		}).Error)
+	}
+
+	router := SetupRouter(db, testTokens)
+
+	t.Run("Last Copy Goes To One Borrower", func(t *testing.T) {
+		var users []model.User
+		assert.NoError(t, db.Where("username LIKE ?", "borrower%").Find(&users).Error)
+
+		var wg sync.WaitGroup
+		codes := make(chan int, len(users))
+		for _, user := range users {
+			wg.Add(1)
+			go func(user model.User) {
+				defer wg.Done()
+				req, _ := http.NewRequest("POST", "/elibrary/v1/borrow", strings.NewReader(`{"title": "Contested Book"}`))
+				req.Header.Set("Authorization", bearer(user.ID, model.RolePatron))
+				req.Header.Set("Content-Type", "application/json")
+				resp := httptest.NewRecorder()
+
+				router.ServeHTTP(resp, req)
+				codes <- resp.Code
+			}(user)
+		}
+		wg.Wait()
+		close(codes)
+
+		succeeded, conflicted := 0, 0
+		for code := range codes {
+			switch code {
+			case http.StatusOK:
+				succeeded++
+			case http.StatusConflict:
+				conflicted++
+			default:
+				t.Errorf("unexpected status %d", code)
+			}
+		}
+		assert.Equal(t, copies, succeeded)
+		assert.Equal(t, borrowers-copies, conflicted)
+
+		var available int64
+		db.Model(&model.BookCopy{}).Where("book_id = ? AND status = ?", book.ID, model.CopyAvailable).Count(&available)
+		assert.Equal(t, int64(0), available)
+
+		var loans int64
+		db.Model(&model.LoanDetail{}).Where("book_id = ? AND is_returned = ?", book.ID, false).Count(&loans)
+		assert.Equal(t, int64(copies), loans)
+	})
+
+	t.Run("Borrow And Return Keep Counts Consistent", func(t *testing.T) {
+		var loans []model.LoanDetail
+		assert.NoError(t, db.Where("book_id = ? AND is_returned = ?", book.ID, false).Find(&loans).Error)
+
+		var wg sync.WaitGroup
+		for _, loan := range loans {
+			// every borrower tries to return twice; only one return may count
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func(userID uint) {
+					defer wg.Done()
+					req, _ := http.NewRequest("POST", "/elibrary/v1/return", strings.NewReader(`{"title": "Contested Book"}`))
+					req.Header.Set("Authorization", bearer(userID, model.RolePatron))
+					req.Header.Set("Content-Type", "application/json")
+					router.ServeHTTP(httptest.NewRecorder(), req)
+				}(loan.UserID)
+			}
+		}
+		wg.Wait()
+
+		var available int64
+		db.Model(&model.BookCopy{}).Where("book_id = ? AND status = ?", book.ID, model.CopyAvailable).Count(&available)
+		assert.Equal(t, int64(copies), available)
+	})
+}
+
+// TestConcurrentLoanLimitAPI borrows many books for the same patron at once,
+// only as many of which as their loan policy allows may be lent.
+func TestConcurrentLoanLimitAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const limit = 2
+	const books = 10
+
+	db := setupConcurrentDB(t, books)
+	assert.NoError(t, db.Model(&model.LoanPolicy{}).Where("patron_category = ? AND item_type = ?", "", "").
+		Update("max_concurrent_loans", limit).Error)
+	for i := 0; i < books; i++ {
+		book := model.BookDetail{
+			Title:  fmt.Sprintf("Wanted Book %d", i),
+			Author: "Popular Author",
+			ISBN:   fmt.Sprintf("97800000001%02d", i),
+		}
+		assert.NoError(t, db.Create(&book).Error)
+		seedCopies(db, book.ID, 1)
+	}
+	user := model.User{FirstName: "Eager", LastName: "Reader", Username: "eager", Email: "eager@example.com"}
+	assert.NoError(t, db.Create(&user).Error)
+
+	router := SetupRouter(db, testTokens)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, books)
+	for i := 0; i < books; i++ {
+		wg.Add(1)
+		go func(title string) {
+			defer wg.Done()
+			req, _ := http.NewRequest("POST", "/elibrary/v1/borrow", strings.NewReader(fmt.Sprintf(`{"title": %q}`, title)))
+			req.Header.Set("Authorization", bearer(user.ID, model.RolePatron))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+			codes <- resp.Code
+		}(fmt.Sprintf("Wanted Book %d", i))
+	}
+	wg.Wait()
+	close(codes)
+
+	succeeded, refused := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			succeeded++
+		case http.StatusForbidden:
+			refused++
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	assert.Equal(t, limit, succeeded)
+	assert.Equal(t, books-limit, refused)
+
+	var loans int64
+	db.Model(&model.LoanDetail{}).Where("user_id = ? AND is_returned = ?", user.ID, false).Count(&loans)
+	assert.Equal(t, int64(limit), loans)
+}
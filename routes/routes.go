@@ -1,24 +1,111 @@
 package routes
 
 import (
+	"eLibrary/internal/auth"
 	"eLibrary/internal/handlers"
 	"eLibrary/internal/middleware"
+	"eLibrary/internal/repository"
+	"eLibrary/internal/service"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func SetupRouter() *gin.Engine {
-	r := gin.Default()
+// SetupRouter wires the GORM-backed repositories for db into a router whose
+// API requires tokens signed by tokens.
+func SetupRouter(db *gorm.DB, tokens *auth.Issuer, options ...service.Option) *gin.Engine {
+	svc := service.New(repository.NewGormRepositories(db), options...)
+	return NewRouter(handlers.New(svc, tokens), handlers.NewHealthHandler(db), tokens)
+}
+
+func NewRouter(h *handlers.Handler, health *handlers.HealthHandler, tokens *auth.Issuer) *gin.Engine {
+	// not gin.Default, whose logger would write out the signatures in the
+	// query strings of download links
+	r := gin.New()
+
+	r.Use(gin.Recovery(), middleware.Logger())
+
+	r.GET("/healthz", health.Healthz)
+	r.GET("/readyz", health.Readyz)
+
+	public := r.Group("/elibrary/v1")
+	{
+		public.POST("/auth/login", h.Login)
+		public.POST("/auth/refresh", h.Refresh)
+		// download links are signed, they work without a bearer token
+		public.GET("/loans/:id/download", h.Download)
+	}
 
-	r.Use(middleware.Logger())
+	manageCatalog := middleware.Require(auth.ManageCatalog)
+	manageUsers := middleware.Require(auth.ManageUsers)
+	selfOrManageUsers := middleware.RequireSelfOr(auth.ManageUsers, "id")
+	selfOrViewAllLoans := middleware.RequireSelfOr(auth.ViewAllLoans, "id")
+	manageHolds := middleware.Require(auth.ManageHolds)
+	manageFines := middleware.Require(auth.ManageFines)
+	selfOrManageFines := middleware.RequireSelfOr(auth.ManageFines, "id")
+	managePolicies := middleware.Require(auth.ManagePolicies)
 
-	eLibrary := r.Group("/elibrary/v1")
+	eLibrary := r.Group("/elibrary/v1", middleware.Authenticate(tokens))
 	{
-		eLibrary.GET("/book/:title", handlers.GetBook)
-		eLibrary.POST("/borrow", handlers.BorrowBook)
-		eLibrary.POST("/extend", handlers.ExtendBook)
-		eLibrary.POST("/return", handlers.ReturnBook)
-		eLibrary.POST("/create-book", handlers.CreateBook)
-		eLibrary.POST("/create-user", handlers.CreateUser)
+		eLibrary.GET("/book/:title", h.GetBook)
+		eLibrary.POST("/borrow", h.BorrowBook)
+		eLibrary.POST("/extend", h.ExtendBook)
+		eLibrary.POST("/return", h.ReturnBook)
+		eLibrary.POST("/create-book", manageCatalog, h.CreateBook)
+		eLibrary.POST("/create-user", manageUsers, h.CreateUser)
+
+		eLibrary.GET("/books", h.SearchBooks)
+		eLibrary.GET("/books/:id", h.GetBookByID)
+		eLibrary.GET("/books/isbn/:isbn", h.GetBookByISBN)
+		eLibrary.GET("/works/:id", h.GetWork)
+		eLibrary.GET("/books/:id/copies", h.ListCopies)
+		eLibrary.GET("/users", manageUsers, h.ListUsers)
+		eLibrary.GET("/loans", h.ListLoans)
+		eLibrary.GET("/loans/:id", h.GetLoan)
+		eLibrary.POST("/loans/:id/renew", h.RenewLoan)
+		eLibrary.GET("/users/:id/loans", selfOrViewAllLoans, h.ListUserLoans)
+		eLibrary.GET("/me/loans", h.ListMyLoans)
+		eLibrary.POST("/books/:id/copies", manageCatalog, h.AddCopy)
+		eLibrary.PATCH("/copies/:barcode", manageCatalog, h.UpdateCopy)
+		eLibrary.GET("/books/:id/files", h.ListFiles)
+		eLibrary.POST("/books/:id/files", manageCatalog, h.UploadFile)
+		eLibrary.POST("/files/:id/borrow", h.BorrowFile)
+		eLibrary.POST("/loans/:id/download-link", h.CreateDownloadLink)
+		eLibrary.GET("/books/:id/reading", h.GetReading)
+		eLibrary.PUT("/books/:id/reading/progress", h.SaveProgress)
+		eLibrary.POST("/books/:id/reading/bookmarks", h.AddBookmark)
+		eLibrary.POST("/books/:id/reading/highlights", h.AddHighlight)
+		eLibrary.DELETE("/bookmarks/:id", h.DeleteBookmark)
+		eLibrary.DELETE("/highlights/:id", h.DeleteHighlight)
+		eLibrary.GET("/events", h.ListEvents)
+
+		eLibrary.POST("/books/import", manageCatalog, h.ImportBooks)
+		eLibrary.GET("/export/:kind", h.Export)
+		eLibrary.PUT("/books/:id", manageCatalog, h.ReplaceBook)
+		eLibrary.PATCH("/books/:id", manageCatalog, h.PatchBook)
+		eLibrary.DELETE("/books/:id", manageCatalog, h.DeleteBook)
+		eLibrary.POST("/books/:id/restore", manageCatalog, h.RestoreBook)
+		eLibrary.GET("/users/:id", selfOrManageUsers, h.GetUser)
+		eLibrary.PUT("/users/:id", selfOrManageUsers, h.ReplaceUser)
+		eLibrary.PATCH("/users/:id", selfOrManageUsers, h.PatchUser)
+		eLibrary.DELETE("/users/:id", manageUsers, h.DeleteUser)
+		eLibrary.POST("/users/:id/restore", manageUsers, h.RestoreUser)
+
+		eLibrary.POST("/books/:id/holds", h.PlaceHold)
+		eLibrary.GET("/books/:id/holds", manageHolds, h.ListBookHolds)
+		eLibrary.GET("/holds", h.ListHolds)
+		eLibrary.DELETE("/holds/:id", h.CancelHold)
+		eLibrary.PATCH("/holds/:id", manageHolds, h.MoveHold)
+
+		eLibrary.GET("/fines", h.ListFines)
+		eLibrary.GET("/users/:id/fines/balance", selfOrManageFines, h.GetFineBalance)
+		eLibrary.POST("/fines/:id/pay", manageFines, h.PayFine)
+		eLibrary.POST("/fines/:id/waive", manageFines, h.WaiveFine)
+
+		eLibrary.GET("/loan-policies", h.ListLoanPolicies)
+		eLibrary.GET("/loan-policies/:id", h.GetLoanPolicy)
+		eLibrary.POST("/loan-policies", managePolicies, h.CreateLoanPolicy)
+		eLibrary.PUT("/loan-policies/:id", managePolicies, h.ReplaceLoanPolicy)
+		eLibrary.DELETE("/loan-policies/:id", managePolicies, h.DeleteLoanPolicy)
 	}
 
 	return r
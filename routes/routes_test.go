@@ -1,57 +1,94 @@
 package routes
 
 import (
-	"eLibrary/global"
+	"bytes"
+	"context"
+	"eLibrary/config"
+	"eLibrary/database"
+	"eLibrary/internal/api"
+	"eLibrary/internal/auth"
+	"eLibrary/internal/errs"
+	"eLibrary/internal/problem"
+	"eLibrary/internal/repository"
+	"eLibrary/internal/service"
+	"eLibrary/internal/storage"
+	"eLibrary/marc"
 	"eLibrary/model"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+// testTokens signs the tokens of every test request.
+var testTokens, _ = auth.NewIssuer(config.AuthConfig{
+	Secret:          "test-secret",
+	Issuer:          "elibrary-test",
+	AccessTokenTTL:  config.Duration{Duration: time.Hour},
+	RefreshTokenTTL: config.Duration{Duration: 24 * time.Hour},
+})
+
+// bearer returns an Authorization header value for userID acting as role.
+func bearer(userID uint, role model.Role) string {
+	tokens, err := testTokens.Issue(auth.Principal{UserID: userID, Role: role})
+	if err != nil {
+		panic("failed to issue test token")
+	}
+	return "Bearer " + tokens.AccessToken
+}
+
 // Mock DB setup
-func setupMockDB() {
+func setupMockDB() *gorm.DB {
 	mockDB, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	global.Database = mockDB
+
+	// every new connection to ":memory:" is a fresh database, keep just one
+	sqlDB, _ := mockDB.DB()
+	sqlDB.SetMaxOpenConns(1)
 
 	// Migrate the schema
-	global.Database.AutoMigrate(&model.BookDetail{})
-	global.Database.AutoMigrate(&model.User{})
-	global.Database.AutoMigrate(&model.LoanDetail{})
+	if err := database.Migrate(mockDB); err != nil {
+		panic("failed to migrate test database")
+	}
 
 	// Seed test data
-	err := global.Database.Create(&model.BookDetail{
+	err := mockDB.Create(&model.BookDetail{
 		Model: gorm.Model{
 			ID: 1,
 		},
-		Title:           "Test Book",
-		Author:          "Test Author",
-		ISBN:            "1234567890",
-		AvailableCopies: 5,
+		Title:  "Test Book",
+		Author: "Test Author",
+		ISBN:   "1234567890",
 	}).Error
 	if err != nil {
 		panic("failed to create test book")
 	}
+	seedCopies(mockDB, 1, 5)
 
-	err = global.Database.Create(&model.BookDetail{
-		Title:           "Second Book",
-		Author:          "Second Author",
-		ISBN:            "1234567899",
-		AvailableCopies: 0,
+	err = mockDB.Create(&model.BookDetail{
+		Title:  "Second Book",
+		Author: "Second Author",
+		ISBN:   "1234567899",
 	}).Error
 	if err != nil {
 		panic("failed to create second test book")
 	}
 
-	err = global.Database.Create(&model.User{
+	err = mockDB.Create(&model.User{
 		Model: gorm.Model{
 			ID: 1,
 		},
@@ -59,35 +96,108 @@ func setupMockDB() {
 		LastName:  "Chow",
 		Username:  "nickczj",
 		Email:     "nick.chow.zj@gmail.com",
+		// "password", hashed at bcrypt.MinCost to keep the tests fast
+		PasswordHash: "$2a$04$kAGHHZLfKsan73z7We9l.u6Zi6.Mcb1RTWvNuBXJpFMD2s3uu05c.",
+		Role:         model.RoleLibrarian,
 	}).Error
 	if err != nil {
 		panic("failed to create user")
 	}
+
+	return mockDB
+}
+
+// failWrites makes inserts into and updates of the rows of table that match
+// condition fail until the test is done, to see how a failure is dealt with.
+func failWrites(t *testing.T, db *gorm.DB, table string, condition string) {
+	for _, event := range []string{"INSERT", "UPDATE"} {
+		trigger := fmt.Sprintf("fail_%s_%s", strings.ToLower(event), table)
+		err := db.Exec(fmt.Sprintf("CREATE TRIGGER %s BEFORE %s ON %s WHEN %s BEGIN SELECT RAISE(ABORT, 'injected failure'); END",
+			trigger, event, table, condition)).Error
+		if err != nil {
+			t.Fatalf("failed to create trigger: %v", err)
+		}
+		t.Cleanup(func() { db.Exec("DROP TRIGGER " + trigger) })
+	}
+}
+
+func createTestBook(t *testing.T, router *gin.Engine, reqBody string) api.Book {
+	req, _ := http.NewRequest("POST", "/elibrary/v1/create-book", strings.NewReader(reqBody))
+	req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response map[string]api.Book
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	return response["book"]
+}
+
+// page mirrors the envelope of every collection endpoint.
+type page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor"`
+	Total      int    `json:"total"`
+}
+
+func listPage[T any](t *testing.T, router *gin.Engine, url string) page[T] {
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response page[T]
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	return response
+}
+
+func seedCopies(db *gorm.DB, bookID uint, count int) {
+	for i := 1; i <= count; i++ {
+		err := db.Create(&model.BookCopy{
+			BookID:  bookID,
+			Barcode: model.CopyBarcode(bookID, i),
+			Status:  model.CopyAvailable,
+		}).Error
+		if err != nil {
+			panic("failed to create test copy")
+		}
+	}
 }
 
 func TestGetBookAPI(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	setupMockDB()
+	db := setupMockDB()
 
-	router := SetupRouter()
+	router := SetupRouter(db, testTokens)
 
 	t.Run("Book Found", func(t *testing.T) {
 		req, _ := http.NewRequest("GET", "/elibrary/v1/book/Test%20Book", nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		resp := httptest.NewRecorder()
 
 		router.ServeHTTP(resp, req)
 
 		assert.Equal(t, http.StatusOK, resp.Code)
 
-		var response map[string]model.BookDetail
+		var response map[string][]api.Book
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, "Test Book", response["book"].Title)
+		assert.Len(t, response["books"], 1)
+		assert.Equal(t, "Test Book", response["books"][0].Title)
 	})
 
 	t.Run("Book Not Found", func(t *testing.T) {
 		req, _ := http.NewRequest("GET", "/elibrary/v1/book/Unknown%20Book", nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		resp := httptest.NewRecorder()
 
 		router.ServeHTTP(resp, req)
@@ -97,21 +207,22 @@ func TestGetBookAPI(t *testing.T) {
 		var response map[string]interface{}
 		_ = json.Unmarshal(resp.Body.Bytes(), &response)
 
-		// Validate that there's no "book" field or that it is empty
-		assert.Nil(t, response["book"])
+		// Validate that there's no "books" field or that it is empty
+		assert.Nil(t, response["books"])
 	})
 }
 
 func TestBorrowBookAPI(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	// Setup mock database or mock global.Database
-	setupMockDB()
+	// Setup mock database
+	db := setupMockDB()
 
-	router := SetupRouter()
+	router := SetupRouter(db, testTokens)
 
 	t.Run("Invalid Request Body", func(t *testing.T) {
 		req, _ := http.NewRequest("POST", "/elibrary/v1/borrow", nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		resp := httptest.NewRecorder()
 
 		router.ServeHTTP(resp, req)
@@ -121,17 +232,17 @@ func TestBorrowBookAPI(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["bad request"], "invalid request body")
+		assert.Equal(t, "invalid request body", response["title"])
 	})
 
 	t.Run("Validation Failed", func(t *testing.T) {
 		invalidLoan := `{
             "title": "",
-            "user_id": 0,
             "name_of_borrower": ""
         }`
 
 		req, _ := http.NewRequest("POST", "/elibrary/v1/borrow", strings.NewReader(invalidLoan))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 
@@ -142,19 +253,19 @@ func TestBorrowBookAPI(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["bad request"], "validation failed")
+		assert.Equal(t, "validation failed", response["title"])
 	})
 
 	t.Run("Book Not Available", func(t *testing.T) {
 		// Prepare loan request for a book that has no available copies
 		reqBody := `{
             "title": "Second Book",
-            "user_id": 1,
             "name_of_borrower": "John Doe"
         }`
 
 		// Simulate a scenario where no copies are available for the book
 		req, _ := http.NewRequest("POST", "/elibrary/v1/borrow", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 
@@ -165,39 +276,39 @@ func TestBorrowBookAPI(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["bad request"], "there are no more available books to borrow")
+		assert.Equal(t, "no_copies_available", response["code"])
 	})
 
 	t.Run("User Not Found", func(t *testing.T) {
 		// Prepare loan request for a book and a non-existent user
 		reqBody := `{
-            "title": "Test Book",
-            "user_id": 99999
+            "title": "Test Book"
         }`
 
 		// Simulate a scenario where the user doesn't exist
 		req, _ := http.NewRequest("POST", "/elibrary/v1/borrow", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", bearer(99999, model.RolePatron))
 		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 
 		router.ServeHTTP(resp, req)
 
-		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
 
 		var response map[string]interface{}
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["error"], "unable to borrow book")
+		assert.Equal(t, "user_not_found", response["code"])
 	})
 
 	t.Run("Successful Borrow", func(t *testing.T) {
 		reqBody := `{
-            "title": "Test Book",
-            "user_id": 1
+            "title": "Test Book"
         }`
 
 		// Simulate a successful borrowing scenario where a book is available and user exists
 		req, _ := http.NewRequest("POST", "/elibrary/v1/borrow", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 
@@ -222,13 +333,14 @@ func TestBorrowBookAPI(t *testing.T) {
 func TestExtendBookAPI(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	// Setup mock database or mock global.Database
-	setupMockDB()
+	// Setup mock database
+	db := setupMockDB()
 
-	router := SetupRouter()
+	router := SetupRouter(db, testTokens)
 
 	t.Run("Invalid Request Body", func(t *testing.T) {
 		req, _ := http.NewRequest("POST", "/elibrary/v1/extend", nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		resp := httptest.NewRecorder()
 
 		router.ServeHTTP(resp, req)
@@ -238,17 +350,17 @@ func TestExtendBookAPI(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["bad request"], "invalid request body")
+		assert.Equal(t, "invalid request body", response["title"])
 	})
 
 	t.Run("Validation Failed", func(t *testing.T) {
 		invalidLoan := `{
             "title": "",
-            "user_id": 0,
             "name_of_borrower": ""
         }`
 
 		req, _ := http.NewRequest("POST", "/elibrary/v1/extend", strings.NewReader(invalidLoan))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 
@@ -259,18 +371,18 @@ func TestExtendBookAPI(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["bad request"], "validation failed")
+		assert.Equal(t, "validation failed", response["title"])
 	})
 
 	t.Run("Loan Not Found", func(t *testing.T) {
 		// Prepare loan request for a book and a non-existent user
 		reqBody := `{
-            "title": "Test Book",
-            "user_id": 99999
+            "title": "Test Book"
         }`
 
 		// Simulate a scenario where the user doesn't exist
 		req, _ := http.NewRequest("POST", "/elibrary/v1/extend", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", bearer(99999, model.RolePatron))
 		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 
@@ -281,11 +393,11 @@ func TestExtendBookAPI(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["bad request"], "loan not found")
+		assert.Equal(t, "no_active_loan", response["code"])
 	})
 
 	t.Run("Successful Extend", func(t *testing.T) {
-		err := global.Database.Create(&model.LoanDetail{
+		err := db.Create(&model.LoanDetail{
 			BookID: 1,
 			BookDetail: model.BookDetail{
 				Model: gorm.Model{
@@ -312,12 +424,12 @@ func TestExtendBookAPI(t *testing.T) {
 		}
 
 		reqBody := `{
-            "title": "Test Book",
-            "user_id": 1
+            "title": "Test Book"
         }`
 
 		// Simulate a successful borrowing scenario where a book is available and user exists
 		req, _ := http.NewRequest("POST", "/elibrary/v1/extend", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 
@@ -339,13 +451,14 @@ func TestExtendBookAPI(t *testing.T) {
 func TestReturnBookAPI(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	// Setup mock database or mock global.Database
-	setupMockDB()
+	// Setup mock database
+	db := setupMockDB()
 
-	router := SetupRouter()
+	router := SetupRouter(db, testTokens)
 
 	t.Run("Invalid Request Body", func(t *testing.T) {
 		req, _ := http.NewRequest("POST", "/elibrary/v1/return", nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		resp := httptest.NewRecorder()
 
 		router.ServeHTTP(resp, req)
@@ -355,17 +468,17 @@ func TestReturnBookAPI(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["bad request"], "invalid request body")
+		assert.Equal(t, "invalid request body", response["title"])
 	})
 
 	t.Run("Validation Failed", func(t *testing.T) {
 		invalidLoan := `{
             "title": "",
-            "user_id": 0,
             "name_of_borrower": ""
         }`
 
 		req, _ := http.NewRequest("POST", "/elibrary/v1/return", strings.NewReader(invalidLoan))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 
@@ -376,18 +489,18 @@ func TestReturnBookAPI(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["bad request"], "validation failed")
+		assert.Equal(t, "validation failed", response["title"])
 	})
 
 	t.Run("Loan Not Found", func(t *testing.T) {
 		// Prepare loan request for a book and a non-existent user
 		reqBody := `{
-            "title": "Test Book",
-            "user_id": 99999
+            "title": "Test Book"
         }`
 
 		// Simulate a scenario where the user doesn't exist
 		req, _ := http.NewRequest("POST", "/elibrary/v1/return", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", bearer(99999, model.RolePatron))
 		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 
@@ -398,11 +511,11 @@ func TestReturnBookAPI(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["bad request"], "loan not found")
+		assert.Equal(t, "no_active_loan", response["code"])
 	})
 
 	t.Run("Successful Return", func(t *testing.T) {
-		err := global.Database.Create(&model.LoanDetail{
+		err := db.Create(&model.LoanDetail{
 			BookID: 1,
 			BookDetail: model.BookDetail{
 				Model: gorm.Model{
@@ -429,12 +542,12 @@ func TestReturnBookAPI(t *testing.T) {
 		}
 
 		reqBody := `{
-            "title": "Test Book",
-            "user_id": 1
+            "title": "Test Book"
         }`
 
 		// Simulate a successful borrowing scenario where a book is available and user exists
 		req, _ := http.NewRequest("POST", "/elibrary/v1/return", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
 		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 
@@ -452,3 +565,2662 @@ func TestReturnBookAPI(t *testing.T) {
 		assert.Equal(t, "Nick Chow", loan["name_of_borrower"])
 	})
 }
+
+func TestHealthAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	t.Run("Healthy", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/healthz", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Ready", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "ready", response["status"])
+		migrations := response["migrations"].(map[string]interface{})
+		assert.Equal(t, "complete", migrations["status"])
+		database := response["database"].(map[string]interface{})
+		assert.NotNil(t, database["pool"])
+	})
+
+	t.Run("Pending Migrations", func(t *testing.T) {
+		emptyDB, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		router := SetupRouter(emptyDB, testTokens)
+
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		migrations := response["migrations"].(map[string]interface{})
+		assert.Equal(t, "pending", migrations["status"])
+	})
+
+	t.Run("Database Unavailable", func(t *testing.T) {
+		router := SetupRouter(nil, testTokens)
+
+		req, _ := http.NewRequest("GET", "/healthz", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	})
+}
+
+func TestBookCopiesAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	t.Run("List Copies", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/elibrary/v1/books/1/copies", nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string][]api.Copy
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Len(t, response["copies"], 5)
+	})
+
+	t.Run("Add Copy", func(t *testing.T) {
+		reqBody := `{
+            "barcode": "TB-EXTRA",
+            "condition": "new",
+            "shelf_location": "A3"
+        }`
+
+		req, _ := http.NewRequest("POST", "/elibrary/v1/books/1/copies", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.Copy
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, model.CopyAvailable, response["copy"].Status)
+		assert.Equal(t, "A3", response["copy"].ShelfLocation)
+	})
+
+	t.Run("Mark Copy Lost", func(t *testing.T) {
+		req, _ := http.NewRequest("PATCH", "/elibrary/v1/copies/TB-EXTRA", strings.NewReader(`{"status": "lost"}`))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Cannot Put Copy On Loan By Hand", func(t *testing.T) {
+		req, _ := http.NewRequest("PATCH", "/elibrary/v1/copies/TB-EXTRA", strings.NewReader(`{"status": "on-loan"}`))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusConflict, resp.Code)
+	})
+
+	t.Run("Availability Derived From Copies", func(t *testing.T) {
+		reqBody := `{
+            "title": "Test Book"
+        }`
+		req, _ := http.NewRequest("POST", "/elibrary/v1/borrow", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var loanResponse map[string]api.Loan
+		err := json.Unmarshal(resp.Body.Bytes(), &loanResponse)
+		assert.NoError(t, err)
+		assert.NotNil(t, loanResponse["loan"].Copy)
+		assert.Equal(t, model.CopyOnLoan, loanResponse["loan"].Copy.Status)
+
+		req, _ = http.NewRequest("GET", "/elibrary/v1/books/1", nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		resp = httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		var bookResponse map[string]api.Book
+		err = json.Unmarshal(resp.Body.Bytes(), &bookResponse)
+		assert.NoError(t, err)
+		// five seeded copies, one lost extra copy and one out on loan
+		assert.Equal(t, 4, bookResponse["book"].AvailableCopies)
+		assert.Equal(t, 6, bookResponse["book"].TotalCopies)
+	})
+}
+
+func TestCatalogAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	var hardcover, paperback, namesake api.Book
+
+	t.Run("Editions Share A Work", func(t *testing.T) {
+		hardcover = createTestBook(t, router, `{
+            "title": "Dune",
+            "authors": ["Frank Herbert"],
+            "isbn": "9780441013593",
+            "isbn_13": "9780441013593",
+            "publisher": "Ace",
+            "publication_year": 2005,
+            "format": "hardcover",
+            "available_copies": 1
+        }`)
+		paperback = createTestBook(t, router, `{
+            "title": "Dune",
+            "author": "Frank Herbert",
+            "isbn": "0441172717",
+            "isbn_10": "0441172717",
+            "publisher": "Ace",
+            "publication_year": 1990,
+            "format": "paperback",
+            "available_copies": 2
+        }`)
+
+		assert.NotNil(t, hardcover.WorkID)
+		assert.Equal(t, hardcover.WorkID, paperback.WorkID)
+		assert.Equal(t, 2, paperback.AvailableCopies)
+	})
+
+	t.Run("Same Title Different Work", func(t *testing.T) {
+		namesake = createTestBook(t, router, `{
+            "title": "Dune",
+            "author": "Someone Else",
+            "isbn": "9780000000002"
+        }`)
+
+		assert.NotEqual(t, hardcover.WorkID, namesake.WorkID)
+	})
+
+	t.Run("Unknown Work", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/elibrary/v1/create-book", strings.NewReader(`{"title": "Dune", "isbn": "9780593099322", "work_id": 999}`))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+
+	t.Run("Invalid ISBN", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/elibrary/v1/create-book", strings.NewReader(`{"title": "Dune", "author": "Frank Herbert", "isbn": "978-0-441-01359-4"}`))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		var response problem.Problem
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "validation_failed", response.Code)
+		if assert.Len(t, response.Errors, 1) {
+			assert.Equal(t, "isbn", response.Errors[0].Field)
+			assert.Equal(t, "isbn", response.Errors[0].Rule)
+			assert.Contains(t, response.Errors[0].Message, "check digit")
+		}
+	})
+
+	t.Run("Duplicate ISBN In Other Form", func(t *testing.T) {
+		// 0-441-17271-7 is the ISBN-10 of the paperback's 9780441172719
+		req, _ := http.NewRequest("POST", "/elibrary/v1/create-book", strings.NewReader(`{"title": "Dune", "author": "Frank Herbert", "isbn": "978-0-441-17271-9"}`))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusConflict, resp.Code)
+	})
+
+	t.Run("ISBN Normalized", func(t *testing.T) {
+		assert.Equal(t, "9780441172719", paperback.ISBN)
+		assert.Equal(t, "9780441172719", paperback.ISBN13)
+		assert.Equal(t, "0441172717", paperback.ISBN10)
+	})
+
+	t.Run("Lookup By Title", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/elibrary/v1/book/Dune", nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string][]api.Book
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Len(t, response["books"], 3)
+	})
+
+	t.Run("Lookup By ID", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/elibrary/v1/books/%d", paperback.ID), nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.Book
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "paperback", response["book"].Format)
+		assert.Equal(t, "Frank Herbert", response["book"].Work.Authors[0].Name)
+	})
+
+	t.Run("Lookup By ISBN", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/elibrary/v1/books/isbn/0-441-17271-7", nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.Book
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, paperback.ID, response["book"].ID)
+	})
+
+	t.Run("Work With Editions", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/elibrary/v1/works/%d", *hardcover.WorkID), nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.Work
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Len(t, response["work"].Editions, 2)
+		assert.Len(t, response["work"].Authors, 1)
+	})
+}
+
+func TestSearchBooksAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	createTestBook(t, router, `{"title": "The Road to Dune", "authors": ["Brian Herbert", "Kevin J. Anderson"], "isbn": "9780765353702", "available_copies": 1}`)
+	createTestBook(t, router, `{"title": "Dune Messiah", "author": "Frank Herbert", "isbn": "9780593098233"}`)
+	createTestBook(t, router, `{"title": "Dune", "author": "Frank Herbert", "isbn": "9780441013593", "available_copies": 2}`)
+
+	search := func(t *testing.T, query string) (books []api.Book, total int) {
+		page := listPage[api.Book](t, router, "/elibrary/v1/books?"+query)
+		return page.Data, page.Total
+	}
+
+	titles := func(books []api.Book) (titles []string) {
+		for _, book := range books {
+			titles = append(titles, book.Title)
+		}
+		return titles
+	}
+
+	t.Run("Ranked Partial Title Match", func(t *testing.T) {
+		books, total := search(t, "q=DUNE")
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []string{"Dune", "Dune Messiah", "The Road to Dune"}, titles(books))
+	})
+
+	t.Run("Match On Work Authors", func(t *testing.T) {
+		books, _ := search(t, "q=anderson")
+		assert.Equal(t, []string{"The Road to Dune"}, titles(books))
+	})
+
+	t.Run("Match On ISBN", func(t *testing.T) {
+		books, _ := search(t, "q=978-0-441")
+		assert.Equal(t, []string{"Dune"}, titles(books))
+	})
+
+	t.Run("Filter By Author And Availability", func(t *testing.T) {
+		books, _ := search(t, "q=dune&author=frank&available=true")
+		assert.Equal(t, []string{"Dune"}, titles(books))
+
+		books, _ = search(t, "available=false&sort=title")
+		assert.Equal(t, []string{"Dune Messiah", "Second Book"}, titles(books))
+	})
+
+	t.Run("Wildcards Are Literal", func(t *testing.T) {
+		books, total := search(t, "q=%25")
+		assert.Equal(t, 0, total)
+		assert.Empty(t, books)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		first := listPage[api.Book](t, router, "/elibrary/v1/books?q=dune&limit=2")
+		assert.Equal(t, 3, first.Total)
+		assert.Equal(t, []string{"Dune", "Dune Messiah"}, titles(first.Data))
+		assert.NotEmpty(t, first.NextCursor)
+
+		second := listPage[api.Book](t, router, "/elibrary/v1/books?q=dune&limit=2&cursor="+first.NextCursor)
+		assert.Equal(t, []string{"The Road to Dune"}, titles(second.Data))
+		assert.Empty(t, second.NextCursor)
+	})
+
+	t.Run("Invalid Parameters", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/elibrary/v1/books?limit=1000", nil)
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+}
+
+func TestListAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	for _, name := range []string{"ada", "grace", "linus", "margaret"} {
+		err := db.Create(&model.User{FirstName: name, Username: name, Email: name + "@example.com"}).Error
+		assert.NoError(t, err)
+	}
+	err := db.Create(&[]model.LoanDetail{
+		{BookID: 1, UserID: 1, LoanDate: time.Now().AddDate(0, 0, -40), ReturnDate: time.Now().AddDate(0, 0, -12)},
+		{BookID: 1, UserID: 2, LoanDate: time.Now(), ReturnDate: time.Now().AddDate(0, 0, 28)},
+		{BookID: 2, UserID: 1, LoanDate: time.Now().AddDate(0, 0, -60), ReturnDate: time.Now().AddDate(0, 0, -32), IsReturned: true},
+	}).Error
+	assert.NoError(t, err)
+
+	t.Run("Users Walk Every Page", func(t *testing.T) {
+		var usernames []string
+		url := "/elibrary/v1/users?sort=username&limit=2"
+		for pages := 0; pages < 5; pages++ {
+			result := listPage[api.User](t, router, url)
+			assert.Equal(t, 5, result.Total)
+			for _, user := range result.Data {
+				usernames = append(usernames, user.Username)
+			}
+			if result.NextCursor == "" {
+				break
+			}
+			url = "/elibrary/v1/users?sort=username&limit=2&cursor=" + result.NextCursor
+		}
+		assert.Equal(t, []string{"ada", "grace", "linus", "margaret", "nickczj"}, usernames)
+	})
+
+	t.Run("Users Descending And Filtered", func(t *testing.T) {
+		result := listPage[api.User](t, router, "/elibrary/v1/users?sort=-id&q=EXAMPLE.com")
+		assert.Equal(t, 4, result.Total)
+		assert.Equal(t, "margaret", result.Data[0].Username)
+	})
+
+	t.Run("Books Sorted By Title", func(t *testing.T) {
+		result := listPage[api.Book](t, router, "/elibrary/v1/books?sort=-title&limit=1")
+		assert.Equal(t, 2, result.Total)
+		assert.Equal(t, "Test Book", result.Data[0].Title)
+
+		result = listPage[api.Book](t, router, "/elibrary/v1/books?sort=-title&limit=1&cursor="+result.NextCursor)
+		assert.Equal(t, "Second Book", result.Data[0].Title)
+	})
+
+	t.Run("Loans By User", func(t *testing.T) {
+		result := listPage[api.Loan](t, router, "/elibrary/v1/loans?user_id=1")
+		assert.Equal(t, 2, result.Total)
+	})
+
+	t.Run("Loans By Returned State", func(t *testing.T) {
+		result := listPage[api.Loan](t, router, "/elibrary/v1/loans?returned=true")
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, uint(2), result.Data[0].BookID)
+	})
+
+	t.Run("Overdue Loans Sorted By Due Date", func(t *testing.T) {
+		result := listPage[api.Loan](t, router, "/elibrary/v1/loans?overdue=true&sort=return_date")
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, uint(1), result.Data[0].UserID)
+		assert.False(t, result.Data[0].IsReturned)
+	})
+
+	t.Run("Loans Paged By Date", func(t *testing.T) {
+		first := listPage[api.Loan](t, router, "/elibrary/v1/loans?sort=-loan_date&limit=2")
+		assert.Equal(t, 3, first.Total)
+		assert.Len(t, first.Data, 2)
+		assert.Equal(t, uint(2), first.Data[0].UserID)
+
+		second := listPage[api.Loan](t, router, "/elibrary/v1/loans?sort=-loan_date&limit=2&cursor="+first.NextCursor)
+		assert.Len(t, second.Data, 1)
+		assert.True(t, second.Data[0].IsReturned)
+	})
+
+	t.Run("Invalid Sort And Cursor", func(t *testing.T) {
+		for _, url := range []string{"/elibrary/v1/loans?sort=password", "/elibrary/v1/users?cursor=garbage"} {
+			req, _ := http.NewRequest("GET", url, nil)
+			req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		}
+	})
+}
+
+func sendJSON(router *gin.Engine, method string, url string, body string, ifMatch string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestBookCRUDAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	dune := createTestBook(t, router, `{"title": "Dune", "author": "Frank Herbert", "isbn": "9780441013593", "available_copies": 1}`)
+	url := fmt.Sprintf("/elibrary/v1/books/%d", dune.ID)
+
+	t.Run("Get Returns ETag", func(t *testing.T) {
+		resp := sendJSON(router, "GET", url, "", "")
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, `"1"`, resp.Header().Get("ETag"))
+	})
+
+	t.Run("Update Requires If-Match", func(t *testing.T) {
+		resp := sendJSON(router, "PATCH", url, `{"publisher": "Ace"}`, "")
+
+		assert.Equal(t, http.StatusPreconditionRequired, resp.Code)
+	})
+
+	t.Run("Patch Bumps Version", func(t *testing.T) {
+		resp := sendJSON(router, "PATCH", url, `{"publisher": "Ace", "publication_year": 1990}`, `"1"`)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+
+		var response map[string]api.Book
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "Ace", response["book"].Publisher)
+		assert.Equal(t, 1990, response["book"].PublicationYear)
+		assert.Equal(t, "Dune", response["book"].Title)
+		assert.Equal(t, 1, response["book"].TotalCopies)
+	})
+
+	t.Run("Stale Version Is Rejected", func(t *testing.T) {
+		resp := sendJSON(router, "PATCH", url, `{"publisher": "Chilton"}`, `"1"`)
+
+		assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+	})
+
+	t.Run("Put Replaces Fields", func(t *testing.T) {
+		resp := sendJSON(router, "PUT", url, `{"title": "Dune", "author": "Frank Herbert", "isbn": "0-441-01359-7"}`, `W/"2"`)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.Book
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "", response["book"].Publisher)
+		assert.Equal(t, "9780441013593", response["book"].ISBN)
+		assert.Equal(t, uint(3), response["book"].Version)
+	})
+
+	t.Run("Duplicate ISBN Is Rejected", func(t *testing.T) {
+		createTestBook(t, router, `{"title": "Dune Messiah", "author": "Frank Herbert", "isbn": "9780593098233"}`)
+
+		resp := sendJSON(router, "PATCH", url, `{"isbn": "9780593098233"}`, "*")
+
+		assert.Equal(t, http.StatusConflict, resp.Code)
+	})
+
+	t.Run("Delete With Outstanding Loan Is Refused", func(t *testing.T) {
+		resp := sendJSON(router, "POST", "/elibrary/v1/borrow", `{"title": "Dune"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = sendJSON(router, "DELETE", url, "", "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/return", `{"title": "Dune"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Delete And Restore", func(t *testing.T) {
+		resp := sendJSON(router, "DELETE", url, "", `"2"`)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+		resp = sendJSON(router, "DELETE", url, "", `"3"`)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		resp = sendJSON(router, "GET", url, "", "")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/borrow", `{"title": "Dune"}`, "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/create-book", `{"title": "Dune", "isbn": "9780441013593"}`, "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = sendJSON(router, "POST", url+"/restore", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = sendJSON(router, "POST", url+"/restore", "", "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = sendJSON(router, "GET", url, "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Unknown Book", func(t *testing.T) {
+		resp := sendJSON(router, "PATCH", "/elibrary/v1/books/999", `{"title": "Nothing"}`, `"1"`)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+
+		resp = sendJSON(router, "DELETE", "/elibrary/v1/books/999", "", "")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+}
+
+func TestUserCRUDAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	t.Run("Patch And Replace", func(t *testing.T) {
+		resp := sendJSON(router, "GET", "/elibrary/v1/users/1", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		etag := resp.Header().Get("ETag")
+
+		resp = sendJSON(router, "PATCH", "/elibrary/v1/users/1", `{"email": "nick@example.com"}`, etag)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.User
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "nick@example.com", response["user"].Email)
+		assert.Equal(t, "Nick", response["user"].FirstName)
+
+		resp = sendJSON(router, "PUT", "/elibrary/v1/users/1", `{"username": "nick", "email": "nick@example.com"}`, etag)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+		resp = sendJSON(router, "PUT", "/elibrary/v1/users/1", `{"username": "nick", "email": "nick@example.com"}`, `"2"`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		err = json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "nick", response["user"].Username)
+		assert.Equal(t, "", response["user"].FirstName)
+	})
+
+	t.Run("Invalid If-Match", func(t *testing.T) {
+		resp := sendJSON(router, "PATCH", "/elibrary/v1/users/1", `{}`, "3")
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+
+	t.Run("Delete With Active Loan Is Refused", func(t *testing.T) {
+		resp := sendJSON(router, "POST", "/elibrary/v1/borrow", `{"title": "Test Book"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = sendJSON(router, "DELETE", "/elibrary/v1/users/1", "", "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/return", `{"title": "Test Book"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Delete And Restore", func(t *testing.T) {
+		resp := sendJSON(router, "DELETE", "/elibrary/v1/users/1", "", "")
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		result := listPage[api.User](t, router, "/elibrary/v1/users")
+		assert.Equal(t, 0, result.Total)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/borrow", `{"title": "Test Book"}`, "")
+		assert.NotEqual(t, http.StatusOK, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/users/1/restore", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		result = listPage[api.User](t, router, "/elibrary/v1/users")
+		assert.Equal(t, 1, result.Total)
+	})
+}
+
+func TestAuthAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	login := func(t *testing.T, body string) (*httptest.ResponseRecorder, auth.TokenPair) {
+		resp := sendJSON(router, "POST", "/elibrary/v1/auth/login", body, "")
+
+		var response map[string]json.RawMessage
+		var tokens auth.TokenPair
+		_ = json.Unmarshal(resp.Body.Bytes(), &response)
+		_ = json.Unmarshal(response["tokens"], &tokens)
+		return resp, tokens
+	}
+
+	t.Run("Missing Token", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/elibrary/v1/books", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		assert.Contains(t, resp.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+
+	t.Run("Invalid Token", func(t *testing.T) {
+		for _, header := range []string{"Bearer garbage", "Basic bmljazpwYXNzd29yZA=="} {
+			req, _ := http.NewRequest("GET", "/elibrary/v1/books", nil)
+			req.Header.Set("Authorization", header)
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		}
+	})
+
+	t.Run("Wrong Password", func(t *testing.T) {
+		resp, _ := login(t, `{"username": "nickczj", "password": "hunter22"}`)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+		resp, _ = login(t, `{"username": "nobody", "password": "password"}`)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("Login And Refresh", func(t *testing.T) {
+		resp, tokens := login(t, `{"username": "nickczj", "password": "password"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.NotContains(t, resp.Body.String(), "PasswordHash")
+
+		req, _ := http.NewRequest("GET", "/elibrary/v1/books", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		// a refresh token does not authorize API calls, nor an access token a refresh
+		req, _ = http.NewRequest("GET", "/elibrary/v1/books", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.RefreshToken)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/auth/refresh", fmt.Sprintf(`{"refresh_token": %q}`, tokens.AccessToken), "")
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/auth/refresh", fmt.Sprintf(`{"refresh_token": %q}`, tokens.RefreshToken), "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Register And Log In", func(t *testing.T) {
+		resp := sendJSON(router, "POST", "/elibrary/v1/create-user", `{"username": "ada", "email": "ada@example.com", "password": "short"}`, "")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/create-user", `{"username": "nickczj", "email": "nick@example.com", "password": "correct horse"}`, "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/create-user", `{"first_name": "Ada", "username": "ada", "email": "ada@example.com", "password": "correct horse"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp, _ = login(t, `{"username": "ada", "password": "correct horse"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Borrower Comes From Token", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/elibrary/v1/borrow", strings.NewReader(`{"title": "Test Book", "user_id": 2}`))
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.Loan
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), response["loan"].UserID)
+	})
+
+	t.Run("Deleted User Cannot Refresh", func(t *testing.T) {
+		tokens, err := testTokens.Issue(auth.Principal{UserID: 2, Role: model.RolePatron})
+		assert.NoError(t, err)
+
+		resp := sendJSON(router, "DELETE", "/elibrary/v1/users/2", "", "")
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/auth/refresh", fmt.Sprintf(`{"refresh_token": %q}`, tokens.RefreshToken), "")
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+}
+
+func TestRoleAccessAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	patron := model.User{FirstName: "Pat", Username: "pat", Email: "pat@example.com", Role: model.RolePatron}
+	admin := model.User{FirstName: "Ada", Username: "ada", Email: "ada@example.com", Role: model.RoleAdmin}
+	assert.NoError(t, db.Create(&patron).Error)
+	assert.NoError(t, db.Create(&admin).Error)
+	assert.NoError(t, db.Create(&[]model.LoanDetail{
+		{BookID: 1, UserID: 1, LoanDate: time.Now(), ReturnDate: time.Now().AddDate(0, 0, 28)},
+		{BookID: 1, UserID: patron.ID, LoanDate: time.Now(), ReturnDate: time.Now().AddDate(0, 0, 28)},
+	}).Error)
+
+	send := func(token string, method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", "*")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	asPatron := bearer(patron.ID, model.RolePatron)
+	asLibrarian := bearer(1, model.RoleLibrarian)
+	asAdmin := bearer(admin.ID, model.RoleAdmin)
+	patronURL := fmt.Sprintf("/elibrary/v1/users/%d", patron.ID)
+	adminURL := fmt.Sprintf("/elibrary/v1/users/%d", admin.ID)
+
+	t.Run("Patrons Cannot Manage The Catalog", func(t *testing.T) {
+		resp := send(asPatron, "POST", "/elibrary/v1/create-book", `{"title": "Dune", "isbn": "9780441013593"}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(asPatron, "PATCH", "/elibrary/v1/books/1", `{"title": "Mine Now"}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(asPatron, "GET", "/elibrary/v1/books/1", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Patrons Only See Themselves", func(t *testing.T) {
+		resp := send(asPatron, "GET", "/elibrary/v1/users", "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(asPatron, "GET", "/elibrary/v1/users/1", "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(asPatron, "GET", patronURL, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = send(asPatron, "PATCH", patronURL, `{"email": "pat@example.org"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = send(asPatron, "PATCH", patronURL, `{"role": "admin"}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("Patrons Only See Their Own Loans", func(t *testing.T) {
+		result := listPage[api.Loan](t, router, "/elibrary/v1/loans")
+		assert.Equal(t, 2, result.Total)
+
+		resp := send(asPatron, "GET", "/elibrary/v1/loans", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response page[api.Loan]
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, response.Total)
+		assert.Equal(t, patron.ID, response.Data[0].UserID)
+
+		resp = send(asPatron, "GET", "/elibrary/v1/loans?user_id=1", "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("Librarians Cannot Touch Staff Or Roles", func(t *testing.T) {
+		resp := send(asLibrarian, "POST", "/elibrary/v1/create-user", `{"username": "root", "email": "root@example.com", "password": "correct horse", "role": "admin"}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(asLibrarian, "PATCH", adminURL, `{"password": "correct horse"}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(asLibrarian, "PATCH", patronURL, `{"role": "librarian"}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(asLibrarian, "PATCH", patronURL, `{"first_name": "Patricia"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = send(asLibrarian, "POST", "/elibrary/v1/create-user", `{"username": "newbie", "email": "newbie@example.com", "password": "correct horse"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Admins Assign Roles", func(t *testing.T) {
+		resp := send(asAdmin, "PATCH", patronURL, `{"role": "wizard"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = send(asAdmin, "PATCH", patronURL, `{"role": "librarian"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		result := listPage[api.User](t, router, "/elibrary/v1/users?role=librarian")
+		assert.Equal(t, 2, result.Total)
+	})
+}
+
+func TestHoldsAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+	seedCopies(db, 2, 1)
+
+	router := SetupRouter(db, testTokens)
+
+	first := model.User{FirstName: "Ann", Username: "ann", Email: "ann@example.com", Role: model.RolePatron}
+	second := model.User{FirstName: "Ben", Username: "ben", Email: "ben@example.com", Role: model.RolePatron}
+	third := model.User{FirstName: "Cat", Username: "cat", Email: "cat@example.com", Role: model.RolePatron}
+	assert.NoError(t, db.Create(&[]*model.User{&first, &second, &third}).Error)
+
+	send := func(userID uint, method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", bearer(userID, model.RolePatron))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	holdFrom := func(t *testing.T, resp *httptest.ResponseRecorder) api.Hold {
+		var response map[string]api.Hold
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		return response["hold"]
+	}
+	copyStatus := func(t *testing.T) model.CopyStatus {
+		var bookCopy model.BookCopy
+		assert.NoError(t, db.Where("book_id = ?", 2).First(&bookCopy).Error)
+		return bookCopy.Status
+	}
+
+	resp := send(first.ID, "POST", "/elibrary/v1/borrow", `{"title": "Second Book"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var secondHold, thirdHold api.Hold
+
+	t.Run("Place Holds", func(t *testing.T) {
+		resp := send(second.ID, "POST", "/elibrary/v1/books/2/holds", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		secondHold = holdFrom(t, resp)
+		assert.Equal(t, model.HoldWaiting, secondHold.Status)
+		assert.Equal(t, 1, secondHold.Position)
+
+		resp = send(third.ID, "POST", "/elibrary/v1/books/2/holds", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		thirdHold = holdFrom(t, resp)
+		assert.Equal(t, 2, thirdHold.Position)
+	})
+
+	t.Run("Rejected Holds", func(t *testing.T) {
+		resp := send(second.ID, "POST", "/elibrary/v1/books/2/holds", "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = send(first.ID, "POST", "/elibrary/v1/books/2/holds", "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = send(second.ID, "POST", "/elibrary/v1/books/1/holds", "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = send(second.ID, "POST", "/elibrary/v1/books/99999/holds", "")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+
+	t.Run("Queue Access", func(t *testing.T) {
+		resp := send(second.ID, "GET", "/elibrary/v1/books/2/holds", "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = sendJSON(router, "GET", "/elibrary/v1/books/2/holds", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string][]api.Hold
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Len(t, response["holds"], 2)
+		assert.Equal(t, second.ID, response["holds"][0].UserID)
+
+		resp = send(second.ID, "GET", "/elibrary/v1/holds", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var holds page[api.Hold]
+		err = json.Unmarshal(resp.Body.Bytes(), &holds)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, holds.Total)
+
+		resp = send(second.ID, "GET", fmt.Sprintf("/elibrary/v1/holds?user_id=%d", third.ID), "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(second.ID, "PATCH", fmt.Sprintf("/elibrary/v1/holds/%d", thirdHold.ID), `{"position": 1}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(second.ID, "DELETE", fmt.Sprintf("/elibrary/v1/holds/%d", thirdHold.ID), "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("Reorder Queue", func(t *testing.T) {
+		resp := sendJSON(router, "PATCH", fmt.Sprintf("/elibrary/v1/holds/%d", thirdHold.ID), `{"position": 1}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, 1, holdFrom(t, resp).Position)
+
+		resp = sendJSON(router, "PATCH", fmt.Sprintf("/elibrary/v1/holds/%d", thirdHold.ID), `{"position": 2}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, 2, holdFrom(t, resp).Position)
+
+		resp = sendJSON(router, "PATCH", fmt.Sprintf("/elibrary/v1/holds/%d", thirdHold.ID), `{"position": 0}`, "")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+
+	t.Run("Return Assigns The Copy", func(t *testing.T) {
+		resp := send(first.ID, "POST", "/elibrary/v1/return", `{"title": "Second Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, model.CopyOnHold, copyStatus(t))
+
+		resp = send(second.ID, "GET", "/elibrary/v1/holds?status=ready", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var holds page[api.Hold]
+		err := json.Unmarshal(resp.Body.Bytes(), &holds)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, holds.Total)
+		assert.NotNil(t, holds.Data[0].CopyID)
+		assert.NotNil(t, holds.Data[0].ExpiresAt)
+	})
+
+	t.Run("Only The Holder Borrows", func(t *testing.T) {
+		resp := send(first.ID, "POST", "/elibrary/v1/borrow", `{"title": "Second Book"}`)
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = send(second.ID, "POST", "/elibrary/v1/borrow", `{"title": "Second Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, model.CopyOnLoan, copyStatus(t))
+
+		resp = send(second.ID, "GET", "/elibrary/v1/holds?status=fulfilled", "")
+		var holds page[api.Hold]
+		err := json.Unmarshal(resp.Body.Bytes(), &holds)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, holds.Total)
+	})
+
+	t.Run("Uncollected Holds Expire", func(t *testing.T) {
+		resp := send(second.ID, "POST", "/elibrary/v1/return", `{"title": "Second Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, model.CopyOnHold, copyStatus(t))
+
+		svc := service.New(repository.NewGormRepositories(db))
+		expired, err := svc.ExpireHolds(context.Background(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, expired)
+
+		expired, err = svc.ExpireHolds(context.Background(), time.Now().Add(service.DefaultHoldPickupWindow+time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.Equal(t, model.CopyAvailable, copyStatus(t))
+
+		resp = send(third.ID, "DELETE", fmt.Sprintf("/elibrary/v1/holds/%d", thirdHold.ID), "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+	})
+
+	t.Run("Cancel Hold", func(t *testing.T) {
+		resp := send(first.ID, "POST", "/elibrary/v1/borrow", `{"title": "Second Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = send(third.ID, "POST", "/elibrary/v1/books/2/holds", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		hold := holdFrom(t, resp)
+
+		resp = send(third.ID, "DELETE", fmt.Sprintf("/elibrary/v1/holds/%d", hold.ID), "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, model.HoldCancelled, holdFrom(t, resp).Status)
+
+		resp = send(third.ID, "DELETE", fmt.Sprintf("/elibrary/v1/holds/%d", hold.ID), "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = send(first.ID, "POST", "/elibrary/v1/return", `{"title": "Second Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, model.CopyAvailable, copyStatus(t))
+	})
+
+	t.Run("Expiry Skips A Failing Hold", func(t *testing.T) {
+		readyAt := time.Now().Add(-2 * service.DefaultHoldPickupWindow)
+		expiresAt := readyAt.Add(service.DefaultHoldPickupWindow)
+		holds := []model.Hold{
+			{BookID: 1, UserID: first.ID, Position: 1, Status: model.HoldReady, ReadyAt: &readyAt, ExpiresAt: &expiresAt},
+			{BookID: 1, UserID: second.ID, Position: 2, Status: model.HoldReady, ReadyAt: &readyAt, ExpiresAt: &expiresAt},
+		}
+		assert.NoError(t, db.Create(&holds).Error)
+		statusOf := func(hold model.Hold) model.HoldStatus {
+			assert.NoError(t, db.First(&hold, hold.ID).Error)
+			return hold.Status
+		}
+
+		svc := service.New(repository.NewGormRepositories(db))
+		t.Run("While It Fails", func(t *testing.T) {
+			failWrites(t, db, "holds", fmt.Sprintf("NEW.id = %d", holds[0].ID))
+			expired, err := svc.ExpireHolds(context.Background(), time.Now())
+			assert.Error(t, err)
+			assert.Equal(t, 1, expired)
+			assert.Equal(t, model.HoldReady, statusOf(holds[0]))
+			assert.Equal(t, model.HoldExpired, statusOf(holds[1]))
+		})
+
+		expired, err := svc.ExpireHolds(context.Background(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.Equal(t, model.HoldExpired, statusOf(holds[0]))
+	})
+
+	t.Run("Deleting The Book Cancels Its Holds", func(t *testing.T) {
+		resp := send(first.ID, "POST", "/elibrary/v1/borrow", `{"title": "Second Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = send(second.ID, "POST", "/elibrary/v1/books/2/holds", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		ready := holdFrom(t, resp)
+		resp = send(third.ID, "POST", "/elibrary/v1/books/2/holds", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		waiting := holdFrom(t, resp)
+
+		resp = send(first.ID, "POST", "/elibrary/v1/return", `{"title": "Second Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, model.CopyOnHold, copyStatus(t))
+
+		resp = sendJSON(router, "DELETE", "/elibrary/v1/books/2", "", "")
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Equal(t, model.CopyAvailable, copyStatus(t))
+
+		for _, id := range []uint{ready.ID, waiting.ID} {
+			var hold model.Hold
+			assert.NoError(t, db.First(&hold, id).Error)
+			assert.Equal(t, model.HoldCancelled, hold.Status)
+			assert.Nil(t, hold.CopyID)
+		}
+	})
+}
+
+func TestFinesAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+	seedCopies(db, 2, 1)
+
+	router := SetupRouter(db, testTokens)
+
+	patron := model.User{FirstName: "Pat", Username: "pat", Email: "pat@example.com", Role: model.RolePatron}
+	other := model.User{FirstName: "Oli", Username: "oli", Email: "oli@example.com", Role: model.RolePatron}
+	assert.NoError(t, db.Create(&[]*model.User{&patron, &other}).Error)
+
+	send := func(userID uint, method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", bearer(userID, model.RolePatron))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	fineFrom := func(t *testing.T, resp *httptest.ResponseRecorder) api.Fine {
+		var response map[string]api.Fine
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		return response["fine"]
+	}
+	balanceURL := fmt.Sprintf("/elibrary/v1/users/%d/fines/balance", patron.ID)
+
+	for _, title := range []string{"Test Book", "Second Book"} {
+		resp := send(patron.ID, "POST", "/elibrary/v1/borrow", fmt.Sprintf(`{"title": %q}`, title))
+		assert.Equal(t, http.StatusOK, resp.Code)
+	}
+	// two and a half days late is charged as three started days, sixty days
+	// late hits the cap
+	assert.NoError(t, db.Model(&model.LoanDetail{}).Where("book_id = ?", 1).
+		Update("return_date", time.Now().Add(-60*time.Hour)).Error)
+	assert.NoError(t, db.Model(&model.LoanDetail{}).Where("book_id = ?", 2).
+		Update("return_date", time.Now().AddDate(0, 0, -60)).Error)
+
+	var lateFine api.Fine
+	var cappedFine model.Fine
+
+	t.Run("Fine On Return", func(t *testing.T) {
+		resp := send(patron.ID, "POST", "/elibrary/v1/return", `{"title": "Test Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.Loan
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.NotNil(t, response["loan"].ReturnedAt)
+		if assert.NotNil(t, response["loan"].Fine) {
+			lateFine = *response["loan"].Fine
+		}
+		assert.Equal(t, 3, lateFine.DaysOverdue)
+		assert.Equal(t, int64(75), lateFine.Amount)
+		assert.Equal(t, model.FineOutstanding, lateFine.Status)
+		assert.True(t, lateFine.Final)
+	})
+
+	t.Run("Periodic Accrual", func(t *testing.T) {
+		svc := service.New(repository.NewGormRepositories(db))
+		accrued, err := svc.AccrueFines(context.Background(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, accrued)
+
+		accrued, err = svc.AccrueFines(context.Background(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, accrued)
+
+		assert.NoError(t, db.Where("loan_id <> ?", lateFine.LoanID).First(&cappedFine).Error)
+		assert.Equal(t, service.DefaultFinePolicy.MaxPerItem, cappedFine.Amount)
+		assert.False(t, cappedFine.Final)
+	})
+
+	t.Run("Balance Blocks Borrowing", func(t *testing.T) {
+		resp := send(patron.ID, "GET", balanceURL, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, fmt.Sprintf(`{"user_id": %d, "balance": 1075, "borrowing_blocked": true}`, patron.ID), resp.Body.String())
+
+		resp = send(other.ID, "GET", balanceURL, "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(patron.ID, "POST", "/elibrary/v1/borrow", `{"title": "Test Book"}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("List Fines", func(t *testing.T) {
+		resp := send(patron.ID, "GET", "/elibrary/v1/fines", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var fines page[api.Fine]
+		err := json.Unmarshal(resp.Body.Bytes(), &fines)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, fines.Total)
+
+		resp = send(other.ID, "GET", "/elibrary/v1/fines", "")
+		err = json.Unmarshal(resp.Body.Bytes(), &fines)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, fines.Total)
+
+		resp = send(other.ID, "GET", fmt.Sprintf("/elibrary/v1/fines?user_id=%d", patron.ID), "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(patron.ID, "GET", "/elibrary/v1/fines?status=forgiven", "")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		// callers other than the API may leave the page size out
+		svc := service.New(repository.NewGormRepositories(db))
+		all, err := svc.ListFines(context.Background(), repository.FineFilter{UserID: patron.ID})
+		assert.NoError(t, err)
+		assert.Len(t, all.Items, 2)
+		assert.Empty(t, all.NextCursor)
+
+		for _, sort := range []string{"amount", "-amount"} {
+			url := "/elibrary/v1/fines?limit=1&sort=" + sort
+			first := listPage[api.Fine](t, router, url)
+			assert.Len(t, first.Data, 1)
+
+			// the cursor carries the amount as a number, Postgres will not
+			// compare an integer column with text
+			data, err := base64.RawURLEncoding.DecodeString(first.NextCursor)
+			assert.NoError(t, err)
+			assert.Contains(t, string(data), `"k":"i"`)
+
+			second := listPage[api.Fine](t, router, url+"&cursor="+first.NextCursor)
+			assert.Len(t, second.Data, 1)
+			assert.Empty(t, second.NextCursor)
+			if len(first.Data) == 1 && len(second.Data) == 1 {
+				amounts := []int64{first.Data[0].Amount, second.Data[0].Amount}
+				if sort == "amount" {
+					assert.Equal(t, []int64{75, 1000}, amounts)
+				} else {
+					assert.Equal(t, []int64{1000, 75}, amounts)
+				}
+			}
+		}
+	})
+
+	t.Run("Pay Fine", func(t *testing.T) {
+		payURL := fmt.Sprintf("/elibrary/v1/fines/%d/pay", lateFine.ID)
+
+		// payments are taken at the desk, not even the patron who owes the
+		// fine may record one
+		resp := send(patron.ID, "POST", payURL, `{"amount": 75}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(other.ID, "POST", payURL, `{"amount": 75}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = sendJSON(router, "POST", payURL, `{"amount": 0}`, "")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = sendJSON(router, "POST", payURL, `{"amount": 100}`, "")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = sendJSON(router, "POST", payURL, `{"amount": 50}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, model.FineOutstanding, fineFrom(t, resp).Status)
+
+		resp = sendJSON(router, "POST", payURL, `{"amount": 25}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		fine := fineFrom(t, resp)
+		assert.Equal(t, model.FinePaid, fine.Status)
+		assert.NotNil(t, fine.SettledAt)
+
+		resp = sendJSON(router, "POST", payURL, `{"amount": 25}`, "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+	})
+
+	t.Run("Waive Fine", func(t *testing.T) {
+		waiveURL := fmt.Sprintf("/elibrary/v1/fines/%d/waive", cappedFine.ID)
+
+		resp := send(patron.ID, "POST", waiveURL, `{"reason": "please"}`)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = sendJSON(router, "POST", waiveURL, `{}`, "")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = sendJSON(router, "POST", waiveURL, `{"reason": "book was damaged in our book drop"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, model.FineWaived, fineFrom(t, resp).Status)
+
+		resp = send(patron.ID, "GET", balanceURL, "")
+		assert.JSONEq(t, fmt.Sprintf(`{"user_id": %d, "balance": 0, "borrowing_blocked": false}`, patron.ID), resp.Body.String())
+
+		resp = send(patron.ID, "POST", "/elibrary/v1/borrow", `{"title": "Test Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Accrual Skips A Failing Loan", func(t *testing.T) {
+		loans := []model.LoanDetail{
+			{BookID: 2, UserID: other.ID, NameOfBorrower: "Oli", LoanDate: time.Now().AddDate(0, 0, -16), ReturnDate: time.Now().AddDate(0, 0, -2)},
+			{BookID: 2, UserID: other.ID, NameOfBorrower: "Oli", LoanDate: time.Now().AddDate(0, 0, -16), ReturnDate: time.Now().AddDate(0, 0, -2)},
+		}
+		assert.NoError(t, db.Create(&loans).Error)
+		fineOf := func(loan model.LoanDetail) (count int64) {
+			db.Model(&model.Fine{}).Where("loan_id = ?", loan.ID).Count(&count)
+			return count
+		}
+
+		svc := service.New(repository.NewGormRepositories(db))
+		t.Run("While It Fails", func(t *testing.T) {
+			failWrites(t, db, "fines", fmt.Sprintf("NEW.loan_id = %d", loans[0].ID))
+			accrued, err := svc.AccrueFines(context.Background(), time.Now())
+			assert.Error(t, err)
+			assert.Equal(t, 1, accrued)
+			assert.Zero(t, fineOf(loans[0]))
+			assert.Equal(t, int64(1), fineOf(loans[1]))
+		})
+
+		accrued, err := svc.AccrueFines(context.Background(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, accrued)
+		assert.Equal(t, int64(1), fineOf(loans[0]))
+	})
+}
+
+func TestLoanPoliciesAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+	seedCopies(db, 2, 1)
+
+	router := SetupRouter(db, testTokens)
+
+	student := model.User{FirstName: "Stu", Username: "stu", Email: "stu@example.com", Role: model.RolePatron, Category: "student"}
+	patron := model.User{FirstName: "Pat", Username: "pat", Email: "pat@example.com", Role: model.RolePatron}
+	admin := model.User{FirstName: "Ada", Username: "ada", Email: "ada@example.com", Role: model.RoleAdmin}
+	assert.NoError(t, db.Create(&[]*model.User{&student, &patron, &admin}).Error)
+
+	send := func(token string, method string, url string, body string, ifMatch string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	loanFrom := func(t *testing.T, resp *httptest.ResponseRecorder) api.Loan {
+		var response map[string]api.Loan
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		return response["loan"]
+	}
+	asStudent := bearer(student.ID, model.RolePatron)
+	asPatron := bearer(patron.ID, model.RolePatron)
+	asAdmin := bearer(admin.ID, model.RoleAdmin)
+	studentPolicy := `{"patron_category": "student", "loan_days": 7, "renewal_days": 7, "max_renewals": 1, "max_concurrent_loans": 1, "block_renewal_on_holds": true}`
+
+	var policy api.LoanPolicy
+
+	t.Run("Default Policy Is Seeded", func(t *testing.T) {
+		resp := send(asPatron, "GET", "/elibrary/v1/loan-policies", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string][]api.LoanPolicy
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		if assert.Len(t, response["loan_policies"], 1) {
+			assert.Equal(t, 28, response["loan_policies"][0].LoanDays)
+		}
+	})
+
+	t.Run("Only Admins Edit Policies", func(t *testing.T) {
+		resp := send(asPatron, "POST", "/elibrary/v1/loan-policies", studentPolicy, "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = sendJSON(router, "POST", "/elibrary/v1/loan-policies", studentPolicy, "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(asAdmin, "POST", "/elibrary/v1/loan-policies", `{"patron_category": "student", "loan_days": 0, "renewal_days": 7}`, "")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = send(asAdmin, "POST", "/elibrary/v1/loan-policies", studentPolicy, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.LoanPolicy
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		policy = response["loan_policy"]
+		assert.Equal(t, "student", policy.PatronCategory)
+
+		resp = send(asAdmin, "POST", "/elibrary/v1/loan-policies", studentPolicy, "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+	})
+
+	t.Run("Patrons Cannot Pick Their Category", func(t *testing.T) {
+		resp := send(asPatron, "PATCH", fmt.Sprintf("/elibrary/v1/users/%d", patron.ID), `{"category": "student"}`, "*")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("Borrowing Follows The Policy", func(t *testing.T) {
+		resp := send(asStudent, "POST", "/elibrary/v1/borrow", `{"title": "Test Book"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		loan := loanFrom(t, resp)
+		assert.WithinDuration(t, time.Now().AddDate(0, 0, 7), loan.ReturnDate.Time, time.Minute)
+
+		resp = send(asStudent, "POST", "/elibrary/v1/borrow", `{"title": "Second Book"}`, "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("Renewal Limit", func(t *testing.T) {
+		resp := send(asStudent, "POST", "/elibrary/v1/extend", `{"title": "Test Book"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		loan := loanFrom(t, resp)
+		assert.Equal(t, 1, loan.Renewals)
+		assert.WithinDuration(t, time.Now().AddDate(0, 0, 14), loan.ReturnDate.Time, time.Minute)
+
+		resp = send(asStudent, "POST", "/elibrary/v1/extend", `{"title": "Test Book"}`, "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+	})
+
+	t.Run("Holds Block Renewal", func(t *testing.T) {
+		resp := send(asPatron, "POST", "/elibrary/v1/borrow", `{"title": "Second Book"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = send(asStudent, "POST", "/elibrary/v1/books/2/holds", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = send(asPatron, "POST", "/elibrary/v1/extend", `{"title": "Second Book"}`, "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+	})
+
+	t.Run("Replace Policy", func(t *testing.T) {
+		url := fmt.Sprintf("/elibrary/v1/loan-policies/%d", policy.ID)
+		relaxed := strings.Replace(studentPolicy, `"max_renewals": 1`, `"max_renewals": 3`, 1)
+
+		resp := send(asAdmin, "PUT", url, relaxed, "")
+		assert.Equal(t, http.StatusPreconditionRequired, resp.Code)
+
+		resp = send(asAdmin, "PUT", url, relaxed, `"1"`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+
+		resp = send(asAdmin, "PUT", url, relaxed, `"1"`)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+		resp = send(asStudent, "POST", "/elibrary/v1/extend", `{"title": "Test Book"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, 2, loanFrom(t, resp).Renewals)
+	})
+
+	t.Run("Delete Policy", func(t *testing.T) {
+		url := fmt.Sprintf("/elibrary/v1/loan-policies/%d", policy.ID)
+
+		resp := send(asAdmin, "DELETE", url, "", "")
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		resp = send(asAdmin, "GET", url, "", "")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+
+		resp = send(asAdmin, "POST", "/elibrary/v1/loan-policies", studentPolicy, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+}
+
+func TestRenewalsAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	patron := model.User{FirstName: "Pat", Username: "pat", Email: "pat@example.com", Role: model.RolePatron}
+	other := model.User{FirstName: "Oli", Username: "oli", Email: "oli@example.com", Role: model.RolePatron}
+	assert.NoError(t, db.Create(&[]*model.User{&patron, &other}).Error)
+
+	send := func(userID uint, method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", bearer(userID, model.RolePatron))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	loanFrom := func(t *testing.T, resp *httptest.ResponseRecorder) api.Loan {
+		var response map[string]api.Loan
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		return response["loan"]
+	}
+	book := `{"title": "Test Book"}`
+
+	t.Run("Returned Loans Cannot Be Extended", func(t *testing.T) {
+		resp := send(patron.ID, "POST", "/elibrary/v1/borrow", book)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = send(patron.ID, "POST", "/elibrary/v1/extend", book)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		loan := loanFrom(t, resp)
+		assert.Equal(t, 1, loan.Renewals)
+		if assert.Len(t, loan.RenewalHistory, 1) {
+			assert.Equal(t, patron.ID, loan.RenewalHistory[0].RenewedBy)
+			assert.Equal(t, loan.ReturnDate.Unix(), loan.RenewalHistory[0].NewReturnDate.Unix())
+		}
+
+		resp = send(patron.ID, "POST", "/elibrary/v1/return", book)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = send(patron.ID, "POST", "/elibrary/v1/extend", book)
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = send(patron.ID, "POST", fmt.Sprintf("/elibrary/v1/loans/%d/renew", loan.ID), "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		var returned model.LoanDetail
+		assert.NoError(t, db.First(&returned, loan.ID).Error)
+		assert.Equal(t, 1, returned.Renewals)
+	})
+
+	var loan api.Loan
+
+	t.Run("Grace Period", func(t *testing.T) {
+		resp := send(patron.ID, "POST", "/elibrary/v1/borrow", book)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		loan = loanFrom(t, resp)
+		assert.NoError(t, db.Model(&model.LoanDetail{}).Where("id = ?", loan.ID).Update("return_date", time.Now().AddDate(0, 0, -2)).Error)
+
+		resp = send(patron.ID, "POST", "/elibrary/v1/extend", book)
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		assert.NoError(t, db.Model(&model.LoanPolicy{}).Where("patron_category = ''").Update("renewal_grace_days", 3).Error)
+
+		resp = send(patron.ID, "POST", "/elibrary/v1/extend", book)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Staff Renewals", func(t *testing.T) {
+		url := fmt.Sprintf("/elibrary/v1/loans/%d/renew", loan.ID)
+
+		resp := send(other.ID, "POST", url, "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = sendJSON(router, "POST", url, "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, 2, loanFrom(t, resp).Renewals)
+
+		resp = send(patron.ID, "POST", url, "")
+		assert.Equal(t, http.StatusConflict, resp.Code)
+	})
+
+	t.Run("History In Loan Response", func(t *testing.T) {
+		url := fmt.Sprintf("/elibrary/v1/loans/%d", loan.ID)
+
+		resp := send(other.ID, "GET", url, "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(patron.ID, "GET", url, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		history := loanFrom(t, resp).RenewalHistory
+		if assert.Len(t, history, 2) {
+			assert.Equal(t, patron.ID, history[0].RenewedBy)
+			assert.Equal(t, uint(1), history[1].RenewedBy)
+			assert.Equal(t, history[0].NewReturnDate.Unix(), history[1].PreviousReturnDate.Unix())
+		}
+
+		loans := listPage[api.Loan](t, router, fmt.Sprintf("/elibrary/v1/loans?user_id=%d&returned=false", patron.ID))
+		if assert.Equal(t, 1, loans.Total) {
+			assert.Len(t, loans.Data[0].RenewalHistory, 2)
+		}
+	})
+}
+
+func TestUserLoansAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	patron := model.User{FirstName: "Pat", Username: "pat", Email: "pat@example.com", Role: model.RolePatron}
+	other := model.User{FirstName: "Oli", Username: "oli", Email: "oli@example.com", Role: model.RolePatron}
+	assert.NoError(t, db.Create(&[]*model.User{&patron, &other}).Error)
+
+	now := time.Now()
+	returnedAt := now.AddDate(0, 0, -10)
+	assert.NoError(t, db.Create(&[]model.LoanDetail{
+		{BookID: 1, UserID: patron.ID, LoanDate: now.AddDate(0, 0, -27), ReturnDate: now.AddDate(0, 0, 1)},
+		{BookID: 2, UserID: patron.ID, LoanDate: now.AddDate(0, 0, -30), ReturnDate: now.AddDate(0, 0, -2)},
+		{BookID: 2, UserID: patron.ID, LoanDate: now.AddDate(0, 0, -60), ReturnDate: now.AddDate(0, 0, -32), IsReturned: true, ReturnedAt: &returnedAt},
+		{BookID: 1, UserID: other.ID, LoanDate: now, ReturnDate: now.AddDate(0, 0, 28)},
+	}).Error)
+
+	list := func(userID uint, url string) (int, page[api.Loan]) {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", bearer(userID, model.RolePatron))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		var response page[api.Loan]
+		_ = json.Unmarshal(resp.Body.Bytes(), &response)
+		return resp.Code, response
+	}
+
+	t.Run("My Loans", func(t *testing.T) {
+		code, loans := list(patron.ID, "/elibrary/v1/me/loans?sort=return_date")
+		assert.Equal(t, http.StatusOK, code)
+		if assert.Equal(t, 3, loans.Total) {
+			past, overdue, dueSoon := loans.Data[0], loans.Data[1], loans.Data[2]
+			assert.True(t, past.IsReturned)
+			assert.False(t, past.Overdue || past.DueSoon)
+			assert.True(t, overdue.Overdue)
+			assert.False(t, overdue.DueSoon)
+			assert.True(t, dueSoon.DueSoon)
+			assert.False(t, dueSoon.Overdue)
+			assert.Equal(t, "Test Book", dueSoon.Book.Title)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		for query, total := range map[string]int{
+			"status=current": 2,
+			"status=past":    1,
+			"due_soon=true":  1,
+			"overdue=true":   1,
+			"book_id=2":      2,
+		} {
+			code, loans := list(patron.ID, "/elibrary/v1/me/loans?"+query)
+			assert.Equal(t, http.StatusOK, code, query)
+			assert.Equal(t, total, loans.Total, query)
+		}
+
+		code, _ := list(patron.ID, "/elibrary/v1/me/loans?status=lost")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("User Loans", func(t *testing.T) {
+		url := fmt.Sprintf("/elibrary/v1/users/%d/loans", patron.ID)
+
+		code, loans := list(patron.ID, url)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 3, loans.Total)
+
+		code, _ = list(other.ID, url)
+		assert.Equal(t, http.StatusForbidden, code)
+
+		result := listPage[api.Loan](t, router, url+"?status=current")
+		assert.Equal(t, 2, result.Total)
+
+		resp := sendJSON(router, "GET", "/elibrary/v1/users/99999/loans", "", "")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+}
+
+func TestProblemDetails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	decode := func(t *testing.T, resp *httptest.ResponseRecorder) problem.Problem {
+		assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
+		var p problem.Problem
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &p))
+		assert.Equal(t, resp.Code, p.Status)
+		return p
+	}
+
+	t.Run("Not Found", func(t *testing.T) {
+		resp := sendJSON(router, "GET", "/elibrary/v1/books/99999", "", "")
+
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+		p := decode(t, resp)
+		assert.Equal(t, "urn:elibrary:problem:book_not_found", p.Type)
+		assert.Equal(t, "book_not_found", p.Code)
+		assert.Equal(t, "book not found", p.Title)
+		assert.Equal(t, "/elibrary/v1/books/99999", p.Instance)
+	})
+
+	t.Run("Invalid Parameter", func(t *testing.T) {
+		resp := sendJSON(router, "GET", "/elibrary/v1/books/abc", "", "")
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		p := decode(t, resp)
+		assert.Equal(t, "invalid_parameter", p.Code)
+		assert.Contains(t, p.Detail, `invalid book id "abc"`)
+	})
+
+	t.Run("Missing Token", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/elibrary/v1/books/1", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		assert.NotEmpty(t, resp.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, "missing_token", decode(t, resp).Code)
+	})
+
+	t.Run("Forbidden", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/elibrary/v1/users", nil)
+		req.Header.Set("Authorization", bearer(1, model.RolePatron))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+		assert.Equal(t, "forbidden", decode(t, resp).Code)
+	})
+
+	t.Run("Preconditions", func(t *testing.T) {
+		resp := sendJSON(router, "PATCH", "/elibrary/v1/books/1", `{"title": "Renamed"}`, "")
+		assert.Equal(t, http.StatusPreconditionRequired, resp.Code)
+		assert.Equal(t, "if_match_required", decode(t, resp).Code)
+
+		resp = sendJSON(router, "PATCH", "/elibrary/v1/books/1", `{"title": "Renamed"}`, `"99"`)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+		assert.Equal(t, "version_conflict", decode(t, resp).Code)
+	})
+
+	t.Run("Conflict Detail", func(t *testing.T) {
+		resp := sendJSON(router, "POST", "/elibrary/v1/borrow", `{"title": "Second Book"}`, "")
+
+		assert.Equal(t, http.StatusConflict, resp.Code)
+		p := decode(t, resp)
+		assert.Equal(t, "no_copies_available", p.Code)
+		assert.Empty(t, p.Detail)
+	})
+}
+
+func TestValidationAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	// failures sends a request that must fail validation and returns the
+	// failing fields by name.
+	failures := func(t *testing.T, method string, url string, body string, ifMatch string) map[string]errs.FieldError {
+		resp := sendJSON(router, method, url, body, ifMatch)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		var response problem.Problem
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		assert.Equal(t, "validation_failed", response.Code)
+		fields := make(map[string]errs.FieldError)
+		for _, field := range response.Errors {
+			fields[field.Field] = field
+		}
+		return fields
+	}
+
+	t.Run("Every Failing Field Is Listed", func(t *testing.T) {
+		fields := failures(t, "POST", "/elibrary/v1/create-user", `{"username": "x!", "email": "not-an-email", "role": "owner"}`, "")
+
+		assert.Len(t, fields, 3)
+		assert.Equal(t, "username", fields["username"].Rule)
+		assert.Equal(t, "email", fields["email"].Rule)
+		assert.Equal(t, "oneof", fields["role"].Rule)
+		assert.Equal(t, "must be one of patron, librarian, admin", fields["role"].Message)
+	})
+
+	t.Run("Book Rules", func(t *testing.T) {
+		fields := failures(t, "POST", "/elibrary/v1/create-book", `{"author": "Frank Herbert", "authors": ["Frank Herbert", ""], "available_copies": -1}`, "")
+
+		assert.Equal(t, "required", fields["title"].Rule)
+		assert.Equal(t, "required_without_all", fields["isbn"].Rule)
+		assert.Equal(t, "required", fields["authors[1]"].Rule)
+		assert.Equal(t, "min", fields["available_copies"].Rule)
+		assert.Equal(t, "must be at least 0", fields["available_copies"].Message)
+
+		fields = failures(t, "POST", "/elibrary/v1/create-book", `{"title": "Dune", "isbn_13": "9780441013592"}`, "")
+		assert.Equal(t, "isbn", fields["isbn_13"].Rule)
+		assert.NotContains(t, fields, "isbn")
+	})
+
+	t.Run("Wrong Type", func(t *testing.T) {
+		fields := failures(t, "POST", "/elibrary/v1/create-book", `{"title": "Dune", "isbn": "9780441013593", "available_copies": "two"}`, "")
+
+		assert.Equal(t, "type", fields["available_copies"].Rule)
+	})
+
+	t.Run("Patches", func(t *testing.T) {
+		fields := failures(t, "PATCH", "/elibrary/v1/books/1", `{"isbn": "12345", "title": ""}`, "*")
+		assert.Equal(t, "isbn", fields["isbn"].Rule)
+		assert.Equal(t, "min", fields["title"].Rule)
+
+		fields = failures(t, "PATCH", "/elibrary/v1/users/1", `{"email": "nick"}`, "*")
+		assert.Equal(t, "email", fields["email"].Rule)
+	})
+
+	t.Run("Copies And Loans", func(t *testing.T) {
+		fields := failures(t, "POST", "/elibrary/v1/books/1/copies", `{"status": "borrowed"}`, "")
+		assert.Equal(t, "oneof", fields["status"].Rule)
+
+		fields = failures(t, "POST", "/elibrary/v1/borrow", `{}`, "")
+		assert.Equal(t, "required", fields["title"].Rule)
+	})
+}
+
+func TestResponseShape(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	router := SetupRouter(db, testTokens)
+
+	t.Run("Book", func(t *testing.T) {
+		resp := sendJSON(router, "GET", "/elibrary/v1/books/1", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]map[string]interface{}
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		book := response["book"]
+		assert.EqualValues(t, 1, book["id"])
+		assert.NotContains(t, book, "ID")
+		assert.NotContains(t, book, "DeletedAt")
+		assert.NotContains(t, book, "deleted_at")
+
+		createdAt, _ := book["created_at"].(string)
+		_, err := time.Parse(time.RFC3339, createdAt)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(createdAt, "Z"))
+	})
+
+	t.Run("Client Cannot Set ID", func(t *testing.T) {
+		resp := sendJSON(router, "POST", "/elibrary/v1/create-book", `{"id": 500, "title": "Dune", "isbn": "9780441013593"}`, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.Book
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		assert.NotEqual(t, uint(500), response["book"].ID)
+		assert.Equal(t, "Dune", response["book"].Title)
+	})
+
+	t.Run("Empty Collections", func(t *testing.T) {
+		resp := sendJSON(router, "GET", "/elibrary/v1/holds", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Contains(t, resp.Body.String(), `"data":[]`)
+	})
+}
+
+func TestDigitalLendingAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	store, err := storage.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	links, err := storage.NewSigner(config.StorageConfig{LinkSecret: "test-link-secret", LinkTTL: config.Duration{Duration: 15 * time.Minute}})
+	assert.NoError(t, err)
+
+	// everything logged, to check that download links are not
+	logs := &bytes.Buffer{}
+	gin.DefaultWriter = io.MultiWriter(os.Stdout, logs)
+	log.SetOutput(io.MultiWriter(os.Stderr, logs))
+	defer func() {
+		gin.DefaultWriter = os.Stdout
+		log.SetOutput(os.Stderr)
+	}()
+	router := SetupRouter(db, testTokens, service.WithFileStorage(store, links), service.WithMaxUploadSize(1024))
+
+	patron := model.User{FirstName: "Pat", Username: "pat", Email: "pat@example.com", Role: model.RolePatron}
+	other := model.User{FirstName: "Oli", Username: "oli", Email: "oli@example.com", Role: model.RolePatron}
+	assert.NoError(t, db.Create(&[]*model.User{&patron, &other}).Error)
+
+	epub := []byte("PK\x03\x04" + strings.Repeat("\x00", 26) + "mimetypeapplication/epub+zip" + "rest of the archive")
+	upload := func(filename string, content []byte, licenses string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, _ := form.CreateFormFile("file", filename)
+		part.Write(content)
+		if licenses != "" {
+			form.WriteField("licenses", licenses)
+		}
+		form.Close()
+
+		req, _ := http.NewRequest("POST", "/elibrary/v1/books/1/files", body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	send := func(userID uint, method string, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", bearer(userID, model.RolePatron))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	download := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	var file api.File
+
+	t.Run("Upload", func(t *testing.T) {
+		resp := upload("dune.epub", epub, "1")
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response map[string]api.File
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		file = response["file"]
+		assert.Equal(t, model.EbookEPUB, file.Format)
+		assert.Equal(t, "application/epub+zip", file.ContentType)
+		assert.Equal(t, "dune.epub", file.Filename)
+		assert.Equal(t, int64(len(epub)), file.Size)
+		assert.Len(t, file.Checksum, 64)
+		assert.Equal(t, 1, file.AvailableLicenses)
+	})
+
+	t.Run("Rejected Uploads", func(t *testing.T) {
+		resp := upload("dune.txt", []byte("just some text"), "1")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), "unsupported_file_type")
+
+		resp = upload("dune.pdf", []byte("%PDF-1.7"+strings.Repeat(" ", 2048)), "1")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+		assert.Contains(t, resp.Body.String(), "file_too_large")
+
+		// too large to even read the form of
+		resp = upload("dune.pdf", []byte("%PDF-1.7"+strings.Repeat(" ", 256<<10)), "1")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+		assert.Contains(t, resp.Body.String(), "file_too_large")
+
+		resp = upload("dune.pdf", []byte("%PDF-1.7"), "")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), `"field":"licenses"`)
+
+		resp = send(patron.ID, "GET", "/elibrary/v1/books/1/files")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var response map[string][]api.File
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		assert.Len(t, response["files"], 1)
+	})
+
+	var loan api.Loan
+	borrowURL := fmt.Sprintf("/elibrary/v1/files/%d/borrow", file.ID)
+
+	t.Run("Borrow Takes A License", func(t *testing.T) {
+		resp := send(patron.ID, "POST", borrowURL)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var response map[string]api.Loan
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		loan = response["loan"]
+		assert.Equal(t, &file.ID, loan.FileID)
+		assert.Nil(t, loan.CopyID)
+
+		resp = send(other.ID, "POST", borrowURL)
+		assert.Equal(t, http.StatusConflict, resp.Code)
+		assert.Contains(t, resp.Body.String(), "no_licenses_available")
+	})
+
+	var link api.DownloadLink
+	linkURL := fmt.Sprintf("/elibrary/v1/loans/%d/download-link", loan.ID)
+
+	t.Run("Download Link", func(t *testing.T) {
+		resp := send(other.ID, "POST", linkURL)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(patron.ID, "POST", linkURL)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var response map[string]api.DownloadLink
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		link = response["link"]
+		assert.WithinDuration(t, time.Now().Add(15*time.Minute), link.ExpiresAt.Time, time.Minute)
+
+		resp = download(link.URL)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.NotContains(t, logs.String(), "signature=", "download links must not be logged")
+		assert.Equal(t, "application/epub+zip", resp.Header().Get("Content-Type"))
+		assert.Contains(t, resp.Header().Get("Content-Disposition"), "dune.epub")
+		assert.Equal(t, epub, resp.Body.Bytes())
+	})
+
+	t.Run("Tampered Link", func(t *testing.T) {
+		later := strings.Replace(link.URL, fmt.Sprint(link.ExpiresAt.Unix()), fmt.Sprint(link.ExpiresAt.Add(time.Hour).Unix()), 1)
+		resp := download(later)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+		assert.Contains(t, resp.Body.String(), "invalid_download_link")
+
+		resp = download(fmt.Sprintf("/elibrary/v1/loans/%d/download", loan.ID))
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("Return Revokes Links And Frees The License", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/elibrary/v1/return", strings.NewReader(`{"title": "Test Book"}`))
+		req.Header.Set("Authorization", bearer(patron.ID, model.RolePatron))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = download(link.URL)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(patron.ID, "POST", linkURL)
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = send(other.ID, "POST", borrowURL)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		var expiring model.LoanDetail
+		assert.NoError(t, db.Where("user_id = ? AND is_returned = ?", other.ID, false).First(&expiring).Error)
+		assert.NoError(t, db.Model(&model.LoanDetail{}).Where("id = ?", expiring.ID).Update("return_date", time.Now().Add(-time.Minute)).Error)
+
+		svc := service.New(repository.NewGormRepositories(db))
+		expired, err := svc.ExpireDigitalLoans(context.Background(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, expired)
+
+		expired, err = svc.ExpireDigitalLoans(context.Background(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, expired)
+
+		var returned model.LoanDetail
+		assert.NoError(t, db.First(&returned, expiring.ID).Error)
+		assert.True(t, returned.IsReturned)
+
+		resp := send(other.ID, "POST", fmt.Sprintf("/elibrary/v1/loans/%d/download-link", expiring.ID))
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		resp = send(other.ID, "GET", "/elibrary/v1/books/1/files")
+		var files map[string][]api.File
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &files))
+		if assert.Len(t, files["files"], 1) {
+			assert.Equal(t, 1, files["files"][0].AvailableLicenses)
+		}
+
+		events := listPage[api.Event](t, router, "/elibrary/v1/events?type=loan.expired")
+		if assert.Len(t, events.Data, 1) {
+			assert.Equal(t, expiring.ID, *events.Data[0].LoanID)
+			assert.Equal(t, other.ID, *events.Data[0].UserID)
+		}
+
+		resp = send(patron.ID, "GET", "/elibrary/v1/events")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var own page[api.Event]
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &own))
+		assert.Empty(t, own.Data)
+	})
+
+	t.Run("Expiry Skips A Failing Loan", func(t *testing.T) {
+		stuck := model.LoanDetail{BookID: 1, UserID: patron.ID, NameOfBorrower: "Pat", FileID: &file.ID,
+			LoanDate: time.Now().AddDate(0, 0, -14), ReturnDate: time.Now().Add(-time.Hour)}
+		next := stuck
+		next.UserID = other.ID
+		next.NameOfBorrower = "Oli"
+		assert.NoError(t, db.Create(&stuck).Error)
+		assert.NoError(t, db.Create(&next).Error)
+		assert.NoError(t, db.Model(&model.DigitalFile{}).Where("id = ?", file.ID).
+			Update("licenses_in_use", gorm.Expr("licenses_in_use + 2")).Error)
+
+		svc := service.New(repository.NewGormRepositories(db))
+		t.Run("While It Fails", func(t *testing.T) {
+			failWrites(t, db, "loan_details", fmt.Sprintf("NEW.id = %d", stuck.ID))
+			expired, err := svc.ExpireDigitalLoans(context.Background(), time.Now())
+			assert.Error(t, err)
+			assert.Equal(t, 1, expired)
+
+			assert.NoError(t, db.First(&stuck, stuck.ID).Error)
+			assert.False(t, stuck.IsReturned)
+			assert.NoError(t, db.First(&next, next.ID).Error)
+			assert.True(t, next.IsReturned)
+		})
+
+		expired, err := svc.ExpireDigitalLoans(context.Background(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.NoError(t, db.First(&stuck, stuck.ID).Error)
+		assert.True(t, stuck.IsReturned)
+	})
+
+	t.Run("Physical Loans Have No Link", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/elibrary/v1/borrow", strings.NewReader(`{"title": "Test Book"}`))
+		req.Header.Set("Authorization", bearer(patron.ID, model.RolePatron))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var response map[string]api.Loan
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+
+		resp = send(patron.ID, "POST", fmt.Sprintf("/elibrary/v1/loans/%d/download-link", response["loan"].ID))
+		assert.Equal(t, http.StatusConflict, resp.Code)
+		assert.Contains(t, resp.Body.String(), "not_digital_loan")
+	})
+}
+
+func TestReadingAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+
+	store, err := storage.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	links, err := storage.NewSigner(config.StorageConfig{LinkSecret: "test-link-secret", LinkTTL: config.Duration{Duration: 15 * time.Minute}})
+	assert.NoError(t, err)
+	router := SetupRouter(db, testTokens, service.WithFileStorage(store, links))
+
+	patron := model.User{FirstName: "Pat", Username: "pat", Email: "pat@example.com", Role: model.RolePatron}
+	other := model.User{FirstName: "Oli", Username: "oli", Email: "oli@example.com", Role: model.RolePatron}
+	assert.NoError(t, db.Create(&[]*model.User{&patron, &other}).Error)
+
+	file := model.DigitalFile{BookID: 1, Format: model.EbookEPUB, Filename: "dune.epub", StorageKey: "books/1/dune.epub", Licenses: 2}
+	assert.NoError(t, db.Create(&file).Error)
+
+	send := func(userID uint, method string, url string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", bearer(userID, model.RolePatron))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	type reading struct {
+		Progress   *api.ReadingProgress `json:"progress"`
+		Bookmarks  []api.Bookmark       `json:"bookmarks"`
+		Highlights []api.Highlight      `json:"highlights"`
+	}
+	getReading := func(userID uint) reading {
+		resp := send(userID, "GET", "/elibrary/v1/books/1/reading", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var response reading
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		return response
+	}
+	borrow := func() {
+		resp := send(patron.ID, "POST", fmt.Sprintf("/elibrary/v1/files/%d/borrow", file.ID), "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+	}
+	giveBack := func() {
+		resp := send(patron.ID, "POST", "/elibrary/v1/return", `{"title": "Test Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	}
+
+	const progressURL = "/elibrary/v1/books/1/reading/progress"
+
+	t.Run("Requires A Digital Loan", func(t *testing.T) {
+		resp := send(patron.ID, "PUT", progressURL, `{"location": {"chapter": "1"}, "percent": 1}`)
+		assert.Equal(t, http.StatusConflict, resp.Code)
+		assert.Contains(t, resp.Body.String(), "no_active_loan")
+
+		resp = send(patron.ID, "POST", "/elibrary/v1/books/1/reading/bookmarks", `{"location": {"page": 3}}`)
+		assert.Equal(t, http.StatusConflict, resp.Code)
+
+		reading := getReading(patron.ID)
+		assert.Nil(t, reading.Progress)
+		assert.NotNil(t, reading.Bookmarks)
+		assert.Empty(t, reading.Bookmarks)
+	})
+
+	var loanID uint
+
+	t.Run("Save Progress", func(t *testing.T) {
+		borrow()
+
+		// another device saves the first progress too, just before this one
+		assert.NoError(t, db.Exec(`CREATE TRIGGER other_device BEFORE INSERT ON reading_progresses WHEN NEW.percent = 20.5
+			BEGIN INSERT INTO reading_progresses (created_at, updated_at, user_id, book_id, loan_id, percent, recorded_at)
+			VALUES (NEW.created_at, NEW.updated_at, NEW.user_id, NEW.book_id, NEW.loan_id, 1, NEW.recorded_at); END`).Error)
+		defer db.Exec("DROP TRIGGER other_device")
+
+		resp := send(patron.ID, "PUT", progressURL, `{"location": {"chapter": "3", "cfi": "epubcfi(/6/8!/4/2/1:0)"}, "percent": 20.5}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var response map[string]api.ReadingProgress
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		assert.Equal(t, "3", response["progress"].Location.Chapter)
+		assert.Equal(t, 20.5, response["progress"].Percent)
+		loanID = response["progress"].LoanID
+		assert.NotZero(t, loanID)
+
+		resp = send(patron.ID, "PUT", progressURL, `{"location": {"chapter": "1"}, "percent": 150}`)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), `"field":"percent"`)
+
+		resp = send(patron.ID, "PUT", progressURL, `{"percent": 10}`)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), `"field":"location"`)
+	})
+
+	t.Run("Stale Progress Is Ignored", func(t *testing.T) {
+		earlier := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		resp := send(patron.ID, "PUT", progressURL, `{"location": {"chapter": "1"}, "percent": 2, "recorded_at": "`+earlier+`"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		progress := getReading(patron.ID).Progress
+		if assert.NotNil(t, progress) {
+			assert.Equal(t, "3", progress.Location.Chapter)
+			assert.Equal(t, 20.5, progress.Percent)
+		}
+	})
+
+	t.Run("Future Progress Is Taken As Now", func(t *testing.T) {
+		later := time.Now().AddDate(1, 0, 0).UTC().Format(time.RFC3339)
+		resp := send(patron.ID, "PUT", progressURL, `{"location": {"chapter": "4"}, "percent": 30, "recorded_at": "`+later+`"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var response map[string]api.ReadingProgress
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		assert.False(t, response["progress"].RecordedAt.After(time.Now()))
+
+		// a later save from a device with a correct clock still goes through
+		resp = send(patron.ID, "PUT", progressURL, `{"location": {"chapter": "3", "cfi": "epubcfi(/6/8!/4/2/1:0)"}, "percent": 20.5}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		progress := getReading(patron.ID).Progress
+		if assert.NotNil(t, progress) {
+			assert.Equal(t, "3", progress.Location.Chapter)
+			assert.Equal(t, 20.5, progress.Percent)
+		}
+	})
+
+	t.Run("Survives Renewal", func(t *testing.T) {
+		resp := send(patron.ID, "POST", "/elibrary/v1/extend", `{"title": "Test Book"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		progress := getReading(patron.ID).Progress
+		if assert.NotNil(t, progress) {
+			assert.Equal(t, "3", progress.Location.Chapter)
+			assert.Equal(t, loanID, progress.LoanID)
+		}
+	})
+
+	t.Run("Bookmarks And Highlights", func(t *testing.T) {
+		resp := send(patron.ID, "POST", "/elibrary/v1/books/1/reading/bookmarks", `{"location": {"chapter": "2"}, "label": "Arrakis"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var bookmark map[string]api.Bookmark
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &bookmark))
+
+		resp = send(patron.ID, "POST", "/elibrary/v1/books/1/reading/highlights", `{"location": {"cfi": "epubcfi(/6/4!/4/10,/1:0,/1:20)"}, "text": "Fear is the mind-killer.", "color": "yellow"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		reading := getReading(patron.ID)
+		assert.Len(t, reading.Bookmarks, 1)
+		if assert.Len(t, reading.Highlights, 1) {
+			assert.Equal(t, "Fear is the mind-killer.", reading.Highlights[0].Text)
+		}
+		assert.Empty(t, getReading(other.ID).Highlights)
+
+		bookmarkURL := fmt.Sprintf("/elibrary/v1/bookmarks/%d", bookmark["bookmark"].ID)
+		resp = send(other.ID, "DELETE", bookmarkURL, "")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = send(patron.ID, "DELETE", bookmarkURL, "")
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		resp = send(patron.ID, "DELETE", bookmarkURL, "")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+		assert.Contains(t, resp.Body.String(), "bookmark_not_found")
+	})
+
+	t.Run("Forgotten On Return", func(t *testing.T) {
+		giveBack()
+
+		reading := getReading(patron.ID)
+		assert.Nil(t, reading.Progress)
+		assert.Empty(t, reading.Bookmarks)
+		assert.Empty(t, reading.Highlights)
+	})
+
+	t.Run("Kept After Return On Request", func(t *testing.T) {
+		borrow()
+
+		resp := send(patron.ID, "PUT", progressURL, `{"location": {"page": 41}, "percent": 59, "keep_after_return": true}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		// a device that does not send the flag leaves it set
+		resp = send(patron.ID, "PUT", progressURL, `{"location": {"page": 42}, "percent": 60}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		resp = send(patron.ID, "POST", "/elibrary/v1/books/1/reading/highlights", `{"location": {"page": 42}, "text": "The spice must flow."}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		giveBack()
+
+		reading := getReading(patron.ID)
+		if assert.NotNil(t, reading.Progress) {
+			assert.Equal(t, 42, reading.Progress.Location.Page)
+		}
+		assert.Len(t, reading.Highlights, 1)
+
+		resp = send(patron.ID, "PUT", progressURL, `{"location": {"page": 43}, "percent": 61}`)
+		assert.Equal(t, http.StatusConflict, resp.Code)
+	})
+}
+
+func TestImportAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+	router := SetupRouter(db, testTokens, service.WithMaxUploadSize(64<<10))
+
+	existing := createTestBook(t, router, `{"title": "God Emperor of Dune", "author": "Frank Herbert", "isbn": "9780441013593"}`)
+
+	upload := func(role model.Role, filename string, content []byte, fields map[string]string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, _ := form.CreateFormFile("file", filename)
+		part.Write(content)
+		for name, value := range fields {
+			form.WriteField(name, value)
+		}
+		form.Close()
+
+		req, _ := http.NewRequest("POST", "/elibrary/v1/books/import", body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", bearer(1, role))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	report := func(resp *httptest.ResponseRecorder) api.ImportReport {
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var response map[string]api.ImportReport
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		return response["report"]
+	}
+
+	books := []byte("title,authors,isbn,publisher,publication_year,available_copies\n" +
+		"Dune,Frank Herbert,978-0-441-17271-9,Chilton Books,1965,2\n" +
+		",Frank Herbert,0441172717,,,\n" +
+		"Dune Messiah,Frank Herbert,9780441172696,,soon,\n" +
+		"God Emperor of Dune,Frank Herbert,9780441013593,Putnam,1981,3\n")
+
+	t.Run("Dry Run", func(t *testing.T) {
+		got := report(upload(model.RoleLibrarian, "books.csv", books, map[string]string{"dry_run": "true"}))
+		assert.True(t, got.DryRun)
+		assert.Equal(t, 4, got.Rows)
+		assert.Equal(t, 1, got.Created)
+		assert.Equal(t, 1, got.Updated)
+		assert.Equal(t, 2, got.Failed)
+		if assert.Len(t, got.Errors, 2) {
+			assert.Equal(t, 3, got.Errors[0].Row)
+			assert.Equal(t, "validation_failed", got.Errors[0].Code)
+			assert.Equal(t, "title", got.Errors[0].Fields[0].Field)
+			assert.Equal(t, 4, got.Errors[1].Row)
+			assert.Equal(t, "9780441172696", got.Errors[1].ISBN)
+			assert.Equal(t, "publication_year", got.Errors[1].Fields[0].Field)
+		}
+
+		resp := sendJSON(router, "GET", "/elibrary/v1/books/isbn/9780441172719", "", "")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+		var book model.BookDetail
+		assert.NoError(t, db.First(&book, existing.ID).Error)
+		assert.Empty(t, book.Publisher)
+	})
+
+	t.Run("Upsert By ISBN", func(t *testing.T) {
+		got := report(upload(model.RoleLibrarian, "books.csv", books, nil))
+		assert.False(t, got.DryRun)
+		assert.Equal(t, 1, got.Created)
+		assert.Equal(t, 1, got.Updated)
+
+		resp := sendJSON(router, "GET", "/elibrary/v1/books/isbn/0441172717", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var created map[string]api.Book
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+		assert.Equal(t, "Dune", created["book"].Title)
+		assert.Equal(t, "Frank Herbert", created["book"].Author)
+		assert.Equal(t, 1965, created["book"].PublicationYear)
+		assert.Equal(t, 2, created["book"].TotalCopies)
+
+		var updated model.BookDetail
+		assert.NoError(t, db.First(&updated, existing.ID).Error)
+		assert.Equal(t, "Putnam", updated.Publisher)
+		assert.Equal(t, 1981, updated.PublicationYear)
+		assert.Equal(t, existing.WorkID, updated.WorkID)
+		var copies int64
+		db.Model(&model.BookCopy{}).Where("book_id = ?", existing.ID).Count(&copies)
+		assert.Zero(t, copies)
+
+		got = report(upload(model.RoleLibrarian, "books.csv", books, nil))
+		assert.Equal(t, 0, got.Created)
+		assert.Equal(t, 2, got.Updated)
+	})
+
+	t.Run("MARC", func(t *testing.T) {
+		record := marc.Record{
+			Leader:        "00000cam a2200000 i 4500",
+			ControlFields: []marc.ControlField{{Tag: "008", Value: "760101s1976    nyu           000 1 eng d"}},
+			DataFields: []marc.DataField{
+				{Tag: "020", Ind1: ' ', Ind2: ' ', Subfields: []marc.Subfield{{Code: 'a', Value: "0399116958 (hardcover)"}}},
+				{Tag: "100", Ind1: '1', Ind2: ' ', Subfields: []marc.Subfield{{Code: 'a', Value: "Herbert, Frank,"}}},
+				{Tag: "245", Ind1: '1', Ind2: '0', Subfields: []marc.Subfield{{Code: 'a', Value: "Children of Dune /"}, {Code: 'c', Value: "Frank Herbert."}}},
+				{Tag: "264", Ind1: ' ', Ind2: '1', Subfields: []marc.Subfield{{Code: 'a', Value: "New York :"}, {Code: 'b', Value: "Putnam,"}, {Code: 'c', Value: "[1976]"}}},
+			},
+		}
+		var content bytes.Buffer
+		assert.NoError(t, marc.NewWriter(&content).Write(record))
+
+		// a file that breaks off imports nothing
+		resp := upload(model.RoleLibrarian, "children.mrc", append(content.Bytes(), "00042broken"...), nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), "invalid_import_file")
+		resp = sendJSON(router, "GET", "/elibrary/v1/books/isbn/0399116958", "", "")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+
+		got := report(upload(model.RoleLibrarian, "children.mrc", content.Bytes(), nil))
+		assert.Equal(t, 1, got.Created)
+
+		resp = sendJSON(router, "GET", "/elibrary/v1/books/isbn/0399116958", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var created map[string]api.Book
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+		assert.Equal(t, "Children of Dune", created["book"].Title)
+		assert.Equal(t, "Herbert, Frank", created["book"].Author)
+		assert.Equal(t, "Putnam", created["book"].Publisher)
+		assert.Equal(t, 1976, created["book"].PublicationYear)
+		assert.Equal(t, "eng", created["book"].Language)
+	})
+
+	t.Run("MARCXML", func(t *testing.T) {
+		document := `<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <leader>00000cam a2200000 a 4500</leader>
+    <controlfield tag="008">850101s1985    nyu           000 1 eng d</controlfield>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">0399131019</subfield></datafield>
+    <datafield tag="100" ind1="1" ind2=" "><subfield code="a">Herbert, Frank.</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="0"><subfield code="a">Chapterhouse, Dune /</subfield></datafield>
+    <datafield tag="260" ind1=" " ind2=" "><subfield code="b">Putnam,</subfield><subfield code="c">c1985.</subfield></datafield>
+  </record>
+  <record>
+    <datafield tag="245" ind1="1" ind2="0"><subfield code="a">No ISBN</subfield></datafield>
+  </record>
+</collection>`
+
+		got := report(upload(model.RoleLibrarian, "chapterhouse.xml", []byte(document), map[string]string{"format": "marcxml"}))
+		assert.Equal(t, 1, got.Created)
+		if assert.Len(t, got.Errors, 1) {
+			assert.Equal(t, 2, got.Errors[0].Row)
+			assert.Equal(t, "isbn", got.Errors[0].Fields[0].Field)
+		}
+
+		resp := sendJSON(router, "GET", "/elibrary/v1/books/isbn/0399131019", "", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var created map[string]api.Book
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+		assert.Equal(t, "Chapterhouse, Dune", created["book"].Title)
+		assert.Equal(t, 1985, created["book"].PublicationYear)
+	})
+
+	t.Run("Rejected Files", func(t *testing.T) {
+		resp := upload(model.RoleLibrarian, "books.csv", []byte("title,shelf\nDune,A1\n"), nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), "invalid_import_file")
+
+		resp = upload(model.RoleLibrarian, "books.txt", books, nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), "unsupported_format")
+
+		resp = upload(model.RoleLibrarian, "books.csv", books, map[string]string{"format": "xlsx"})
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), `"field":"format"`)
+
+		huge := append([]byte("title,author,isbn\n"), bytes.Repeat([]byte("Dune,Frank Herbert,0441172717\n"), 10000)...)
+		resp = upload(model.RoleLibrarian, "books.csv", huge, nil)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+		assert.Contains(t, resp.Body.String(), "file_too_large")
+
+		resp = upload(model.RolePatron, "books.csv", books, nil)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+}
+
+func TestExportAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := setupMockDB()
+	router := SetupRouter(db, testTokens)
+
+	// the books of setupMockDB have invalid ISBNs, which do not import
+	since := time.Now().UTC().Format(time.RFC3339Nano)
+	dune := createTestBook(t, router, `{"title": "Dune", "authors": ["Frank Herbert"], "isbn": "0441172717", "publisher": "Chilton Books", "publication_year": 1965, "language": "eng"}`)
+
+	patrons := make([]model.User, 0, 600)
+	for i := 0; i < 600; i++ {
+		patrons = append(patrons, model.User{FirstName: "Reader", Username: fmt.Sprintf("reader%d", i), Email: fmt.Sprintf("reader%d@example.com", i), Role: model.RolePatron})
+	}
+	assert.NoError(t, db.CreateInBatches(&patrons, 100).Error)
+
+	returnedAt := time.Now().Add(-24 * time.Hour)
+	assert.NoError(t, db.Create(&[]model.LoanDetail{
+		{BookID: 1, UserID: patrons[0].ID, NameOfBorrower: "Reader", LoanDate: time.Now().AddDate(0, 0, -20), ReturnDate: time.Now().AddDate(0, 0, -6), IsReturned: true, ReturnedAt: &returnedAt},
+		{BookID: dune.ID, UserID: patrons[1].ID, NameOfBorrower: "Reader", LoanDate: time.Now().AddDate(0, 0, -2), ReturnDate: time.Now().AddDate(0, 0, 12)},
+	}).Error)
+
+	export := func(role model.Role, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", bearer(1, role))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("Books As CSV", func(t *testing.T) {
+		resp := export(model.RoleLibrarian, "/elibrary/v1/export/books")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", resp.Header().Get("Content-Type"))
+		assert.Contains(t, resp.Header().Get("Content-Disposition"), "books-")
+
+		rows, err := csv.NewReader(resp.Body).ReadAll()
+		assert.NoError(t, err)
+		if assert.Len(t, rows, 4) {
+			assert.Equal(t, []string{"id", "title", "author", "authors", "isbn", "isbn_10", "isbn_13", "publisher", "publication_year", "language", "format"}, rows[0])
+			assert.Equal(t, []string{fmt.Sprint(dune.ID), "Dune", "Frank Herbert", "Frank Herbert", "9780441172719", "0441172717", "9780441172719", "Chilton Books", "1965", "eng", ""}, rows[3])
+		}
+	})
+
+	t.Run("Books As MARCXML", func(t *testing.T) {
+		resp := export(model.RoleLibrarian, "/elibrary/v1/export/books?format=marcxml")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "application/marcxml+xml", resp.Header().Get("Content-Type"))
+
+		var records []marc.Record
+		r := marc.NewXMLReader(resp.Body)
+		for record, err := r.Read(); err == nil; record, err = r.Read() {
+			records = append(records, record)
+		}
+		if assert.Len(t, records, 3) {
+			assert.Equal(t, "Dune", records[2].Value("245", 'a'))
+			assert.Equal(t, "Frank Herbert", records[2].Value("100", 'a'))
+			assert.Equal(t, "eng", records[2].Control("008")[35:38])
+		}
+	})
+
+	t.Run("Books Read Back In", func(t *testing.T) {
+		for _, format := range []string{"csv", "marcxml"} {
+			url := "/elibrary/v1/export/books?format=" + format + "&from=" + since
+			exported := export(model.RoleLibrarian, url)
+			assert.Equal(t, http.StatusOK, exported.Code)
+
+			body := &bytes.Buffer{}
+			form := multipart.NewWriter(body)
+			_, disposition, _ := mime.ParseMediaType(exported.Header().Get("Content-Disposition"))
+			part, _ := form.CreateFormFile("file", disposition["filename"])
+			part.Write(exported.Body.Bytes())
+			form.Close()
+			req, _ := http.NewRequest("POST", "/elibrary/v1/books/import", body)
+			req.Header.Set("Content-Type", form.FormDataContentType())
+			req.Header.Set("Authorization", bearer(1, model.RoleLibrarian))
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusOK, resp.Code, format)
+			var response map[string]api.ImportReport
+			assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+			assert.Equal(t, 1, response["report"].Rows, format)
+			assert.Equal(t, 1, response["report"].Updated, format)
+			assert.Equal(t, 0, response["report"].Failed, format)
+
+			assert.Equal(t, exported.Body.String(), export(model.RoleLibrarian, url).Body.String(), format)
+		}
+	})
+
+	t.Run("Users As NDJSON", func(t *testing.T) {
+		resp := export(model.RoleLibrarian, "/elibrary/v1/export/users?format=ndjson")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "application/x-ndjson", resp.Header().Get("Content-Type"))
+		assert.NotContains(t, resp.Body.String(), "password")
+
+		lines := strings.Split(strings.TrimSpace(resp.Body.String()), "\n")
+		assert.Len(t, lines, 601)
+		var last api.User
+		assert.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+		assert.Equal(t, "reader599", last.Username)
+	})
+
+	t.Run("Loan Filters", func(t *testing.T) {
+		resp := export(model.RoleLibrarian, "/elibrary/v1/export/loans?returned=false")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		rows, err := csv.NewReader(resp.Body).ReadAll()
+		assert.NoError(t, err)
+		if assert.Len(t, rows, 2) {
+			assert.Equal(t, "Dune", rows[1][2])
+			assert.Equal(t, "false", rows[1][10])
+		}
+
+		tenDaysAgo := time.Now().AddDate(0, 0, -10).Format(time.DateOnly)
+		resp = export(model.RoleLibrarian, "/elibrary/v1/export/loans?format=ndjson&to="+tenDaysAgo)
+		lines := strings.Split(strings.TrimSpace(resp.Body.String()), "\n")
+		if assert.Len(t, lines, 1) {
+			var loan api.Loan
+			assert.NoError(t, json.Unmarshal([]byte(lines[0]), &loan))
+			assert.True(t, loan.IsReturned)
+		}
+
+		resp = export(model.RoleLibrarian, "/elibrary/v1/export/books?from="+time.Now().AddDate(0, 0, 1).Format(time.DateOnly))
+		rows, err = csv.NewReader(resp.Body).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, rows, 1)
+	})
+
+	t.Run("Rejected Exports", func(t *testing.T) {
+		resp := export(model.RoleLibrarian, "/elibrary/v1/export/holds")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = export(model.RoleLibrarian, "/elibrary/v1/export/users?format=marcxml")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), "unsupported_format")
+		assert.Empty(t, resp.Header().Get("Content-Disposition"))
+
+		resp = export(model.RoleLibrarian, "/elibrary/v1/export/loans?from=yesterday")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), "invalid_parameter")
+
+		resp = export(model.RolePatron, "/elibrary/v1/export/loans")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+}